@@ -0,0 +1,184 @@
+package eskip
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OrderAnnotation is the name of the annotation stored in the Order
+	// field of the routes, e.g. // @order=10
+	OrderAnnotation = "order"
+
+	// BackendOptionAnnotationPrefix is the prefix of the annotations
+	// stored in the BackendOptions field of the routes, without the
+	// prefix, e.g. // @backend-timeout=5s
+	BackendOptionAnnotationPrefix = "backend-"
+
+	// EnvAnnotation is the name of the annotation stored in the Env field
+	// of the routes, e.g. // @env=staging
+	EnvAnnotation = "env"
+
+	// RateLimitAnnotation is the name of the annotation stored in the
+	// RateLimit field of the routes, e.g. // @ratelimit=100/s
+	RateLimitAnnotation = "ratelimit"
+
+	// DisabledAnnotation is the name of the annotation stored in the
+	// Disabled and DisabledReason fields of the routes, optionally with
+	// the reason after a colon, e.g. // @disabled: migrating to v2
+	DisabledAnnotation = "disabled"
+
+	// SourceFileAnnotation is set by ParseFS to the path of the file that
+	// the route was parsed from, e.g. // @source-file=routes/api.eskip
+	SourceFileAnnotation = "source-file"
+
+	// SkipPreprocessingAnnotation marks the routes that the Editor and
+	// Clone preprocessors leave untouched, e.g. // @skip-preprocessing
+	SkipPreprocessingAnnotation = "skip-preprocessing"
+
+	// SkipCloneAnnotation marks the routes that the Clone preprocessor
+	// doesn't clone, e.g. // @skip-clone
+	SkipCloneAnnotation = "skip-clone"
+)
+
+const invalidAnnotationErrorFmt = "invalid %s annotation in route %s: %s"
+
+// tells whether an annotation is stored in a dedicated field of the route
+func isFieldAnnotation(key string) bool {
+	return key == OrderAnnotation || key == EnvAnnotation || key == RateLimitAnnotation ||
+		key == DisabledAnnotation || strings.HasPrefix(key, BackendOptionAnnotationPrefix)
+}
+
+func hasAnnotation(r *Route, key string) bool {
+	_, ok := r.Annotations[key]
+	return ok
+}
+
+// returns the annotations of the route including the ones stored in
+// dedicated fields, as they are printed
+func routeAnnotations(r *Route) map[string]string {
+	if r.Order == 0 && r.Env == "" && r.RateLimit == "" && !r.Disabled && len(r.BackendOptions) == 0 {
+		return r.Annotations
+	}
+
+	a := copyAnnotations(r.Annotations)
+	if a == nil {
+		a = make(map[string]string)
+	}
+
+	if r.Order != 0 {
+		a[OrderAnnotation] = strconv.Itoa(r.Order)
+	}
+
+	if r.Env != "" {
+		a[EnvAnnotation] = r.Env
+	}
+
+	if r.RateLimit != "" {
+		a[RateLimitAnnotation] = r.RateLimit
+	}
+
+	if r.Disabled {
+		a[DisabledAnnotation] = r.DisabledReason
+	}
+
+	for k, v := range r.BackendOptions {
+		a[BackendOptionAnnotationPrefix+k] = v
+	}
+
+	return a
+}
+
+// sets the annotations of the route, and moves the ones with a dedicated
+// field into the field
+func setAnnotations(r *Route, a map[string]string) error {
+	var hasFields bool
+	for k := range a {
+		if isFieldAnnotation(k) {
+			hasFields = true
+			break
+		}
+	}
+
+	if !hasFields {
+		r.Annotations = a
+		return nil
+	}
+
+	rest := make(map[string]string)
+	for k, v := range a {
+		switch {
+		case k == OrderAnnotation:
+			order, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf(invalidAnnotationErrorFmt, OrderAnnotation, r.Id, v)
+			}
+
+			r.Order = order
+		case k == EnvAnnotation:
+			r.Env = v
+		case k == RateLimitAnnotation:
+			r.RateLimit = v
+		case k == DisabledAnnotation:
+			r.Disabled = true
+			r.DisabledReason = v
+		case strings.HasPrefix(k, BackendOptionAnnotationPrefix):
+			if r.BackendOptions == nil {
+				r.BackendOptions = make(map[string]string)
+			}
+
+			r.BackendOptions[strings.TrimPrefix(k, BackendOptionAnnotationPrefix)] = v
+		default:
+			rest[k] = v
+		}
+	}
+
+	if len(rest) == 0 {
+		rest = nil
+	}
+
+	r.Annotations = rest
+	return nil
+}
+
+// SelectByEnv returns the routes for an environment: the routes with the
+// same Env, parsed from the @env annotation, and the routes without an Env.
+// The order of the routes is kept, and they are not copied.
+func SelectByEnv(routes []*Route, env string) []*Route {
+	var selected []*Route
+	for _, r := range routes {
+		if r.Env == "" || r.Env == env {
+			selected = append(selected, r)
+		}
+	}
+
+	return selected
+}
+
+// ActiveRoutes returns the routes that are not disabled with the @disabled
+// annotation. The order of the routes is kept, and they are not copied.
+func ActiveRoutes(routes []*Route) []*Route {
+	var active []*Route
+	for _, r := range routes {
+		if !r.Disabled {
+			active = append(active, r)
+		}
+	}
+
+	return active
+}
+
+// DisabledRoutes returns the IDs of the disabled routes mapped to the reason
+// of disabling them, e.g. for auditing. The reason is empty when the
+// @disabled annotation doesn't have one.
+func DisabledRoutes(routes []*Route) map[string]string {
+	disabled := make(map[string]string)
+	for _, r := range routes {
+		if r.Disabled {
+			disabled[r.Id] = r.DisabledReason
+		}
+	}
+
+	return disabled
+}
@@ -0,0 +1,279 @@
+package eskip
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBackendOptionsAnnotations(t *testing.T) {
+	r, err := Parse(`
+		// @backend-timeout=5s
+		// @backend-health-check-interval=10s
+		// @team=payments
+		r1: * -> <roundRobin, "https://a.example.org", "https://b.example.org">;
+
+		r2: * -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(map[string]string{"timeout": "5s", "health-check-interval": "10s"}, r[0].BackendOptions); d != "" {
+		t.Error("failed to parse the backend options")
+		t.Log(d)
+	}
+
+	if d := cmp.Diff(map[string]string{"team": "payments"}, r[0].Annotations); d != "" {
+		t.Error("invalid annotations")
+		t.Log(d)
+	}
+
+	if r[1].BackendOptions != nil {
+		t.Error("unexpected backend options")
+	}
+
+	s := String(r...)
+	const expect = "// @backend-health-check-interval=10s\n// @backend-timeout=5s\n// @team=payments\n" +
+		`r1: * -> <roundRobin, "https://a.example.org", "https://b.example.org">;` + "\n" +
+		`r2: * -> <shunt>;`
+	if s != expect {
+		t.Errorf("invalid routes string, got: %s, expected: %s", s, expect)
+	}
+
+	rr, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(r, rr); d != "" {
+		t.Error("failed to round-trip the backend options")
+		t.Log(d)
+	}
+
+	b, err := MarshalRoutesJSON(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rj, err := UnmarshalRoutesJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(r[0].BackendOptions, rj[0].BackendOptions); d != "" {
+		t.Error("failed to round-trip the backend options through JSON")
+		t.Log(d)
+	}
+
+	c := r[0].Copy()
+	c.BackendOptions["timeout"] = "1s"
+	if r[0].BackendOptions["timeout"] != "5s" {
+		t.Error("failed to copy the backend options")
+	}
+}
+
+func TestSkipPreprocessingAnnotations(t *testing.T) {
+	r, err := Parse(`
+		r1: Source("10.0.0.0/8") -> <shunt>;
+
+		// @skip-preprocessing
+		r2: Source("10.0.0.0/8") -> <shunt>;
+
+		// @skip-clone
+		r3: Source("10.0.0.0/8") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("editor", func(t *testing.T) {
+		e := NewEditor(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)")
+		result := e.Do(CopyRoutes(r))
+
+		var names []string
+		for _, ri := range result {
+			names = append(names, ri.Predicates[0].Name)
+		}
+
+		if d := cmp.Diff([]string{"ClientIP", "Source", "ClientIP"}, names); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		c := NewClone(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)")
+		if d := cmp.Diff([]string{"r1", "r2", "r3", "clone_r1"}, routeIDs(c.Do(r))); d != "" {
+			t.Error(d)
+		}
+	})
+}
+
+func TestEnvAnnotation(t *testing.T) {
+	r, err := Parse(`
+		// @env=staging
+		// @team=payments
+		r1: Path("/debug") -> "https://debug.example.org";
+
+		// @env=prod
+		r2: Path("/debug") -> <shunt>;
+
+		r3: * -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].Env != "staging" || r[1].Env != "prod" || r[2].Env != "" {
+		t.Errorf("failed to parse the env: %s, %s, %s", r[0].Env, r[1].Env, r[2].Env)
+	}
+
+	if d := cmp.Diff(map[string]string{"team": "payments"}, r[0].Annotations); d != "" || r[1].Annotations != nil {
+		t.Error("invalid annotations")
+		t.Log(d)
+	}
+
+	t.Run("select", func(t *testing.T) {
+		if d := cmp.Diff([]string{"r1", "r3"}, routeIDs(SelectByEnv(r, "staging"))); d != "" {
+			t.Error(d)
+		}
+
+		if d := cmp.Diff([]string{"r3"}, routeIDs(SelectByEnv(r, "test"))); d != "" {
+			t.Error(d)
+		}
+
+		if !(AnnotationSelector{"env": "prod"}).Matches(r[1]) || (AnnotationSelector{"env": "prod"}).Matches(r[0]) {
+			t.Error("failed to select by the env annotation")
+		}
+	})
+
+	t.Run("round-trip", func(t *testing.T) {
+		s := String(r...)
+		const expect = "// @env=staging\n// @team=payments\n" +
+			`r1: Path("/debug") -> "https://debug.example.org";` + "\n" +
+			"// @env=prod\n" +
+			`r2: Path("/debug") -> <shunt>;` + "\n" +
+			`r3: * -> "https://www.example.org";`
+		if s != expect {
+			t.Errorf("invalid routes string, got: %s, expected: %s", s, expect)
+		}
+
+		rr, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff(r, rr); d != "" {
+			t.Error("failed to round-trip the env")
+			t.Log(d)
+		}
+
+		b, err := MarshalRoutesJSON(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rj, err := UnmarshalRoutesJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rj[0].Env != "staging" || rj[1].Env != "prod" {
+			t.Error("failed to round-trip the env through JSON")
+		}
+
+		if Copy(r[0]).Env != "staging" || Canonical(r[0]).Env != "staging" {
+			t.Error("failed to copy the env")
+		}
+	})
+}
+
+func TestDisabledAnnotation(t *testing.T) {
+	r, err := Parse(`
+		// @disabled: migrating to v2
+		r1: Path("/v1") -> "https://v1.example.org";
+
+		// @disabled
+		// @team=payments
+		r2: Path("/legacy") -> <shunt>;
+
+		// @disabled=deprecated
+		r3: Path("/old") -> <shunt>;
+
+		r4: * -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r[0].Disabled || !r[1].Disabled || !r[2].Disabled || r[3].Disabled {
+		t.Error("failed to parse the disabled routes")
+	}
+
+	if d := cmp.Diff(map[string]string{"team": "payments"}, r[1].Annotations); d != "" || r[0].Annotations != nil {
+		t.Error("invalid annotations")
+		t.Log(d)
+	}
+
+	t.Run("select", func(t *testing.T) {
+		if d := cmp.Diff([]string{"r4"}, routeIDs(ActiveRoutes(r))); d != "" {
+			t.Error(d)
+		}
+
+		if d := cmp.Diff(map[string]string{
+			"r1": "migrating to v2",
+			"r2": "",
+			"r3": "deprecated",
+		}, DisabledRoutes(r)); d != "" {
+			t.Error(d)
+		}
+
+		if !(AnnotationSelector{"disabled": ""}).Matches(r[1]) || (AnnotationSelector{"disabled": ""}).Matches(r[3]) {
+			t.Error("failed to select by the disabled annotation")
+		}
+	})
+
+	t.Run("round-trip", func(t *testing.T) {
+		s := String(r...)
+		const expect = "// @disabled: migrating to v2\n" +
+			`r1: Path("/v1") -> "https://v1.example.org";` + "\n" +
+			"// @disabled\n// @team=payments\n" +
+			`r2: Path("/legacy") -> <shunt>;` + "\n" +
+			"// @disabled: deprecated\n" +
+			`r3: Path("/old") -> <shunt>;` + "\n" +
+			`r4: * -> "https://www.example.org";`
+		if s != expect {
+			t.Errorf("invalid routes string, got: %s, expected: %s", s, expect)
+		}
+
+		rr, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff(r, rr); d != "" {
+			t.Error("failed to round-trip the disabled routes")
+			t.Log(d)
+		}
+
+		b, err := MarshalRoutesJSON(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rj, err := UnmarshalRoutesJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff(DisabledRoutes(r), DisabledRoutes(rj)); d != "" {
+			t.Error("failed to round-trip the disabled routes through JSON")
+			t.Log(d)
+		}
+
+		if !Copy(r[0]).Disabled || Canonical(r[0]).DisabledReason != "migrating to v2" {
+			t.Error("failed to copy the disabled route")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package eskip
+
+import "fmt"
+
+// ArgKind tells the type of a predicate or filter argument stored in the
+// Args field.
+type ArgKind int
+
+const (
+	// ArgUnknown is returned for arguments of types not known to eskip.
+	ArgUnknown ArgKind = iota
+
+	// ArgString is the kind of string arguments, including regular
+	// expressions.
+	ArgString
+
+	// ArgNumber is the kind of numeric arguments, float64 or int.
+	ArgNumber
+
+	// ArgBool is the kind of boolean arguments.
+	ArgBool
+
+	// ArgArray is the kind of list arguments.
+	ArgArray
+
+	// ArgPredicate is the kind of predicate arguments, e.g. of Not().
+	ArgPredicate
+
+	// ArgBinary is the kind of binary arguments, []byte, e.g. from base64
+	// literals.
+	ArgBinary
+
+	// ArgDuration is the kind of duration arguments, Duration, from the
+	// duration literals.
+	ArgDuration
+
+	// ArgSize is the kind of size arguments, ByteSize, from the size
+	// literals.
+	ArgSize
+)
+
+// String returns the name of the argument kind.
+func (k ArgKind) String() string {
+	switch k {
+	case ArgString:
+		return "string"
+	case ArgNumber:
+		return "number"
+	case ArgBool:
+		return "bool"
+	case ArgArray:
+		return "array"
+	case ArgPredicate:
+		return "predicate"
+	case ArgBinary:
+		return "binary"
+	case ArgDuration:
+		return "duration"
+	case ArgSize:
+		return "size"
+	default:
+		return "unknown"
+	}
+}
+
+func argKind(a interface{}) ArgKind {
+	switch a.(type) {
+	case string:
+		return ArgString
+	case float64, int:
+		return ArgNumber
+	case bool:
+		return ArgBool
+	case []interface{}, []string:
+		return ArgArray
+	case *Predicate:
+		return ArgPredicate
+	case []byte:
+		return ArgBinary
+	case Duration:
+		return ArgDuration
+	case ByteSize:
+		return ArgSize
+	default:
+		return ArgUnknown
+	}
+}
+
+func argType(args []interface{}, i int) (ArgKind, error) {
+	if i < 0 || i >= len(args) {
+		return ArgUnknown, fmt.Errorf("argument index out of range: %d", i)
+	}
+
+	return argKind(args[i]), nil
+}
+
+func argTypes(args []interface{}) []ArgKind {
+	k := make([]ArgKind, len(args))
+	for i, a := range args {
+		k[i] = argKind(a)
+	}
+
+	return k
+}
+
+// ArgType returns the kind of the filter argument at index i. It returns an
+// error when the index is out of range.
+func (f *Filter) ArgType(i int) (ArgKind, error) {
+	return argType(f.Args, i)
+}
+
+// ArgTypes returns the kinds of all the filter arguments.
+func (f *Filter) ArgTypes() []ArgKind {
+	return argTypes(f.Args)
+}
+
+// ArgType returns the kind of the predicate argument at index i. It returns
+// an error when the index is out of range.
+func (p *Predicate) ArgType(i int) (ArgKind, error) {
+	return argType(p.Args, i)
+}
+
+// ArgTypes returns the kinds of all the predicate arguments.
+func (p *Predicate) ArgTypes() []ArgKind {
+	return argTypes(p.Args)
+}
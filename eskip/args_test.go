@@ -0,0 +1,106 @@
+package eskip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArgTypes(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		args   []interface{}
+		expect []ArgKind
+	}{{
+		title:  "no args",
+		expect: []ArgKind{},
+	}, {
+		title:  "string",
+		args:   []interface{}{"foo"},
+		expect: []ArgKind{ArgString},
+	}, {
+		title:  "numbers",
+		args:   []interface{}{3.14, 42},
+		expect: []ArgKind{ArgNumber, ArgNumber},
+	}, {
+		title:  "bool",
+		args:   []interface{}{true},
+		expect: []ArgKind{ArgBool},
+	}, {
+		title:  "array",
+		args:   []interface{}{[]interface{}{"foo", 42}, []string{"bar"}},
+		expect: []ArgKind{ArgArray, ArgArray},
+	}, {
+		title:  "predicate",
+		args:   []interface{}{&Predicate{Name: "Method", Args: []interface{}{"GET"}}},
+		expect: []ArgKind{ArgPredicate},
+	}, {
+		title:  "binary",
+		args:   []interface{}{[]byte("foo")},
+		expect: []ArgKind{ArgBinary},
+	}, {
+		title:  "duration",
+		args:   []interface{}{Duration(5 * time.Second)},
+		expect: []ArgKind{ArgDuration},
+	}, {
+		title:  "size",
+		args:   []interface{}{ByteSize(10 << 20)},
+		expect: []ArgKind{ArgSize},
+	}, {
+		title:  "unknown",
+		args:   []interface{}{struct{}{}, nil},
+		expect: []ArgKind{ArgUnknown, ArgUnknown},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			f := &Filter{Name: "foo", Args: test.args}
+			if d := cmp.Diff(test.expect, f.ArgTypes()); d != "" {
+				t.Errorf("invalid filter arg types: %s", d)
+			}
+
+			p := &Predicate{Name: "Foo", Args: test.args}
+			if d := cmp.Diff(test.expect, p.ArgTypes()); d != "" {
+				t.Errorf("invalid predicate arg types: %s", d)
+			}
+
+			for i, k := range test.expect {
+				fk, err := f.ArgType(i)
+				if err != nil || fk != k {
+					t.Errorf("invalid filter arg type at %d: %v, %v", i, fk, err)
+				}
+
+				pk, err := p.ArgType(i)
+				if err != nil || pk != k {
+					t.Errorf("invalid predicate arg type at %d: %v, %v", i, pk, err)
+				}
+			}
+		})
+	}
+}
+
+func TestArgTypeOutOfRange(t *testing.T) {
+	f := &Filter{Name: "foo", Args: []interface{}{"bar"}}
+	for _, i := range []int{-1, 1} {
+		if _, err := f.ArgType(i); err == nil {
+			t.Errorf("failed to fail for index %d", i)
+		}
+	}
+}
+
+func TestArgKindString(t *testing.T) {
+	for k, s := range map[ArgKind]string{
+		ArgUnknown:   "unknown",
+		ArgString:    "string",
+		ArgNumber:    "number",
+		ArgBool:      "bool",
+		ArgArray:     "array",
+		ArgPredicate: "predicate",
+		ArgBinary:    "binary",
+		ArgDuration:  "duration",
+		ArgSize:      "size",
+	} {
+		if k.String() != s {
+			t.Errorf("invalid kind name, got: %s, expected: %s", k.String(), s)
+		}
+	}
+}
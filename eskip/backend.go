@@ -0,0 +1,333 @@
+package eskip
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// replaces the host part of a backend address, when it matches from. When from
+// doesn't contain a port, only the hostname is compared, and the original port
+// is preserved.
+func rewriteHost(address, from, to string) (string, bool) {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return address, false
+	}
+
+	switch {
+	case u.Host == from:
+		u.Host = to
+	case u.Hostname() == from:
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(to, port)
+		} else {
+			u.Host = to
+		}
+	default:
+		return address, false
+	}
+
+	return u.String(), true
+}
+
+// RewriteBackendHost replaces the host of the network backends from the
+// value of from to the value of to, while keeping the scheme, the port and
+// the path of the backend address. When from doesn't contain a port, it
+// matches the hostname of the backend regardless of the port. Shunt,
+// loopback and dynamic routes are skipped. When lbEndpoints is true, the
+// endpoints of the load balanced routes are rewritten, too.
+//
+// The routes are modified in place, and the number of changed backend
+// addresses is returned.
+func RewriteBackendHost(routes []*Route, from, to string, lbEndpoints bool) int {
+	var n int
+	for _, r := range routes {
+		if r == nil || r.Shunt {
+			continue
+		}
+
+		switch r.BackendType {
+		case NetworkBackend:
+			if b, ok := rewriteHost(r.Backend, from, to); ok {
+				r.Backend = b
+				n++
+			}
+		case LBBackend:
+			if !lbEndpoints {
+				continue
+			}
+
+			for i, ep := range r.LBEndpoints {
+				if b, ok := rewriteHost(ep, from, to); ok {
+					r.LBEndpoints[i] = b
+					n++
+				}
+			}
+		}
+	}
+
+	return n
+}
+
+// normalizes a backend address by lowercasing the scheme and the host, and
+// stripping the default port of the scheme. Addresses that cannot be parsed
+// are returned unchanged.
+func normalizeBackendAddress(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return address
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" || port == defaultPorts[u.Scheme] {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+
+		u.Host = host
+	} else {
+		u.Host = net.JoinHostPort(host, port)
+	}
+
+	return u.String()
+}
+
+// NormalizeBackend returns a copy of the route with the network backend
+// address and the load balancer endpoints normalized: the scheme and the host
+// are lowercased, and the default ports of the http and https schemes are
+// stripped. When sortLBEndpoints is true, the load balancer endpoints are
+// sorted, which is useful for set-like comparison, otherwise their order is
+// preserved, as it may matter for some algorithms. Other fields of the route
+// are not copied deep.
+func NormalizeBackend(r *Route, sortLBEndpoints bool) *Route {
+	if r == nil {
+		return nil
+	}
+
+	c := *r
+	if c.Backend != "" {
+		c.Backend = normalizeBackendAddress(c.Backend)
+	}
+
+	if len(r.LBEndpoints) > 0 {
+		c.LBEndpoints = make([]string, len(r.LBEndpoints))
+		for i, ep := range r.LBEndpoints {
+			c.LBEndpoints[i] = normalizeBackendAddress(ep)
+		}
+
+		if sortLBEndpoints {
+			sort.Strings(c.LBEndpoints)
+		}
+	}
+
+	return &c
+}
+
+// IsDynamic tells whether the route has a dynamic backend, when the
+// backend address is set by a filter during processing the request.
+func (r *Route) IsDynamic() bool {
+	return !r.Shunt && r.BackendType == DynamicBackend
+}
+
+// IsLoopback tells whether the route has a loopback backend, when the
+// request is matched again against the routing table after the filters of
+// the route.
+func (r *Route) IsLoopback() bool {
+	return !r.Shunt && r.BackendType == LoopBackend
+}
+
+// PreLoopbackFilters returns the filters of a loopback route, that are
+// executed on the request before it is matched again against the routing
+// table, in the order of execution. Since the backend is always the last
+// element of a route, these are all the filters of the route. For the
+// routes without a loopback backend, it returns nil. The filters are not
+// copied.
+func (r *Route) PreLoopbackFilters() []*Filter {
+	if !r.IsLoopback() {
+		return nil
+	}
+
+	return r.Filters
+}
+
+func addressScheme(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return strings.ToLower(u.Scheme)
+}
+
+// returns the lowercase scheme of the network backend, or of the endpoints of
+// a load balanced backend, when they all have the same scheme. For other
+// backends, it returns false.
+func backendScheme(r *Route) (string, bool) {
+	if r.Shunt {
+		return "", false
+	}
+
+	switch r.BackendType {
+	case NetworkBackend:
+		s := addressScheme(r.Backend)
+		return s, s != ""
+	case LBBackend:
+		var scheme string
+		for i, ep := range r.LBEndpoints {
+			s := addressScheme(ep)
+			if s == "" || i > 0 && s != scheme {
+				return "", false
+			}
+
+			scheme = s
+		}
+
+		return scheme, scheme != ""
+	default:
+		return "", false
+	}
+}
+
+// FilterByBackendScheme returns the routes whose backend address has the
+// scheme, e.g. https. The scheme is compared case-insensitively. The load
+// balanced routes are returned when all their endpoints have the scheme,
+// while the shunt, loopback and dynamic routes are never returned.
+func FilterByBackendScheme(routes []*Route, scheme string) []*Route {
+	var result []*Route
+	for _, r := range routes {
+		if s, ok := backendScheme(r); ok && strings.EqualFold(s, scheme) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// BackendSchemeFilters implements the routing.PreProcessor interface. It
+// keeps the listed filters only in the routes whose backend has the scheme,
+// see FilterByBackendScheme(), and removes them from the other routes with a
+// network or load balanced backend. E.g. it can be used to keep the TLS
+// related filters only in the routes with an https backend. The shunt,
+// loopback and dynamic routes, and the load balanced routes with mixed
+// schemes, are not changed.
+type BackendSchemeFilters struct {
+	Scheme  string
+	Filters []string
+}
+
+// Do implements the interface routing.PreProcessor. It returns the routes
+// with the listed filters removed from the routes with other schemes. The
+// changed routes are shallow copies, and the input routes are not modified.
+func (bf *BackendSchemeFilters) Do(routes []*Route) []*Route {
+	if len(bf.Filters) == 0 {
+		return routes
+	}
+
+	names := make(map[string]bool)
+	for _, n := range bf.Filters {
+		names[n] = true
+	}
+
+	nextRoutes := make([]*Route, len(routes))
+	for i, r := range routes {
+		nextRoutes[i] = r
+		if s, ok := backendScheme(r); !ok || strings.EqualFold(s, bf.Scheme) {
+			continue
+		}
+
+		var filters []*Filter
+		for _, f := range r.Filters {
+			if !names[f.Name] {
+				filters = append(filters, f)
+			}
+		}
+
+		if len(filters) == len(r.Filters) {
+			continue
+		}
+
+		next := new(Route)
+		*next = *r
+		next.Filters = filters
+		nextRoutes[i] = next
+	}
+
+	return nextRoutes
+}
+
+// PartitionByBackendKind splits the routes by the kind of their backend:
+// the routes forwarding to network backends, including the load balanced
+// ones, and the routes with shunt, loopback and dynamic backends. The legacy
+// Shunt field takes precedence over the backend type. The order of the
+// routes is preserved in each partition.
+func PartitionByBackendKind(routes []*Route) (network, shunt, loopback, dynamic []*Route) {
+	for _, r := range routes {
+		if r.Shunt {
+			shunt = append(shunt, r)
+			continue
+		}
+
+		switch r.BackendType {
+		case ShuntBackend:
+			shunt = append(shunt, r)
+		case LoopBackend:
+			loopback = append(loopback, r)
+		case DynamicBackend:
+			dynamic = append(dynamic, r)
+		default:
+			network = append(network, r)
+		}
+	}
+
+	return
+}
+
+// CommonBackendSuffix returns the longest DNS suffix shared by the hosts of
+// the network backends of the routes, starting with a dot, e.g.
+// .internal.example.org for the backends https://a.internal.example.org and
+// https://b.internal.example.org:8443. Only whole labels are compared, and
+// the hosts are compared case-insensitively, without the port. The routes
+// with other backend types and the backends without a host are ignored. It
+// returns an empty string when the hosts don't share a suffix, or when
+// there are no network backends.
+func CommonBackendSuffix(routes []*Route) string {
+	var suffix []string
+	var found bool
+	for _, r := range routes {
+		if r.Shunt || r.BackendType != NetworkBackend {
+			continue
+		}
+
+		u, err := url.Parse(r.Backend)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		labels := strings.Split(strings.ToLower(u.Hostname()), ".")
+		if !found {
+			suffix, found = labels, true
+			continue
+		}
+
+		n := 0
+		for n < len(suffix) && n < len(labels) && suffix[len(suffix)-1-n] == labels[len(labels)-1-n] {
+			n++
+		}
+
+		suffix = suffix[len(suffix)-n:]
+	}
+
+	if len(suffix) == 0 {
+		return ""
+	}
+
+	return "." + strings.Join(suffix, ".")
+}
@@ -0,0 +1,348 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRewriteBackendHost(t *testing.T) {
+	for _, test := range []struct {
+		title       string
+		routes      string
+		from, to    string
+		lbEndpoints bool
+		expect      string
+		changed     int
+	}{{
+		title:  "no routes",
+		from:   "old.internal",
+		to:     "new.internal",
+		expect: "",
+	}, {
+		title:   "network backend, path and scheme preserved",
+		routes:  `r: * -> "https://old.internal/foo/bar"`,
+		from:    "old.internal",
+		to:      "new.internal",
+		expect:  `r: * -> "https://new.internal/foo/bar"`,
+		changed: 1,
+	}, {
+		title:   "port preserved when matching hostname only",
+		routes:  `r: * -> "http://old.internal:9090"`,
+		from:    "old.internal",
+		to:      "new.internal",
+		expect:  `r: * -> "http://new.internal:9090"`,
+		changed: 1,
+	}, {
+		title:   "host with port",
+		routes:  `r: * -> "http://old.internal:9090"`,
+		from:    "old.internal:9090",
+		to:      "new.internal:8080",
+		expect:  `r: * -> "http://new.internal:8080"`,
+		changed: 1,
+	}, {
+		title:  "host with different port doesn't match",
+		routes: `r: * -> "http://old.internal:9090"`,
+		from:   "old.internal:8080",
+		to:     "new.internal",
+		expect: `r: * -> "http://old.internal:9090"`,
+	}, {
+		title:  "partial host doesn't match",
+		routes: `r: * -> "http://api.old.internal"`,
+		from:   "old.internal",
+		to:     "new.internal",
+		expect: `r: * -> "http://api.old.internal"`,
+	}, {
+		title: "shunt, loopback and dynamic skipped",
+		routes: `
+			r1: * -> <shunt>;
+			r2: * -> <loopback>;
+			r3: * -> <dynamic>;
+			r4: * -> "http://old.internal";
+		`,
+		from: "old.internal",
+		to:   "new.internal",
+		expect: `
+			r1: * -> <shunt>;
+			r2: * -> <loopback>;
+			r3: * -> <dynamic>;
+			r4: * -> "http://new.internal";
+		`,
+		changed: 1,
+	}, {
+		title:  "lb endpoints skipped",
+		routes: `r: * -> <"http://old.internal:9090", "http://other.internal:9090">`,
+		from:   "old.internal",
+		to:     "new.internal",
+		expect: `r: * -> <"http://old.internal:9090", "http://other.internal:9090">`,
+	}, {
+		title:       "lb endpoints rewritten",
+		routes:      `r: * -> <"http://old.internal:9090", "http://other.internal:9090">`,
+		from:        "old.internal",
+		to:          "new.internal",
+		lbEndpoints: true,
+		expect:      `r: * -> <"http://new.internal:9090", "http://other.internal:9090">`,
+		changed:     1,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			routes, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changed := RewriteBackendHost(routes, test.from, test.to, test.lbEndpoints)
+			if changed != test.changed {
+				t.Errorf("invalid number of changes, got: %d, expected: %d", changed, test.changed)
+			}
+
+			if !EqLists(routes, expect) {
+				t.Error("failed to rewrite backend host")
+				t.Log(cmp.Diff(String(expect...), String(routes...)))
+			}
+		})
+	}
+}
+
+func TestNormalizeBackend(t *testing.T) {
+	for _, test := range []struct {
+		title           string
+		route           *Route
+		sortLBEndpoints bool
+		expect          *Route
+	}{{
+		title: "nil",
+	}, {
+		title:  "network backend",
+		route:  &Route{Backend: "HTTPS://API.Example.org:443/foo"},
+		expect: &Route{Backend: "https://api.example.org/foo"},
+	}, {
+		title:  "network backend with non-default port",
+		route:  &Route{Backend: "http://api.example.org:8080"},
+		expect: &Route{Backend: "http://api.example.org:8080"},
+	}, {
+		title:  "ipv6 backend",
+		route:  &Route{Backend: "http://[::1]:80"},
+		expect: &Route{Backend: "http://[::1]"},
+	}, {
+		title: "lb endpoints, order preserved",
+		route: &Route{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://b.example.org:80", "HTTP://A.example.org:9090"},
+		},
+		expect: &Route{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://b.example.org", "http://a.example.org:9090"},
+		},
+	}, {
+		title: "lb endpoints, sorted",
+		route: &Route{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://b.example.org:80", "HTTP://A.example.org:9090"},
+		},
+		sortLBEndpoints: true,
+		expect: &Route{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://a.example.org:9090", "http://b.example.org"},
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var original *Route
+			if test.route != nil {
+				original = test.route.Copy()
+			}
+
+			n := NormalizeBackend(test.route, test.sortLBEndpoints)
+			if d := cmp.Diff(test.expect, n); d != "" {
+				t.Error("failed to normalize backend")
+				t.Log(d)
+			}
+
+			if d := cmp.Diff(original, test.route); d != "" {
+				t.Error("the original route was modified")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestBackendScheme(t *testing.T) {
+	routes := []*Route{
+		{Id: "https", BackendType: NetworkBackend, Backend: "HTTPS://www.example.org"},
+		{Id: "http", BackendType: NetworkBackend, Backend: "http://www.example.org"},
+		{Id: "lbHTTPS", BackendType: LBBackend, LBEndpoints: []string{"https://a.example.org", "https://b.example.org"}},
+		{Id: "lbMixed", BackendType: LBBackend, LBEndpoints: []string{"https://a.example.org", "http://b.example.org"}},
+		{Id: "shunt", BackendType: ShuntBackend},
+		{Id: "legacyShunt", BackendType: NetworkBackend, Backend: "https://www.example.org", Shunt: true},
+		{Id: "loopback", BackendType: LoopBackend},
+		{Id: "dynamic", BackendType: DynamicBackend},
+	}
+
+	for _, r := range routes {
+		r.Filters = []*Filter{{Name: "setPath", Args: []interface{}{"/"}}, {Name: "tlsFoo"}, {Name: "status", Args: []interface{}{float64(200)}}}
+	}
+
+	t.Run("filter by scheme", func(t *testing.T) {
+		if d := cmp.Diff([]string{"https", "lbHTTPS"}, routeIDs(FilterByBackendScheme(routes, "https"))); d != "" {
+			t.Error(d)
+		}
+
+		if d := cmp.Diff([]string{"http"}, routeIDs(FilterByBackendScheme(routes, "HTTP"))); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("scheme filters", func(t *testing.T) {
+		original := CopyRoutes(routes)
+		bf := &BackendSchemeFilters{Scheme: "https", Filters: []string{"tlsFoo"}}
+		result := bf.Do(routes)
+
+		var stripped []string
+		for i, r := range result {
+			if len(r.Filters) == 2 && r.Filters[0].Name == "setPath" && r.Filters[1].Name == "status" {
+				stripped = append(stripped, r.Id)
+			} else if r != routes[i] {
+				t.Errorf("failed to keep the route %s", r.Id)
+			}
+		}
+
+		if d := cmp.Diff([]string{"http"}, stripped); d != "" {
+			t.Error(d)
+		}
+
+		if !EqLists(original, routes) {
+			t.Error("the input routes were modified")
+		}
+	})
+}
+
+func TestPartitionByBackendKind(t *testing.T) {
+	r, err := Parse(`
+		network: * -> "https://www.example.org";
+		shunt: * -> <shunt>;
+		lb: * -> <"https://a.example.org", "https://b.example.org">;
+		loopback: * -> <loopback>;
+		dynamic: * -> <dynamic>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = append(
+		r,
+		&Route{Id: "legacyShunt", Shunt: true},
+		&Route{Id: "legacyShuntWithType", BackendType: ShuntBackend, Shunt: true},
+		&Route{Id: "shuntOverridesDynamic", BackendType: DynamicBackend, Shunt: true},
+	)
+
+	network, shunt, loopback, dynamic := PartitionByBackendKind(r)
+	if d := cmp.Diff([]string{"network", "lb"}, routeIDs(network)); d != "" {
+		t.Error(d)
+	}
+
+	if d := cmp.Diff([]string{"shunt", "legacyShunt", "legacyShuntWithType", "shuntOverridesDynamic"}, routeIDs(shunt)); d != "" {
+		t.Error(d)
+	}
+
+	if d := cmp.Diff([]string{"loopback"}, routeIDs(loopback)); d != "" {
+		t.Error(d)
+	}
+
+	if d := cmp.Diff([]string{"dynamic"}, routeIDs(dynamic)); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestCommonBackendSuffix(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect string
+	}{{
+		title: "no routes",
+	}, {
+		title: "shared suffix",
+		routes: `
+			r1: * -> "https://a.internal.example.org";
+			r2: * -> "https://B.Internal.example.org:8443/foo";
+			r3: * -> "https://c.d.internal.example.org";
+		`,
+		expect: ".internal.example.org",
+	}, {
+		title: "whole labels only",
+		routes: `
+			r1: * -> "https://foo-example.org";
+			r2: * -> "https://www.example.org";
+		`,
+		expect: ".org",
+	}, {
+		title: "no shared suffix",
+		routes: `
+			r1: * -> "https://www.example.org";
+			r2: * -> "https://www.example.com";
+		`,
+	}, {
+		title:  "single backend",
+		routes: `r1: * -> "https://www.example.org"`,
+		expect: ".www.example.org",
+	}, {
+		title: "other backends ignored",
+		routes: `
+			r1: * -> "https://a.example.org";
+			r2: * -> <shunt>;
+			r3: * -> <"https://www.example.com">;
+			r4: * -> <dynamic>;
+			r5: * -> "https://b.example.org";
+		`,
+		expect: ".example.org",
+	}, {
+		title:  "only other backends",
+		routes: `r1: * -> <shunt>; r2: * -> <loopback>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := CommonBackendSuffix(r); s != test.expect {
+				t.Errorf("invalid suffix, got: %q, expected: %q", s, test.expect)
+			}
+		})
+	}
+}
+
+func TestPreLoopbackFilters(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/foo") -> setPath("/bar") -> setRequestHeader("X-Loop", "1") -> <loopback>;
+		r2: Path("/bar") -> setPath("/baz") -> <shunt>;
+		r3: * -> <loopback>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r[0].IsLoopback() || r[1].IsLoopback() || !r[2].IsLoopback() {
+		t.Error("failed to detect loopback backends")
+	}
+
+	if (&Route{BackendType: LoopBackend, Shunt: true}).IsLoopback() {
+		t.Error("failed to prefer the legacy shunt")
+	}
+
+	if d := cmp.Diff([]string{"setPath", "setRequestHeader"}, filterNames(r[0].PreLoopbackFilters())); d != "" {
+		t.Error(d)
+	}
+
+	if f := r[1].PreLoopbackFilters(); f != nil {
+		t.Errorf("unexpected filters: %v", f)
+	}
+
+	if f := r[2].PreLoopbackFilters(); len(f) != 0 {
+		t.Errorf("unexpected filters: %v", f)
+	}
+}
@@ -0,0 +1,459 @@
+package eskip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The binary format starts with a magic prefix and a version byte, followed
+// by the number of routes and the routes. The strings and the lists are
+// prefixed by their length, the integers are varint encoded, and the maps
+// are encoded with their keys sorted. The args start with a type tag.
+const (
+	binaryMagic   = "eskb"
+	binaryVersion = 1
+)
+
+const (
+	argTagString byte = iota
+	argTagFloat
+	argTagInt
+	argTagBool
+	argTagBytes
+	argTagPredicate
+	argTagDuration
+	argTagByteSize
+)
+
+var invalidBinaryData = errors.New("invalid binary routing table")
+
+type binaryWriter struct {
+	buf []byte
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (w *binaryWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.tmp[:], v)
+	w.buf = append(w.buf, w.tmp[:n]...)
+}
+
+func (w *binaryWriter) varint(v int64) {
+	n := binary.PutVarint(w.tmp[:], v)
+	w.buf = append(w.buf, w.tmp[:n]...)
+}
+
+func (w *binaryWriter) bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *binaryWriter) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *binaryWriter) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *binaryWriter) strings(s []string) {
+	w.uvarint(uint64(len(s)))
+	for _, si := range s {
+		w.string(si)
+	}
+}
+
+func (w *binaryWriter) stringMap(m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	w.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		w.string(k)
+		w.string(m[k])
+	}
+}
+
+func (w *binaryWriter) multiMap(m map[string][]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	w.uvarint(uint64(len(keys)))
+	for _, k := range keys {
+		w.string(k)
+		w.strings(m[k])
+	}
+}
+
+func (w *binaryWriter) args(args []interface{}) error {
+	w.uvarint(uint64(len(args)))
+	for _, a := range args {
+		switch v := a.(type) {
+		case string:
+			w.buf = append(w.buf, argTagString)
+			w.string(v)
+		case float64:
+			w.buf = append(w.buf, argTagFloat)
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+			w.buf = append(w.buf, b[:]...)
+		case int:
+			w.buf = append(w.buf, argTagInt)
+			w.varint(int64(v))
+		case bool:
+			w.buf = append(w.buf, argTagBool)
+			w.bool(v)
+		case []byte:
+			w.buf = append(w.buf, argTagBytes)
+			w.bytes(v)
+		case *Predicate:
+			w.buf = append(w.buf, argTagPredicate)
+			if err := w.nameArgs(v.Name, v.Args); err != nil {
+				return err
+			}
+		case Duration:
+			w.buf = append(w.buf, argTagDuration)
+			w.varint(int64(v))
+		case ByteSize:
+			w.buf = append(w.buf, argTagByteSize)
+			w.varint(int64(v))
+		default:
+			return fmt.Errorf("unsupported arg type in the binary format: %T", a)
+		}
+	}
+
+	return nil
+}
+
+func (w *binaryWriter) nameArgs(name string, args []interface{}) error {
+	w.string(name)
+	return w.args(args)
+}
+
+func (w *binaryWriter) route(r *Route) error {
+	w.string(r.Id)
+	w.string(r.Path)
+	w.strings(r.HostRegexps)
+	w.strings(r.PathRegexps)
+	w.string(r.Method)
+	w.stringMap(r.Headers)
+	w.multiMap(r.HeaderRegexps)
+	w.strings(r.QueryParams)
+	w.multiMap(r.QueryParamRegexps)
+	w.multiMap(r.Cookies)
+
+	w.uvarint(uint64(len(r.Predicates)))
+	for _, p := range r.Predicates {
+		if err := w.nameArgs(p.Name, p.Args); err != nil {
+			return fmt.Errorf("failed to encode route %s: %w", r.Id, err)
+		}
+	}
+
+	w.uvarint(uint64(len(r.Filters)))
+	for _, f := range r.Filters {
+		if err := w.nameArgs(f.Name, f.Args); err != nil {
+			return fmt.Errorf("failed to encode route %s: %w", r.Id, err)
+		}
+
+		w.string(f.Comment)
+	}
+
+	w.bool(r.Shunt)
+	w.uvarint(uint64(r.BackendType))
+	w.string(r.Backend)
+	w.string(r.LBAlgorithm)
+	w.strings(r.LBEndpoints)
+	w.bool(r.Fallback)
+	w.strings(r.PredicateOrder)
+	w.stringMap(r.Annotations)
+	w.varint(int64(r.Order))
+	w.stringMap(r.BackendOptions)
+	w.string(r.Env)
+	w.string(r.RateLimit)
+	w.bool(r.Disabled)
+	w.string(r.DisabledReason)
+	w.string(r.Name)
+	w.string(r.Namespace)
+	return nil
+}
+
+// MarshalBinary serializes the routes into a compact binary format, that
+// is faster to decode than JSON, e.g. for caching large routing tables.
+// Every field of the routes is preserved, except that the empty and the nil
+// slices and maps are not distinguished. The supported arg types are
+// string, float64, int, bool, []byte, *Predicate, Duration and ByteSize. The
+// format is specific to this package and it may change between versions,
+// in which case UnmarshalBinary rejects the data of the other versions.
+func MarshalBinary(routes []*Route) ([]byte, error) {
+	w := &binaryWriter{}
+	w.buf = append(w.buf, binaryMagic...)
+	w.buf = append(w.buf, binaryVersion)
+	w.uvarint(uint64(len(routes)))
+	for _, r := range routes {
+		if err := w.route(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.buf, nil
+}
+
+// the decoder stops at the first error, and returns the zero values after
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) fail() {
+	if r.err == nil {
+		r.err = invalidBinaryData
+	}
+
+	r.data = nil
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *binaryReader) byte() byte {
+	if len(r.data) == 0 {
+		r.fail()
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *binaryReader) bool() bool {
+	return r.byte() != 0
+}
+
+// returns a length that cannot exceed the remaining data, as every item
+// takes at least one byte
+func (r *binaryReader) length() int {
+	n := r.uvarint()
+	if n > uint64(len(r.data)) {
+		r.fail()
+		return 0
+	}
+
+	return int(n)
+}
+
+func (r *binaryReader) raw() []byte {
+	n := r.length()
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *binaryReader) string() string {
+	return string(r.raw())
+}
+
+func (r *binaryReader) strings() []string {
+	n := r.length()
+	if n == 0 {
+		return nil
+	}
+
+	s := make([]string, n)
+	for i := range s {
+		s[i] = r.string()
+	}
+
+	return s
+}
+
+func (r *binaryReader) stringMap() map[string]string {
+	n := r.length()
+	if n == 0 {
+		return nil
+	}
+
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k := r.string()
+		m[k] = r.string()
+	}
+
+	return m
+}
+
+func (r *binaryReader) multiMap() map[string][]string {
+	n := r.length()
+	if n == 0 {
+		return nil
+	}
+
+	m := make(map[string][]string, n)
+	for i := 0; i < n; i++ {
+		k := r.string()
+		m[k] = r.strings()
+	}
+
+	return m
+}
+
+func (r *binaryReader) args() []interface{} {
+	n := r.length()
+	if n == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, n)
+	for i := range args {
+		switch r.byte() {
+		case argTagString:
+			args[i] = r.string()
+		case argTagFloat:
+			if len(r.data) < 8 {
+				r.fail()
+				return nil
+			}
+
+			args[i] = math.Float64frombits(binary.LittleEndian.Uint64(r.data))
+			r.data = r.data[8:]
+		case argTagInt:
+			args[i] = int(r.varint())
+		case argTagBool:
+			args[i] = r.bool()
+		case argTagBytes:
+			args[i] = append([]byte(nil), r.raw()...)
+		case argTagPredicate:
+			p := &Predicate{Name: r.string()}
+			p.Args = r.args()
+			args[i] = p
+		case argTagDuration:
+			args[i] = Duration(r.varint())
+		case argTagByteSize:
+			args[i] = ByteSize(r.varint())
+		default:
+			r.fail()
+		}
+
+		if r.err != nil {
+			return nil
+		}
+	}
+
+	return args
+}
+
+func (r *binaryReader) route() *Route {
+	rt := &Route{}
+	rt.Id = r.string()
+	rt.Path = r.string()
+	rt.HostRegexps = r.strings()
+	rt.PathRegexps = r.strings()
+	rt.Method = r.string()
+	rt.Headers = r.stringMap()
+	rt.HeaderRegexps = r.multiMap()
+	rt.QueryParams = r.strings()
+	rt.QueryParamRegexps = r.multiMap()
+	rt.Cookies = r.multiMap()
+
+	if n := r.length(); n > 0 {
+		rt.Predicates = make([]*Predicate, n)
+		for i := range rt.Predicates {
+			p := &Predicate{Name: r.string()}
+			p.Args = r.args()
+			rt.Predicates[i] = p
+		}
+	}
+
+	if n := r.length(); n > 0 {
+		rt.Filters = make([]*Filter, n)
+		for i := range rt.Filters {
+			f := &Filter{Name: r.string()}
+			f.Args = r.args()
+			f.Comment = r.string()
+			rt.Filters[i] = f
+		}
+	}
+
+	rt.Shunt = r.bool()
+	rt.BackendType = BackendType(r.uvarint())
+	rt.Backend = r.string()
+	rt.LBAlgorithm = r.string()
+	rt.LBEndpoints = r.strings()
+	rt.Fallback = r.bool()
+	rt.PredicateOrder = r.strings()
+	rt.Annotations = r.stringMap()
+	rt.Order = int(r.varint())
+	rt.BackendOptions = r.stringMap()
+	rt.Env = r.string()
+	rt.RateLimit = r.string()
+	rt.Disabled = r.bool()
+	rt.DisabledReason = r.string()
+	rt.Name = r.string()
+	rt.Namespace = r.string()
+	return rt
+}
+
+// UnmarshalBinary parses the routes from the binary format created by
+// MarshalBinary. It returns an error when the data is invalid, truncated,
+// or it was created by a different version of the format.
+func UnmarshalBinary(data []byte) ([]*Route, error) {
+	if len(data) < len(binaryMagic)+1 || string(data[:len(binaryMagic)]) != binaryMagic {
+		return nil, invalidBinaryData
+	}
+
+	if v := data[len(binaryMagic)]; v != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary routing table version: %d", v)
+	}
+
+	r := &binaryReader{data: data[len(binaryMagic)+1:]}
+	n := r.length()
+	if n == 0 {
+		return nil, r.err
+	}
+
+	routes := make([]*Route, n)
+	for i := range routes {
+		routes[i] = r.route()
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+
+	if len(r.data) > 0 {
+		return nil, invalidBinaryData
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,160 @@
+package eskip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	r, err := ParseWithOptions(`
+		// @team=gateway
+		// @order=3
+		// @env=staging
+		// @ratelimit=100/s
+		// @disabled: migrating to v2
+		// @backend-timeout=5s
+		r1: Path("/foo") && Host(/^www[.]example[.]org$/) && PathRegexp("^/foo") && Method("GET") &&
+			Header("X-Foo", "bar") && HeaderRegexp("X-Bar", /baz/) && QueryParam("page", /^[0-9]+$/) &&
+			Cookie("tcial", /^enabled$/) && Cookie("session", /^[0-9a-f]+$/) &&
+			Custom(3.14, -42, "qux") && Not(Custom2("quux")) && Fallback()
+			-> setRequestHeader("X-Foo", "bar") // a comment
+			-> inlineContent(b64"SGVsbG8=")
+			-> custom(5s, 10MB)
+			-> <roundRobin, "https://a.example.org", "https://b.example.org">;
+
+		r2: * -> <shunt>;
+		r3: * -> <loopback>;
+		r4: * -> "https://www.example.org";
+	`, ParseOptions{QueryParamFields: true, CookieFields: true, TypedLiterals: true, PredicateOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = append(r, &Route{
+		Id:          "r5",
+		Shunt:       true,
+		Filters:     []*Filter{{Name: "custom", Args: []interface{}{1, true, Duration(time.Second), ByteSize(1024)}}},
+		Name:        "foo",
+		Namespace:   "bar",
+		BackendType: DynamicBackend,
+	})
+
+	b, err := MarshalBinary(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := UnmarshalBinary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(r, rr); d != "" {
+		t.Error(d)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		b, err := MarshalBinary(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := UnmarshalBinary(b)
+		if err != nil || len(r) != 0 {
+			t.Errorf("failed to decode empty table: %v, %v", r, err)
+		}
+	})
+}
+
+func TestBinaryErrors(t *testing.T) {
+	r, err := Parse(`r1: Path("/foo") -> setPath("/bar") -> "https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := MarshalBinary(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("invalid prefix", func(t *testing.T) {
+		if _, err := UnmarshalBinary([]byte(`[{"id": "r1"}]`)); err != invalidBinaryData {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+	})
+
+	t.Run("other version", func(t *testing.T) {
+		bv := append([]byte(nil), b...)
+		bv[len(binaryMagic)] = 2
+		if _, err := UnmarshalBinary(bv); err == nil || err.Error() != "unsupported binary routing table version: 2" {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for i := len(binaryMagic) + 1; i < len(b); i++ {
+			if _, err := UnmarshalBinary(b[:i]); err != invalidBinaryData {
+				t.Fatalf("failed to fail at %d with the right error: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		if _, err := UnmarshalBinary(append(b, 0)); err != invalidBinaryData {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+	})
+
+	t.Run("unsupported arg", func(t *testing.T) {
+		_, err := MarshalBinary([]*Route{{Id: "r1", Filters: []*Filter{{Name: "foo", Args: []interface{}{struct{}{}}}}}})
+		if err == nil || err.Error() != "failed to encode route r1: unsupported arg type in the binary format: struct {}" {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+	})
+}
+
+func BenchmarkUnmarshalBinary(b *testing.B) {
+	r, err := Parse(largeRoutingDocument(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data, err := MarshalBinary(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(len(data)), "encoded-B")
+}
+
+func BenchmarkUnmarshalRoutesJSON(b *testing.B) {
+	r, err := Parse(largeRoutingDocument(10000))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data, err := MarshalRoutesJSON(r)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := UnmarshalRoutesJSON(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(len(data)), "encoded-B")
+}
@@ -0,0 +1,123 @@
+package eskip
+
+import "net/http"
+
+// the methods supported by the Methods predicate
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+func isMethodPredicate(p *Predicate) bool {
+	return p.Name == "Method" || p.Name == "Methods"
+}
+
+// returns the methods of the route when it has exactly one Method or Methods
+// predicate with only standard methods, and the key identifying the rest of
+// the route, including the annotations
+func coalesceKey(r *Route) (methods []string, key string, ok bool) {
+	c := Canonical(r)
+	var rest []*Predicate
+	var found int
+	for _, p := range c.Predicates {
+		if !isMethodPredicate(p) {
+			rest = append(rest, p)
+			continue
+		}
+
+		found++
+		for _, a := range p.Args {
+			m, isString := a.(string)
+			if !isString || !standardMethods[m] {
+				return nil, "", false
+			}
+
+			methods = append(methods, m)
+		}
+	}
+
+	if found != 1 || len(methods) == 0 {
+		return nil, "", false
+	}
+
+	c.Predicates = rest
+	c = formatRoute(c)
+	c.Id = ""
+	return methods, Print(PrettyPrintInfo{}, c), true
+}
+
+// Coalesce merges the routes that differ only in their Method or Methods
+// predicate into a single route with a Methods predicate, e.g. the routes
+// Path("/foo") && Method("GET") and Path("/foo") && Method("POST") with the
+// same filters and backend become Path("/foo") && Methods("GET", "POST").
+//
+// It is conservative: the routes are merged only when everything else,
+// the predicates, the filters, the backend and the annotations, are the
+// same, and when the methods are standard HTTP methods, as supported by the
+// Methods predicate. The merged route takes the ID and the position of the
+// first route of the group, and the unmerged routes are returned unchanged.
+// The input routes are not modified.
+func Coalesce(routes []*Route) []*Route {
+	type group struct {
+		routes  []*Route
+		methods []string
+	}
+
+	var result []*Route
+	groups := make(map[string]*group)
+	positions := make(map[*group]int)
+	for _, r := range routes {
+		methods, key, ok := coalesceKey(r)
+		if !ok {
+			result = append(result, r)
+			continue
+		}
+
+		g, ok := groups[key]
+		if !ok {
+			g = &group{}
+			groups[key] = g
+			positions[g] = len(result)
+			result = append(result, r)
+		}
+
+		g.routes = append(g.routes, r)
+		g.methods = append(g.methods, methods...)
+	}
+
+	for g, i := range positions {
+		if len(g.routes) == 1 {
+			continue
+		}
+
+		c := Copy(g.routes[0])
+		var p []*Predicate
+		for _, pi := range c.Predicates {
+			if !isMethodPredicate(pi) {
+				p = append(p, pi)
+			}
+		}
+
+		var args []interface{}
+		seen := make(map[string]bool)
+		for _, m := range g.methods {
+			if !seen[m] {
+				seen[m] = true
+				args = append(args, m)
+			}
+		}
+
+		c.Predicates = append(p, &Predicate{Name: "Methods", Args: args})
+		c.PredicateOrder = nil
+		result[i] = c
+	}
+
+	return result
+}
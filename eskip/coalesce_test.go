@@ -0,0 +1,104 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCoalesce(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect string
+	}{{
+		title: "no routes",
+	}, {
+		title: "methods merged",
+		routes: `
+			get: Path("/foo") && Method("GET") -> setPath("/bar") -> "https://www.example.org";
+			other: Path("/bar") -> <shunt>;
+			post: Method("POST") && Path("/foo") -> setPath("/bar") -> "https://www.example.org";
+			put: Path("/foo") && Methods("PUT", "GET") -> setPath("/bar") -> "https://www.example.org";
+		`,
+		expect: `
+			get: Path("/foo") && Methods("GET", "POST", "PUT") -> setPath("/bar") -> "https://www.example.org";
+			other: Path("/bar") -> <shunt>;
+		`,
+	}, {
+		title: "different filters, backends or predicates",
+		routes: `
+			r1: Path("/foo") && Method("GET") -> setPath("/bar") -> "https://www.example.org";
+			r2: Path("/foo") && Method("POST") -> setPath("/baz") -> "https://www.example.org";
+			r3: Path("/foo") && Method("PUT") -> setPath("/bar") -> "https://api.example.org";
+			r4: Path("/foo") && Header("X-Foo", "bar") && Method("PATCH") -> setPath("/bar") -> "https://www.example.org";
+		`,
+		expect: `
+			r1: Path("/foo") && Method("GET") -> setPath("/bar") -> "https://www.example.org";
+			r2: Path("/foo") && Method("POST") -> setPath("/baz") -> "https://www.example.org";
+			r3: Path("/foo") && Method("PUT") -> setPath("/bar") -> "https://api.example.org";
+			r4: Path("/foo") && Header("X-Foo", "bar") && Method("PATCH") -> setPath("/bar") -> "https://www.example.org";
+		`,
+	}, {
+		title: "different annotations",
+		routes: `
+			// @owner=foo
+			r1: Method("GET") -> <shunt>;
+			r2: Method("POST") -> <shunt>;
+		`,
+		expect: `
+			// @owner=foo
+			r1: Method("GET") -> <shunt>;
+			r2: Method("POST") -> <shunt>;
+		`,
+	}, {
+		title: "non-standard methods",
+		routes: `
+			r1: Method("GET") -> <shunt>;
+			r2: Method("PURGE") -> <shunt>;
+			r3: Method("get") -> <shunt>;
+		`,
+		expect: `
+			r1: Method("GET") -> <shunt>;
+			r2: Method("PURGE") -> <shunt>;
+			r3: Method("get") -> <shunt>;
+		`,
+	}, {
+		title: "both method and methods",
+		routes: `
+			r1: Method("GET") && Methods("GET", "POST") -> <shunt>;
+			r2: Method("POST") -> <shunt>;
+		`,
+		expect: `
+			r1: Method("GET") && Methods("GET", "POST") -> <shunt>;
+			r2: Method("POST") -> <shunt>;
+		`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := CopyRoutes(r)
+			result := Coalesce(r)
+			if !EqLists(expect, result) {
+				t.Errorf("invalid result, got: %s, expected: %s", Print(PrettyPrintInfo{}, result...), test.expect)
+			}
+
+			if d := cmp.Diff(routeIDs(expect), routeIDs(result)); d != "" {
+				t.Error("invalid order of the routes")
+				t.Log(d)
+			}
+
+			if !EqLists(original, r) {
+				t.Error("the input routes were modified")
+			}
+		})
+	}
+}
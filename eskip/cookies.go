@@ -0,0 +1,39 @@
+package eskip
+
+import "sort"
+
+// CookiePredicates returns the Cookie predicates, that are stored in the
+// Cookies field of the route, in a stable order: sorted by the cookie name,
+// and for the same name, sorted by the regular expression. The predicates in
+// the Predicates field of the route are not included.
+func CookiePredicates(r *Route) []*Predicate {
+	var p []*Predicate
+	for _, k := range sortedKeys(r.Cookies) {
+		values := copyStrings(r.Cookies[k])
+		sort.Strings(values)
+		for _, v := range values {
+			p = append(p, &Predicate{Name: "Cookie", Args: []interface{}{k, v}})
+		}
+	}
+
+	return p
+}
+
+func applyCookie(r *Route, args []interface{}, o ParseOptions) error {
+	sargs, err := getStringArgs(2, args)
+	if err != nil {
+		return invalidCookieArgsError
+	}
+
+	if !o.CookieFields {
+		r.Predicates = append(r.Predicates, &Predicate{Name: "Cookie", Args: args})
+		return nil
+	}
+
+	if r.Cookies == nil {
+		r.Cookies = make(map[string][]string)
+	}
+
+	r.Cookies[sargs[0]] = append(r.Cookies[sargs[0]], sargs[1])
+	return nil
+}
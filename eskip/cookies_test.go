@@ -0,0 +1,89 @@
+package eskip
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCookieFields(t *testing.T) {
+	const code = `Cookie("session", /^[0-9a-f]+$/) && Path("/foo") && Cookie("session", "^a") && Cookie("tcial", /^enabled$/) -> <shunt>`
+
+	r, err := Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].Cookies != nil || len(r[0].Predicates) != 3 {
+		t.Error("failed to keep the cookies in the predicates by default")
+	}
+
+	rf, err := ParseWithOptions(code, ParseOptions{CookieFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(map[string][]string{
+		"tcial":   {"^enabled$"},
+		"session": {"^[0-9a-f]+$", "^a"},
+	}, rf[0].Cookies); d != "" {
+		t.Error(d)
+	}
+
+	if len(rf[0].Predicates) != 0 {
+		t.Errorf("unexpected predicates: %v", rf[0].Predicates)
+	}
+
+	const expect = `Path("/foo") && Cookie("session", "^[0-9a-f]+$") && Cookie("session", "^a") && Cookie("tcial", "^enabled$") -> <shunt>`
+	if s := rf[0].String(); s != expect {
+		t.Errorf("invalid route string, got: %s, expected: %s", s, expect)
+	}
+
+	rr, err := ParseWithOptions(expect, ParseOptions{CookieFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(rf[0], rr[0]); d != "" {
+		t.Errorf("failed to round-trip the cookies: %s", d)
+	}
+
+	if !Eq(r[0], rf[0]) {
+		t.Error("the two representations are not equal")
+	}
+
+	b, err := json.Marshal(rf[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rj Route
+	if err := json.Unmarshal(b, &rj); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Eq(r[0], &rj) {
+		t.Errorf("failed to round-trip the cookies in JSON: %s", b)
+	}
+
+	c := rf[0].Copy()
+	c.Cookies["session"][0] = "^b"
+	if rf[0].Cookies["session"][0] != "^[0-9a-f]+$" {
+		t.Error("failed to copy the cookies")
+	}
+}
+
+func TestCookieArgs(t *testing.T) {
+	for _, code := range []string{
+		`Cookie("tcial") -> <shunt>`,
+		`Cookie("tcial", "a", "b") -> <shunt>`,
+		`Cookie(42, "^a") -> <shunt>`,
+	} {
+		for _, o := range []ParseOptions{{}, {CookieFields: true}} {
+			if _, err := ParseWithOptions(code, o); err == nil || err.Error() != "the Cookie predicate expects a cookie name and a regexp" {
+				t.Errorf("failed to fail with the right error: %s, %v", code, err)
+			}
+		}
+	}
+}
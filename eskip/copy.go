@@ -1,9 +1,20 @@
 package eskip
 
 func copyArgs(a []interface{}) []interface{} {
-	// we don't need deep copy of the items for the supported values
+	// we don't need deep copy of the items for the supported values,
+	// except for the nested predicates and the binary args
 	c := make([]interface{}, len(a))
-	copy(c, a)
+	for i, ai := range a {
+		switch v := ai.(type) {
+		case *Predicate:
+			c[i] = CopyPredicate(v)
+		case []byte:
+			c[i] = append([]byte(nil), v...)
+		default:
+			c[i] = ai
+		}
+	}
+
 	return c
 }
 
@@ -29,6 +40,29 @@ func CopyPredicates(p []*Predicate) []*Predicate {
 	return c
 }
 
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	c := make([]string, len(s))
+	copy(c, s)
+	return c
+}
+
+func copyAnnotations(a map[string]string) map[string]string {
+	if a == nil {
+		return nil
+	}
+
+	c := make(map[string]string, len(a))
+	for k, v := range a {
+		c[k] = v
+	}
+
+	return c
+}
+
 // CopyFilter creates a copy of the input filter.
 func CopyFilter(f *Filter) *Filter {
 	if f == nil {
@@ -38,6 +72,7 @@ func CopyFilter(f *Filter) *Filter {
 	c := &Filter{}
 	c.Name = f.Name
 	c.Args = copyArgs(f.Args)
+	c.Comment = f.Comment
 	return c
 }
 
@@ -62,6 +97,15 @@ func Copy(r *Route) *Route {
 	c.Id = r.Id
 	c.Predicates = CopyPredicates(r.Predicates)
 	c.Filters = CopyFilters(r.Filters)
+	c.Fallback = r.Fallback
+	c.PredicateOrder = copyStrings(r.PredicateOrder)
+	c.Annotations = copyAnnotations(r.Annotations)
+	c.Order = r.Order
+	c.BackendOptions = copyAnnotations(r.BackendOptions)
+	c.Env = r.Env
+	c.RateLimit = r.RateLimit
+	c.Disabled = r.Disabled
+	c.DisabledReason = r.DisabledReason
 	c.BackendType = r.BackendType
 	c.Backend = r.Backend
 	c.LBAlgorithm = r.LBAlgorithm
@@ -70,6 +114,35 @@ func Copy(r *Route) *Route {
 	return c
 }
 
+// WithoutMetadata returns a deep copy of the route without the metadata
+// that doesn't affect how the requests are matched and handled: the
+// comments of the filters, the annotations, the Order, Env, RateLimit,
+// Disabled and DisabledReason fields, the PredicateOrder, and the
+// deprecated Name and Namespace fields. The BackendOptions are kept. It
+// can be combined with Eq() or Canonical(), e.g. to find the routes that
+// have changed only their metadata, like the owner annotation.
+func (r *Route) WithoutMetadata() *Route {
+	if r == nil {
+		return nil
+	}
+
+	c := r.Copy()
+	for _, f := range c.Filters {
+		f.Comment = ""
+	}
+
+	c.Annotations = nil
+	c.Order = 0
+	c.Env = ""
+	c.RateLimit = ""
+	c.Disabled = false
+	c.DisabledReason = ""
+	c.PredicateOrder = nil
+	c.Name = ""
+	c.Namespace = ""
+	return c
+}
+
 // CopyRoutes creates a new slice with the canonical copy of each route in the input slice.
 func CopyRoutes(r []*Route) []*Route {
 	c := make([]*Route, len(r))
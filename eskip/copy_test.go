@@ -197,4 +197,68 @@ func TestCopy(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("binary args", func(t *testing.T) {
+		r := &Route{
+			Id:          "route1",
+			Filters:     []*Filter{{Name: "inlineContent", Args: []interface{}{[]byte("hello")}}},
+			BackendType: ShuntBackend,
+		}
+
+		for _, c := range []*Route{r.Copy(), Copy(r), r.WithoutMetadata()} {
+			r.Filters[0].Args[0].([]byte)[0] = 'j'
+			if string(c.Filters[0].Args[0].([]byte)) != "hello" {
+				t.Error("failed to copy binary arg")
+			}
+
+			r.Filters[0].Args[0].([]byte)[0] = 'h'
+		}
+	})
+}
+
+func TestWithoutMetadata(t *testing.T) {
+	r, err := ParseWithOptions(`
+		// @team=payments
+		// @order=3
+		// @env=staging
+		// @ratelimit=100/s
+		// @disabled: migrating to v2
+		// @backend-timeout=5s
+		r1: Method("GET") && Path("/foo")
+			-> setPath("/bar") // for the legacy clients
+			-> "https://www.example.org";
+
+		// @team=checkout
+		r2: Path("/foo") && Method("GET") -> setPath("/bar") -> "https://www.example.org";
+	`, ParseOptions{PredicateOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := r[0].WithoutMetadata()
+	const expect = "// @backend-timeout=5s\n" + `r1: Path("/foo") && Method("GET") -> setPath("/bar") -> "https://www.example.org";`
+	if s := Print(PrettyPrintInfo{}, c); s != expect {
+		t.Errorf("invalid route, got: %s, expected: %s", s, expect)
+	}
+
+	if c.Annotations != nil || c.Order != 0 || c.Env != "" || c.RateLimit != "" ||
+		c.Disabled || c.DisabledReason != "" || c.PredicateOrder != nil {
+		t.Errorf("failed to clear the metadata: %#v", c)
+	}
+
+	if r[0].Filters[0].Comment != "for the legacy clients" || r[0].Annotations["team"] != "payments" ||
+		!r[0].Disabled || len(r[0].PredicateOrder) != 2 {
+		t.Error("the original route was modified")
+	}
+
+	c2 := r[1].WithoutMetadata()
+	c2.Id = c.Id
+	c2.BackendOptions = c.BackendOptions
+	if !reflect.DeepEqual(Canonical(c), Canonical(c2)) {
+		t.Error("the routes differing only in their metadata are not equal without it")
+	}
+
+	if (*Route)(nil).WithoutMetadata() != nil {
+		t.Error("failed to handle nil")
+	}
 }
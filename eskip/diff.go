@@ -0,0 +1,254 @@
+package eskip
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FilterChange describes a changed arg of a filter between two versions of
+// a route.
+type FilterChange struct {
+	// RouteID is the ID of the new version of the route.
+	RouteID string
+
+	// Filter is the name of the filter.
+	Filter string
+
+	// Index is the position of the filter in the filter chain.
+	Index int
+
+	// Arg is the index of the changed arg.
+	Arg int
+
+	// Old and New are the values of the arg in the old and the new
+	// version. When the number of args changed, the missing values are
+	// nil.
+	Old, New interface{}
+}
+
+func changedArgString(a interface{}) string {
+	if a == nil {
+		return "<none>"
+	}
+
+	return argsString([]interface{}{a})
+}
+
+// String returns a human readable description of the change, e.g.
+// route r1: status arg[0] 200 → 418.
+func (c FilterChange) String() string {
+	return fmt.Sprintf(
+		"route %s: %s arg[%d] %s → %s",
+		c.RouteID, c.Filter, c.Arg, changedArgString(c.Old), changedArgString(c.New),
+	)
+}
+
+// FilterDiff returns the changed filter args between two versions of a
+// route. The filters are matched by their position and name, and the
+// filters at the positions where the names differ or that exist only in
+// one of the versions are not compared, they need to be reviewed with the
+// complete route. The changes are returned in the order of the filters and
+// the args.
+func FilterDiff(oldRoute, newRoute *Route) []FilterChange {
+	var changes []FilterChange
+	for i := 0; i < len(oldRoute.Filters) && i < len(newRoute.Filters); i++ {
+		of, nf := oldRoute.Filters[i], newRoute.Filters[i]
+		if of.Name != nf.Name {
+			continue
+		}
+
+		for j := 0; j < len(of.Args) || j < len(nf.Args); j++ {
+			var oa, na interface{}
+			if j < len(of.Args) {
+				oa = of.Args[j]
+			}
+
+			if j < len(nf.Args) {
+				na = nf.Args[j]
+			}
+
+			if j < len(of.Args) && j < len(nf.Args) && eqArg(oa, na) {
+				continue
+			}
+
+			changes = append(changes, FilterChange{
+				RouteID: newRoute.Id,
+				Filter:  nf.Name,
+				Index:   i,
+				Arg:     j,
+				Old:     oa,
+				New:     na,
+			})
+		}
+	}
+
+	return changes
+}
+
+// returns the filters of a that are not matched by a filter of b, in their
+// order. Each filter of b matches at most one filter of a.
+func filtersNotIn(a, b []*Filter, eq func(*Filter, *Filter) bool) []*Filter {
+	matched := make([]bool, len(b))
+	var only []*Filter
+	for _, fa := range a {
+		found := false
+		for i, fb := range b {
+			if !matched[i] && eq(fa, fb) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			only = append(only, fa)
+		}
+	}
+
+	return only
+}
+
+func filtersSetDiff(a, b *Route, eq func(*Filter, *Filter) bool) (onlyA, onlyB []*Filter) {
+	return filtersNotIn(a.Filters, b.Filters, eq), filtersNotIn(b.Filters, a.Filters, eq)
+}
+
+// FilterSetDiff returns the filters that only one of the routes has, e.g. to
+// verify that a canary or a shadow route carries the intended extra filters
+// and nothing more. The filters are compared by their name and args, and
+// their position is ignored. The repeated filters are counted, e.g. when a
+// has a filter twice, and b has it once, one of them is returned in onlyA.
+// The filters are returned in the order of the routes.
+func FilterSetDiff(a, b *Route) (onlyA, onlyB []*Filter) {
+	return filtersSetDiff(a, b, func(fa, fb *Filter) bool {
+		return fa.Name == fb.Name && eqArgs(fa.Args, fb.Args)
+	})
+}
+
+// FilterSetDiffByName returns the filters that only one of the routes has,
+// like FilterSetDiff, but it compares the filters only by their name, to
+// find which kinds of filters differ, regardless of their args.
+func FilterSetDiffByName(a, b *Route) (onlyA, onlyB []*Filter) {
+	return filtersSetDiff(a, b, func(fa, fb *Filter) bool {
+		return fa.Name == fb.Name
+	})
+}
+
+// returns the routes of a table by their ID, or an error when a route has
+// no ID or the IDs are not unique
+func patchTable(routes []*Route) (map[string]*Route, error) {
+	m := make(map[string]*Route, len(routes))
+	for _, r := range routes {
+		if r.Id == "" {
+			return nil, errors.New("route without an id in the routing table")
+		}
+
+		if _, ok := m[r.Id]; ok {
+			return nil, fmt.Errorf("duplicate route id in the routing table: %s", r.Id)
+		}
+
+		m[r.Id] = r
+	}
+
+	return m, nil
+}
+
+// the route in its canonical form, with annotations, as printed in a patch
+func patchRouteString(r *Route) string {
+	return Print(PrettyPrintInfo{}, formatRoute(r))
+}
+
+// the old version of a changed route, commented out line by line, with the
+// annotations without their comment markers, e.g. // - @team=api
+func oldVersionLines(r *Route) []string {
+	lines := strings.Split(patchRouteString(r), "\n")
+	for i, l := range lines {
+		lines[i] = "// - " + strings.TrimPrefix(l, "// ")
+	}
+
+	return lines
+}
+
+// TablePatch returns a human-readable description of the changes between
+// two versions of a routing table, e.g. for reviewing a change of the
+// configuration, where a textual diff would be noisy because of reordering.
+// The routes are compared by their ID, and in their canonical form, see
+// Format(), so the order of the routes and of their predicates doesn't
+// matter.
+//
+// The patch is an eskip document, with the sections of the added, the
+// changed and the removed routes, each sorted by the route IDs. The added
+// and the changed routes are route definitions in their new version, the
+// old version of the changed routes precedes them in comments, marked with
+// a -, and the IDs of the removed routes are in comments, too. Parsing the
+// patch returns the routes to be added or replaced. When there are no
+// changes, the patch is empty. It returns an error when a route of either
+// table has no ID, or the IDs are not unique.
+func TablePatch(oldRoutes, newRoutes []*Route) (patch string, err error) {
+	oldByID, err := patchTable(oldRoutes)
+	if err != nil {
+		return "", err
+	}
+
+	newByID, err := patchTable(newRoutes)
+	if err != nil {
+		return "", err
+	}
+
+	var added, changed, removed []string
+	for id, r := range newByID {
+		o, ok := oldByID[id]
+		switch {
+		case !ok:
+			added = append(added, id)
+		case patchRouteString(o) != patchRouteString(r):
+			changed = append(changed, id)
+		}
+	}
+
+	for id := range oldByID {
+		if _, ok := newByID[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	var sections []string
+	if len(added) > 0 {
+		s := []string{"// added"}
+		for _, id := range added {
+			s = append(s, patchRouteString(newByID[id]))
+		}
+
+		sections = append(sections, strings.Join(s, "\n"))
+	}
+
+	if len(changed) > 0 {
+		s := []string{"// changed"}
+		for _, id := range changed {
+			s = append(s, oldVersionLines(oldByID[id])...)
+			s = append(s, patchRouteString(newByID[id]))
+		}
+
+		sections = append(sections, strings.Join(s, "\n"))
+	}
+
+	if len(removed) > 0 {
+		s := []string{"// removed"}
+		for _, id := range removed {
+			s = append(s, "// "+id)
+		}
+
+		sections = append(sections, strings.Join(s, "\n"))
+	}
+
+	if len(sections) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(sections, "\n\n") + "\n", nil
+}
@@ -0,0 +1,227 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterDiff(t *testing.T) {
+	parse := func(code string) *Route {
+		r, err := Parse(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r[0]
+	}
+
+	for _, test := range []struct {
+		title  string
+		old    string
+		new    string
+		expect []string
+	}{{
+		title: "no changes",
+		old:   `r1: * -> setPath("/foo") -> status(200) -> <shunt>`,
+		new:   `r1: Path("/bar") -> setPath("/foo") -> status(200) -> "https://www.example.org"`,
+	}, {
+		title: "changed args",
+		old:   `r1: * -> setRequestHeader("X-Foo", "bar") -> status(200) -> <shunt>`,
+		new:   `r1: * -> setRequestHeader("X-Foo", "baz") -> status(418) -> <shunt>`,
+		expect: []string{
+			`route r1: setRequestHeader arg[1] "bar" → "baz"`,
+			"route r1: status arg[0] 200 → 418",
+		},
+	}, {
+		title: "changed types and arg counts",
+		old:   `r1: * -> custom(1, "2") -> ratelimit(20) -> <shunt>`,
+		new:   `r1: * -> custom("1", "2") -> ratelimit(20, "1m") -> <shunt>`,
+		expect: []string{
+			`route r1: custom arg[0] 1 → "1"`,
+			`route r1: ratelimit arg[1] <none> → "1m"`,
+		},
+	}, {
+		title: "different filters are not compared",
+		old:   `r1: * -> setPath("/foo") -> status(200) -> <shunt>`,
+		new:   `r1: * -> modPath("^/", "/foo") -> status(200) -> setPath("/bar") -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var changes []string
+			for _, c := range FilterDiff(parse(test.old), parse(test.new)) {
+				changes = append(changes, c.String())
+			}
+
+			if d := cmp.Diff(test.expect, changes); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+
+	t.Run("change fields", func(t *testing.T) {
+		c := FilterDiff(
+			parse(`r1: * -> setPath("/") -> status(200) -> <shunt>`),
+			parse(`r2: * -> setPath("/") -> status(418) -> <shunt>`),
+		)
+
+		if d := cmp.Diff([]FilterChange{{RouteID: "r2", Filter: "status", Index: 1, Arg: 0, Old: float64(200), New: float64(418)}}, c); d != "" {
+			t.Error(d)
+		}
+	})
+}
+
+func TestFilterSetDiff(t *testing.T) {
+	parse := func(code string) *Route {
+		r, err := Parse(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return r[0]
+	}
+
+	filterStrings := func(f []*Filter) []string {
+		var s []string
+		for _, fi := range f {
+			s = append(s, fi.String())
+		}
+
+		return s
+	}
+
+	for _, test := range []struct {
+		title                    string
+		a, b                     string
+		onlyA, onlyB             []string
+		onlyAByName, onlyBByName []string
+	}{{
+		title: "same filters in a different order",
+		a:     `* -> setPath("/") -> status(200) -> <shunt>`,
+		b:     `* -> status(200) -> setPath("/") -> <shunt>`,
+	}, {
+		title:       "extra filters",
+		a:           `* -> tee("https://shadow.example.org") -> setPath("/") -> <shunt>`,
+		b:           `* -> setPath("/") -> <shunt>`,
+		onlyA:       []string{`tee("https://shadow.example.org")`},
+		onlyAByName: []string{`tee("https://shadow.example.org")`},
+	}, {
+		title:       "different args",
+		a:           `* -> setRequestHeader("X-Canary", "true") -> setPath("/") -> <shunt>`,
+		b:           `* -> setRequestHeader("X-Canary", "false") -> setPath("/") -> status(200) -> <shunt>`,
+		onlyA:       []string{`setRequestHeader("X-Canary", "true")`},
+		onlyB:       []string{`setRequestHeader("X-Canary", "false")`, `status(200)`},
+		onlyBByName: []string{`status(200)`},
+	}, {
+		title:       "repeated filters",
+		a:           `* -> setRequestHeader("X-Foo", "1") -> setRequestHeader("X-Foo", "1") -> <shunt>`,
+		b:           `* -> setRequestHeader("X-Foo", "1") -> <shunt>`,
+		onlyA:       []string{`setRequestHeader("X-Foo", "1")`},
+		onlyAByName: []string{`setRequestHeader("X-Foo", "1")`},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			a, b := parse(test.a), parse(test.b)
+			onlyA, onlyB := FilterSetDiff(a, b)
+			if d := cmp.Diff(test.onlyA, filterStrings(onlyA)); d != "" {
+				t.Error(d)
+			}
+
+			if d := cmp.Diff(test.onlyB, filterStrings(onlyB)); d != "" {
+				t.Error(d)
+			}
+
+			onlyA, onlyB = FilterSetDiffByName(a, b)
+			if d := cmp.Diff(test.onlyAByName, filterStrings(onlyA)); d != "" {
+				t.Error(d)
+			}
+
+			if d := cmp.Diff(test.onlyBByName, filterStrings(onlyB)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestTablePatch(t *testing.T) {
+	old, err := Parse(`
+		// @team=api
+		// @owner=alice
+		r1: Path("/api") && Method("GET") -> "https://api.example.org";
+		r2: Path("/legacy") -> "https://legacy.example.org";
+		r3: Host(/^www[.]example[.]org$/) && Path("/") -> setPath("/index.html") -> "https://www.example.org";
+		r4: * -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	new, err := Parse(`
+		r4: * -> <shunt>;
+		r3: Path("/") && Host(/^www[.]example[.]org$/) -> setPath("/index.html") -> "https://www.example.org";
+		// @team=api
+		// @owner=alice
+		r1: Path("/api") && Method("GET") -> "https://api-v2.example.org";
+		r5: Path("/new") -> status(201) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		patch, err := TablePatch(old, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const expect = `// added
+r5: Path("/new") -> status(201) -> <shunt>;
+
+// changed
+// - @owner=alice
+// - @team=api
+// - r1: Path("/api") && Method("GET") -> "https://api.example.org";
+// @owner=alice
+// @team=api
+r1: Path("/api") && Method("GET") -> "https://api-v2.example.org";
+
+// removed
+// r2
+`
+		if patch != expect {
+			t.Errorf("invalid patch, got:\n%s\nexpected:\n%s", patch, expect)
+		}
+
+		upserts, err := Parse(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff([]string{"r5", "r1"}, routeIDs(upserts)); d != "" {
+			t.Error(d)
+		}
+
+		if d := cmp.Diff(map[string]string{"team": "api", "owner": "alice"}, upserts[1].Annotations); d != "" {
+			t.Error(d)
+		}
+
+		if upserts[0].Annotations != nil {
+			t.Errorf("the old version leaked annotations: %v", upserts[0].Annotations)
+		}
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		patch, err := TablePatch(old, old)
+		if err != nil || patch != "" {
+			t.Errorf("unexpected patch: %q, %v", patch, err)
+		}
+	})
+
+	t.Run("invalid tables", func(t *testing.T) {
+		if _, err := TablePatch(old, append(new, &Route{Id: "r5"})); err == nil {
+			t.Error("failed to fail on duplicate ids")
+		}
+
+		if _, err := TablePatch([]*Route{{}}, new); err == nil {
+			t.Error("failed to fail on a missing id")
+		}
+	})
+}
@@ -69,7 +69,10 @@ of "*". The simple wildcards behave similar to the Path predicate.
 
 The regexp path predicate accepts a regular expression as a single
 argument that needs to be matched by the request path. The regular
-expression can be surrounded by '/' or '"'.
+expression can be surrounded by '/' or '"'. When a route has multiple
+PathRegexp predicates, the request path needs to match all of them, e.g.
+PathRegexp("^/some") && PathRegexp(/\w+Id$/) matches /some/userId, but not
+/some/user or /other/userId.
 
 	Host(/host-regular-expression/)
 
@@ -91,6 +94,19 @@ header value to match.
 The header regexp predicate works similar to the header expression, but
 the value to be matched is a regular expression.
 
+	QueryParam("page", /^[0-9]+$/)
+
+The query param predicate matches the requests with the query param present,
+and, when the second argument is set, with a value matching the regular
+expression. When parsed with the QueryParamFields option, these predicates
+are stored in the QueryParams and QueryParamRegexps fields of the routes.
+
+	Cookie("tcial", /^enabled$/)
+
+The cookie predicate matches the requests with the cookie present, and with
+a value matching the regular expression. When parsed with the CookieFields
+option, these predicates are stored in the Cookies field of the routes.
+
 	*
 
 Catch all predicate.
@@ -99,6 +115,17 @@ Catch all predicate.
 
 Former, deprecated form of the catch all predicate.
 
+	Weight(50)
+
+Increases the priority of the route over the routes with the same
+predicates. The weight must be a non-negative integer.
+
+	Fallback()
+
+Marks the route as the fallback route of the routing table. It doesn't
+affect the matching of the route, and it is meant to be used together with
+the catch all predicate.
+
 
 Custom Predicates
 
@@ -112,6 +139,14 @@ During parsing, custom predicates may define any arbitrary list of
 arguments of types number, string or regular expression, and it is the
 responsibility of the implementation to validate them.
 
+	Not(Header("X-Foo", "bar"))
+
+The Not predicate wraps a single predicate, and it is parsed into a
+predicate named Not, with the wrapped predicate, as a *Predicate, being its
+only argument. Other predicates don't accept predicates as arguments. The
+evaluation of the Not predicate needs to be provided by an extension, like
+for the other custom predicates.
+
 (See the documentation of the routing package.)
 
 
@@ -121,7 +156,31 @@ Filters are used to augment the incoming requests and the outgoing
 responses, or do other useful or fun stuff. Filters can have different
 numbers of arguments depending on the implementation of the particular
 filter. The arguments can be of type string ("a string"), number
-(3.1415) or regular expression (/[.]html$/ or "[.]html$").
+(3.1415, -42, 0xFF or 1_000_000) or regular expression (/[.]html$/ or
+"[.]html$").
+
+The string arguments can be quoted with double quotes, single quotes or
+backticks, e.g. "foo", 'foo' or `foo`, and the printer can be configured to
+use either style, see QuoteStyle. They support the escape sequences of the
+delimiter, e.g. \" or \', and \\, \n, \t, \r, \a, \b, \f and \v, and the
+unicode escape sequences \u00e9 and \U0001F600, including the UTF-16
+surrogate pairs, e.g. \ud83d\ude00. When serializing the routes, the
+backslashes and the non-printable characters of the strings are escaped.
+The unknown escape sequences, e.g. \q, are kept with the backslash, unless
+the routes are parsed with the StrictEscapes option, when they are
+rejected.
+
+Binary arguments can be provided as base64 literals, e.g. b64"SGVsbG8=", that
+are decoded into []byte. In the JSON format, the binary arguments are
+represented as objects with a type marker: {"type": "base64", "value":
+"SGVsbG8="}.
+
+When parsed with the TypedLiterals option, the arguments can be duration
+literals, e.g. 5s, 100ms or 1m30s, in the format of time.ParseDuration, and
+size literals, e.g. 10MB or 2KiB, stored as Duration and ByteSize values.
+The supported size units are B, KB, MB, GB and TB, with a base of 1000, and
+KiB, MiB, GiB and TiB, with a base of 1024. In the JSON format, they are
+represented as strings.
 
 A filter example:
 
@@ -203,6 +262,9 @@ shunt:
 The shunt backend means that the route will not forward requests,
 but the router will handle requests itself. The default response in this
 case is 404 Not Found, unless a filter in the route changes it.
+The backend cannot be omitted, e.g. * -> status(404) is invalid, and with the
+SuggestShunt option, the parser reports these routes with an error suggesting
+the shunt backend.
 
 loopback:
 
@@ -214,7 +276,8 @@ to a network backend. When a new route returns, the filter chain of the original
 loop route is executed on the response, and the response is
 returned. The path parameters of the outer, looping, route are preserved for
 the inner route, but the path parameters of the inner route are discarded
-once it returns.
+once it returns. The filters of a loopback route are always executed before
+the request is looped back, see (*Route).PreLoopbackFilters().
 
 dynamic:
 
@@ -236,6 +299,76 @@ Example with comments:
 	route1: Path("/api") -> "https://api.example.org";
 	route2: * -> <shunt> // everything else 404
 
+A comment in the same line as a filter is kept as the comment of the filter,
+and it is printed again when serializing the route:
+
+	route3: * -> status(418) // added for incident-1234
+	  -> <shunt>
+
+Comments can be placed in their own lines, or at the end of the lines, also
+between the predicates and between the filters of a route:
+
+	route3b: Path("/foo")
+	  // only reads
+	  && Method("GET")
+	  // strips the prefix
+	  -> setPath("/")
+	  -> <shunt>
+
+The comments in their own line, preceding a route and starting with '@', are
+the annotations of the route. They contain arbitrary metadata in the form of
+key=value pairs, or only a key, stored in the Annotations field of the route.
+The annotations don't affect the route matching:
+
+	// @team=gateway
+	// @cost-center=1234
+	// @experimental
+	route4: Path("/beta") -> "https://beta.example.org";
+
+The @order annotation is stored in the Order field of the route, instead of
+the Annotations, and it needs to be an integer. It can be used to sort the
+routes in an explicit order, see SortByOrder:
+
+	// @order=10
+	route5: Path("/legacy") -> "https://legacy.example.org";
+
+Similarly, the annotations with the backend- prefix are stored in the
+BackendOptions field of the route, without the prefix. They contain the
+options of the backend that are not implemented as filters, see
+ValidateBackendOptions:
+
+	// @backend-timeout=5s
+	route6: Path("/slow") -> <roundRobin, "https://a.example.org", "https://b.example.org">;
+
+The @env annotation is stored in the Env field of the route. It can be used
+to keep the routes of multiple environments in the same document, see
+SelectByEnv:
+
+	// @env=staging
+	route6b: Path("/debug") -> "https://debug.example.org";
+
+The @ratelimit annotation is stored in the RateLimit field of the route. It
+contains the intended rate limit of the route, in the form of the number of
+requests per a time unit or a duration, e.g. 100/s or 600/10m, that can be
+turned into a rate limit filter, see ApplyRateLimitFilters:
+
+	// @ratelimit=100/s
+	route6c: Path("/search") -> "https://search.example.org";
+
+The @disabled annotation marks the routes that are kept in the document, but
+are not meant to be used, with an optional reason after a colon, stored in
+the Disabled and DisabledReason fields of the route, see ActiveRoutes:
+
+	// @disabled: migrating to v2
+	route6d: Path("/v1") -> "https://v1.example.org";
+
+The routes with the @skip-preprocessing annotation are left untouched by
+the Editor and Clone preprocessors, and the routes with the @skip-clone
+annotation are not cloned by the Clone preprocessor:
+
+	// @skip-preprocessing
+	route7: Source("10.0.0.0/8") -> <shunt>;
+
 
 Regular expressions
 
@@ -258,6 +391,10 @@ eskip.Parse function. In case of grammar error, it returns an error with
 the approximate position of the invalid syntax element, otherwise it
 returns a list of structured, in-memory route definitions.
 
+The eskip.ParseFS function parses the routes from multiple files of a file
+system, e.g. a directory or a zip archive, annotating the routes with the
+path of their file.
+
 The eskip parser does not validate the routes against all semantic rules,
 e.g., whether a filter or a custom predicate implementation is available.
 This validation happens during processing the parsed definitions.
@@ -0,0 +1,93 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+type dotGraph struct {
+	b     strings.Builder
+	nodes map[string]string
+}
+
+// returns the ID of the node identified by key, and writes the node when it
+// is new
+func (g *dotGraph) node(key, label, shape string) string {
+	if id, ok := g.nodes[key]; ok {
+		return id
+	}
+
+	id := fmt.Sprintf("n%d", len(g.nodes))
+	g.nodes[key] = id
+	fmt.Fprintf(&g.b, "  %s [label=%s, shape=%s];\n", id, dotQuote(label), shape)
+	return id
+}
+
+func (g *dotGraph) edge(from, to, label, style string) {
+	fmt.Fprintf(&g.b, "  %s -> %s [label=%s, style=%s];\n", from, to, dotQuote(label), style)
+}
+
+func (g *dotGraph) backendNode(r *Route) string {
+	switch r.BackendType {
+	case ShuntBackend:
+		return g.node("shunt", "<shunt>", "octagon")
+	case DynamicBackend:
+		return g.node("dynamic", "<dynamic>", "box")
+	case LoopBackend:
+		return g.node("loopback:"+r.Id, "<loopback>", "diamond")
+	case LBBackend:
+		s := lbBackendString(r)
+		return g.node("lb:"+s, s, "box")
+	default:
+		return g.node("network:"+r.Backend, r.Backend, "box")
+	}
+}
+
+// ToDOT renders the routes as a Graphviz DOT digraph, e.g. for the
+// documentation of a routing table. The incoming requests are represented by
+// a single node, and the routes by the edges from it to the nodes of their
+// backends, labeled with the ID and the predicates of the route. The routes
+// with the same network or load balanced backend share the node, and so do
+// the routes with a shunt or a dynamic backend. Every loopback route has its
+// own node, with dashed edges to the backends of the routes that it may loop
+// back into, labeled with their IDs, as found by LoopbackGraph. The output is
+// stable for the same routes.
+func ToDOT(routes []*Route) string {
+	g := &dotGraph{nodes: make(map[string]string)}
+	g.b.WriteString("digraph routes {\n")
+	requests := g.node("requests", "requests", "circle")
+
+	canonical := make([]*Route, len(routes))
+	byID := make(map[string]*Route)
+	for i, r := range routes {
+		c := Canonical(r)
+		canonical[i] = c
+		if _, ok := byID[c.Id]; !ok {
+			byID[c.Id] = c
+		}
+
+		label := c.Id + "\n" + r.predicateString(PrettyPrintInfo{})
+		g.edge(requests, g.backendNode(c), strings.TrimPrefix(label, "\n"), "solid")
+	}
+
+	loopbacks := LoopbackGraph(routes)
+	for _, c := range canonical {
+		if c.BackendType != LoopBackend {
+			continue
+		}
+
+		from := g.backendNode(c)
+		for _, id := range loopbacks[c.Id] {
+			g.edge(from, g.backendNode(byID[id]), id, "dashed")
+		}
+	}
+
+	g.b.WriteString("}\n")
+	return g.b.String()
+}
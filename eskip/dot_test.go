@@ -0,0 +1,45 @@
+package eskip
+
+import "testing"
+
+func TestToDOT(t *testing.T) {
+	r, err := Parse(`
+		api: Path("/api") -> "https://api.example.org";
+		apiPost: Path("/api") && Method("POST") -> "https://api.example.org";
+		lb: Host(/^lb[.]example[.]org$/) -> <roundRobin, "https://a.example.org", "https://b.example.org">;
+		legacy: PathSubtree("/legacy") -> setPath("/api") -> <loopback>;
+		health: Path("/health") -> status(200) -> <shunt>;
+		notFound: * -> <shunt>;
+		dyn: Header("X-Target", "\"quoted\"") -> setDynamicBackendUrlFromHeader("X-Target") -> <dynamic>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `digraph routes {
+  n0 [label="requests", shape=circle];
+  n1 [label="https://api.example.org", shape=box];
+  n0 -> n1 [label="api\nPath(\"/api\")", style=solid];
+  n0 -> n1 [label="apiPost\nPath(\"/api\") && Method(\"POST\")", style=solid];
+  n2 [label="<roundRobin, \"https://a.example.org\", \"https://b.example.org\">", shape=box];
+  n0 -> n2 [label="lb\nHost(/^lb[.]example[.]org$/)", style=solid];
+  n3 [label="<loopback>", shape=diamond];
+  n0 -> n3 [label="legacy\nPathSubtree(\"/legacy\")", style=solid];
+  n4 [label="<shunt>", shape=octagon];
+  n0 -> n4 [label="health\nPath(\"/health\")", style=solid];
+  n0 -> n4 [label="notFound\n*", style=solid];
+  n5 [label="<dynamic>", shape=box];
+  n0 -> n5 [label="dyn\nHeader(\"X-Target\", \"\\\"quoted\\\"\")", style=solid];
+  n3 -> n1 [label="api", style=dashed];
+  n3 -> n4 [label="notFound", style=dashed];
+}
+`
+
+	if s := ToDOT(r); s != expect {
+		t.Errorf("invalid DOT output, got:\n%s\nexpected:\n%s", s, expect)
+	}
+
+	if s := ToDOT(nil); s != "digraph routes {\n  n0 [label=\"requests\", shape=circle];\n}\n" {
+		t.Errorf("invalid DOT output for no routes: %s", s)
+	}
+}
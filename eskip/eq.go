@@ -1,6 +1,10 @@
 package eskip
 
-import "sort"
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
 
 // used for sorting:
 func compareRouteID(r []*Route) func(int, int) bool {
@@ -26,13 +30,30 @@ func hasDuplicateID(r []*Route) bool {
 	return false
 }
 
+func eqArg(left, right interface{}) bool {
+	switch l := left.(type) {
+	case *Predicate:
+		r, ok := right.(*Predicate)
+		return ok && l.Name == r.Name && eqArgs(l.Args, r.Args)
+	case []byte:
+		r, ok := right.([]byte)
+		return ok && bytes.Equal(l, r)
+	default:
+		if _, ok := right.([]byte); ok {
+			return false
+		}
+
+		return left == right
+	}
+}
+
 func eqArgs(left, right []interface{}) bool {
 	if len(left) != len(right) {
 		return false
 	}
 
 	for i := range left {
-		if left[i] != right[i] {
+		if !eqArg(left[i], right[i]) {
 			return false
 		}
 	}
@@ -40,6 +61,13 @@ func eqArgs(left, right []interface{}) bool {
 	return true
 }
 
+// ArgsEqual compares the args of two filters or predicates with the same
+// semantics as Eq: the binary args are compared by their content, and the
+// predicate args, e.g. of Not(), by their name and args.
+func ArgsEqual(left, right []interface{}) bool {
+	return eqArgs(left, right)
+}
+
 func eqStrings(left, right []string) bool {
 	if len(left) != len(right) {
 		return false
@@ -54,8 +82,10 @@ func eqStrings(left, right []string) bool {
 	return true
 }
 
-func eq2(left, right *Route) bool {
-	lc, rc := Canonical(left), Canonical(right)
+func eq2(left, right *Route, o CanonicalOptions) bool {
+	o.KeepConvenienceFields = false
+	lc, rc := CanonicalWithOptions(left, o), CanonicalWithOptions(right, o)
+	lc, rc = NormalizeBackend(lc, true), NormalizeBackend(rc, true)
 
 	if left == nil && right == nil {
 		return true
@@ -91,6 +121,10 @@ func eq2(left, right *Route) bool {
 		}
 	}
 
+	if lc.Fallback != rc.Fallback {
+		return false
+	}
+
 	if lc.BackendType != rc.BackendType {
 		return false
 	}
@@ -110,13 +144,13 @@ func eq2(left, right *Route) bool {
 	return true
 }
 
-func eq2Lists(left, right []*Route) bool {
+func eq2Lists(left, right []*Route, o CanonicalOptions) bool {
 	if len(left) != len(right) {
 		return false
 	}
 
 	for i := range left {
-		if !eq2(left[i], right[i]) {
+		if !eq2(left[i], right[i], o) {
 			return false
 		}
 	}
@@ -132,13 +166,23 @@ func eq2Lists(left, right []*Route) bool {
 //
 // The Name and Namespace fields are ignored.
 //
+// The network backend addresses and the load balancer endpoints are
+// compared in their normalized form, see NormalizeBackend(), and the order of
+// the load balancer endpoints doesn't matter.
+//
 // If there are multiple methods, only the last one is considered, to
 // reproduce the route matching (even if how it works, may not be the
 // most expected in regard of the method predicates).
 //
 func Eq(r ...*Route) bool {
+	return EqWithOptions(CanonicalOptions{}, r...)
+}
+
+// EqWithOptions compares routes like Eq, using the canonical form created
+// with the provided options. The KeepConvenienceFields option is ignored.
+func EqWithOptions(o CanonicalOptions, r ...*Route) bool {
 	for i := 1; i < len(r); i++ {
-		if !eq2(r[i-1], r[i]) {
+		if !eq2(r[i-1], r[i], o) {
 			return false
 		}
 	}
@@ -152,6 +196,13 @@ func Eq(r ...*Route) bool {
 // the lists doesn't matter.
 //
 func EqLists(r ...[]*Route) bool {
+	return EqListsWithOptions(CanonicalOptions{}, r...)
+}
+
+// EqListsWithOptions compares lists of routes like EqLists, using the
+// canonical form created with the provided options. The
+// KeepConvenienceFields option is ignored.
+func EqListsWithOptions(o CanonicalOptions, r ...[]*Route) bool {
 	rc := make([][]*Route, len(r))
 	for i := range rc {
 		rc[i] = make([]*Route, len(r[i]))
@@ -163,7 +214,7 @@ func EqLists(r ...[]*Route) bool {
 	}
 
 	for i := 1; i < len(rc); i++ {
-		if !eq2Lists(rc[i-1], rc[i]) {
+		if !eq2Lists(rc[i-1], rc[i], o) {
 			return false
 		}
 	}
@@ -171,22 +222,93 @@ func EqLists(r ...[]*Route) bool {
 	return true
 }
 
-// Canonical returns the canonical representation of a route, that uses the
-// standard, non-legacy representation of the predicates and the backends.
-// Canonical creates a copy of the route, but doesn't necessarily creates a
-// copy of every field. See also Copy().
-//
-func Canonical(r *Route) *Route {
-	if r == nil {
-		return nil
+// CanonicalOptions can be used to control the optional behavior of
+// CanonicalWithOptions and CanonicalListWithOptions.
+type CanonicalOptions struct {
+	// KeepConvenienceFields tells to keep the predicates stored in the
+	// convenience fields, e.g. Path or Headers, in their fields, instead
+	// of expanding them into the generic Predicates. The fields are
+	// copied.
+	KeepConvenienceFields bool
+
+	// FilterArgNormalizers, when set, are applied to the string args of
+	// the filters at the specified positions, e.g. to ignore the
+	// insignificant whitespace in a JSON arg when comparing routes. The
+	// filters with normalized args are copied. By default, the args are
+	// kept as they are.
+	FilterArgNormalizers map[FilterArg]ArgNormalizer
+}
+
+// FilterArg identifies the position of an arg of a filter, by the name of
+// the filter and the index of the arg.
+type FilterArg struct {
+	Filter string
+	Index  int
+}
+
+// ArgNormalizer returns the normalized form of a string arg.
+type ArgNormalizer func(string) string
+
+// NormalizeJSONArg removes the insignificant whitespace from a JSON arg.
+// When the arg is not valid JSON, it is returned unchanged.
+func NormalizeJSONArg(s string) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, []byte(s)); err != nil {
+		return s
 	}
 
-	c := &Route{}
-	c.Id = r.Id
+	return b.String()
+}
 
-	c.Predicates = make([]*Predicate, len(r.Predicates))
-	copy(c.Predicates, r.Predicates)
+func normalizeFilterArgs(filters []*Filter, n map[FilterArg]ArgNormalizer) []*Filter {
+	if len(n) == 0 {
+		return filters
+	}
+
+	var normalized []*Filter
+	for i, f := range filters {
+		var args []interface{}
+		for j, a := range f.Args {
+			s, ok := a.(string)
+			if !ok {
+				continue
+			}
+
+			normalize, ok := n[FilterArg{Filter: f.Name, Index: j}]
+			if !ok {
+				continue
+			}
+
+			if args == nil {
+				args = make([]interface{}, len(f.Args))
+				copy(args, f.Args)
+			}
+
+			args[j] = normalize(s)
+		}
+
+		if args == nil {
+			continue
+		}
+
+		if normalized == nil {
+			normalized = make([]*Filter, len(filters))
+			copy(normalized, filters)
+		}
+
+		fc := *f
+		fc.Args = args
+		normalized[i] = &fc
+	}
 
+	if normalized == nil {
+		return filters
+	}
+
+	return normalized
+}
+
+func expandConvenienceFields(c, r *Route) {
 	// legacy path:
 	var hasPath bool
 	for _, p := range c.Predicates {
@@ -221,30 +343,95 @@ func Canonical(r *Route) *Route {
 		)
 	}
 
-	// legacy header:
-	for name, value := range r.Headers {
-		c.Predicates = append(
-			c.Predicates,
-			&Predicate{Name: "Header", Args: []interface{}{name, value}},
-		)
+	// legacy header and header regexp:
+	c.Predicates = append(c.Predicates, HeaderPredicates(r)...)
+
+	// query params:
+	c.Predicates = append(c.Predicates, QueryParamPredicates(r)...)
+
+	// cookies:
+	c.Predicates = append(c.Predicates, CookiePredicates(r)...)
+}
+
+func keepConvenienceFields(c, r *Route) {
+	c.Path = r.Path
+	c.HostRegexps = copyStrings(r.HostRegexps)
+	c.PathRegexps = copyStrings(r.PathRegexps)
+	c.Method = r.Method
+
+	if r.Headers != nil {
+		c.Headers = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			c.Headers[k] = v
+		}
 	}
 
-	// legacy header regexp:
-	for name, values := range r.HeaderRegexps {
-		for _, value := range values {
-			c.Predicates = append(
-				c.Predicates,
-				&Predicate{Name: "HeaderRegexp", Args: []interface{}{name, value}},
-			)
+	if r.HeaderRegexps != nil {
+		c.HeaderRegexps = make(map[string][]string, len(r.HeaderRegexps))
+		for k, v := range r.HeaderRegexps {
+			c.HeaderRegexps[k] = copyStrings(v)
 		}
 	}
 
+	c.QueryParams = copyStrings(r.QueryParams)
+	if r.QueryParamRegexps != nil {
+		c.QueryParamRegexps = make(map[string][]string, len(r.QueryParamRegexps))
+		for k, v := range r.QueryParamRegexps {
+			c.QueryParamRegexps[k] = copyStrings(v)
+		}
+	}
+
+	if r.Cookies != nil {
+		c.Cookies = make(map[string][]string, len(r.Cookies))
+		for k, v := range r.Cookies {
+			c.Cookies[k] = copyStrings(v)
+		}
+	}
+}
+
+// Canonical returns the canonical representation of a route, that uses the
+// standard, non-legacy representation of the predicates and the backends.
+// Canonical creates a copy of the route, but doesn't necessarily creates a
+// copy of every field. See also Copy().
+//
+func Canonical(r *Route) *Route {
+	return CanonicalWithOptions(r, CanonicalOptions{})
+}
+
+// CanonicalWithOptions returns the canonical representation of a route, like
+// Canonical, applying the provided options.
+func CanonicalWithOptions(r *Route, o CanonicalOptions) *Route {
+	if r == nil {
+		return nil
+	}
+
+	c := &Route{}
+	c.Id = r.Id
+
+	c.Predicates = make([]*Predicate, len(r.Predicates))
+	copy(c.Predicates, r.Predicates)
+
+	if o.KeepConvenienceFields {
+		keepConvenienceFields(c, r)
+	} else {
+		expandConvenienceFields(c, r)
+	}
+
 	if len(c.Predicates) == 0 {
 		c.Predicates = nil
 	}
 
 	sort.Slice(c.Predicates, comparePredicateName(c.Predicates))
-	c.Filters = r.Filters
+	c.Filters = normalizeFilterArgs(r.Filters, o.FilterArgNormalizers)
+	c.Fallback = r.Fallback
+	c.PredicateOrder = r.PredicateOrder
+	c.Annotations = r.Annotations
+	c.Order = r.Order
+	c.BackendOptions = r.BackendOptions
+	c.Env = r.Env
+	c.RateLimit = r.RateLimit
+	c.Disabled = r.Disabled
+	c.DisabledReason = r.DisabledReason
 
 	c.BackendType = r.BackendType
 	switch c.BackendType {
@@ -274,13 +461,19 @@ func Canonical(r *Route) *Route {
 // all copied. See more at CopyRoutes() and Canonical().
 //
 func CanonicalList(l []*Route) []*Route {
+	return CanonicalListWithOptions(l, CanonicalOptions{})
+}
+
+// CanonicalListWithOptions returns the canonical form of each route in the
+// list, like CanonicalList, applying the provided options.
+func CanonicalListWithOptions(l []*Route, o CanonicalOptions) []*Route {
 	if len(l) == 0 {
 		return nil
 	}
 
 	cl := make([]*Route, len(l))
 	for i := range l {
-		cl[i] = Canonical(l[i])
+		cl[i] = CanonicalWithOptions(l[i], o)
 	}
 
 	return cl
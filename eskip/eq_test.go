@@ -133,6 +133,25 @@ func TestEq(t *testing.T) {
 		title:  "3 eq",
 		routes: []*Route{{Id: "foo"}, {Id: "foo"}, {Id: "foo"}},
 		expect: true,
+	}, {
+		title: "eq lb endpoints in different textual forms",
+		routes: []*Route{{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"HTTP://Foo.example.org:80", "https://bar.example.org:443"},
+		}, {
+			BackendType: LBBackend,
+			LBEndpoints: []string{"https://bar.example.org", "http://foo.example.org"},
+		}},
+		expect: true,
+	}, {
+		title: "non-eq lb endpoints ports",
+		routes: []*Route{{
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://foo.example.org:8080"},
+		}, {
+			BackendType: LBBackend,
+			LBEndpoints: []string{"http://foo.example.org"},
+		}},
 	}} {
 		t.Run(test.title, func(t *testing.T) {
 			if Eq(test.routes...) != test.expect {
@@ -319,3 +338,122 @@ func TestCanonicalList(t *testing.T) {
 		})
 	}
 }
+
+func TestCanonicalListKeepConvenienceFields(t *testing.T) {
+	list := []*Route{{
+		Id:            "r1",
+		Path:          "/foo",
+		HostRegexps:   []string{"^www[.]example[.]org$"},
+		PathRegexps:   []string{"[.]html$"},
+		Method:        "GET",
+		Headers:       map[string]string{"X-Foo": "foo"},
+		HeaderRegexps: map[string][]string{"X-Bar": {"^bar"}},
+		Predicates:    []*Predicate{{Name: "Traffic", Args: []interface{}{.3}}},
+		Shunt:         true,
+		Name:          "foo",
+	}, {
+		Id:          "r2",
+		BackendType: LBBackend,
+		LBEndpoints: []string{"http://b.example.org", "http://a.example.org"},
+	}}
+
+	expect := []*Route{{
+		Id:            "r1",
+		Path:          "/foo",
+		HostRegexps:   []string{"^www[.]example[.]org$"},
+		PathRegexps:   []string{"[.]html$"},
+		Method:        "GET",
+		Headers:       map[string]string{"X-Foo": "foo"},
+		HeaderRegexps: map[string][]string{"X-Bar": {"^bar"}},
+		Predicates:    []*Predicate{{Name: "Traffic", Args: []interface{}{.3}}},
+		BackendType:   ShuntBackend,
+	}, {
+		Id:          "r2",
+		BackendType: LBBackend,
+		LBEndpoints: []string{"http://a.example.org", "http://b.example.org"},
+	}}
+
+	c := CanonicalListWithOptions(list, CanonicalOptions{KeepConvenienceFields: true})
+	if d := cmp.Diff(expect, c); d != "" {
+		t.Error("failed to canonicalize the routes keeping the convenience fields")
+		t.Log(d)
+	}
+
+	if !EqLists(list, c) {
+		t.Error("the canonical routes are not equal to the original ones")
+	}
+
+	c[0].Headers["X-Foo"] = "bar"
+	c[0].HostRegexps[0] = "bar"
+	if list[0].Headers["X-Foo"] != "foo" || list[0].HostRegexps[0] != "^www[.]example[.]org$" {
+		t.Error("failed to copy the convenience fields")
+	}
+
+	if d := cmp.Diff(CanonicalList(list), CanonicalListWithOptions(list, CanonicalOptions{})); d != "" {
+		t.Error("the default options changed the canonical form")
+		t.Log(d)
+	}
+}
+
+func TestEqFilterArgNormalizers(t *testing.T) {
+	r, err := Parse(`
+		compact: * -> setPath("/foo") -> inlineContent("{\"foo\":[1,2]}", "application/json") -> <shunt>;
+		spaced: * -> setPath("/foo") -> inlineContent("{ \"foo\": [1, 2] }", "application/json") -> <shunt>;
+		invalid: * -> setPath("/foo") -> inlineContent("{ foo }", "application/json") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ri := range r {
+		ri.Id = "r"
+	}
+
+	o := CanonicalOptions{
+		FilterArgNormalizers: map[FilterArg]ArgNormalizer{
+			{Filter: "inlineContent", Index: 0}: NormalizeJSONArg,
+		},
+	}
+
+	t.Run("byte comparison by default", func(t *testing.T) {
+		if Eq(r[0], r[1]) {
+			t.Error("unexpected equality")
+		}
+	})
+
+	t.Run("normalized", func(t *testing.T) {
+		if !EqWithOptions(o, r[0], r[1]) {
+			t.Error("failed to compare the normalized args")
+		}
+
+		if !EqListsWithOptions(o, r[:1], r[1:2]) {
+			t.Error("failed to compare the lists with the normalized args")
+		}
+	})
+
+	t.Run("invalid JSON kept", func(t *testing.T) {
+		if EqWithOptions(o, r[0], r[2]) {
+			t.Error("unexpected equality")
+		}
+
+		c := CanonicalWithOptions(r[2], o)
+		if c.Filters[1].Args[0] != "{ foo }" {
+			t.Error("failed to keep the invalid JSON arg")
+		}
+	})
+
+	t.Run("input not modified", func(t *testing.T) {
+		c := CanonicalWithOptions(r[1], o)
+		if c.Filters[1].Args[0] != `{"foo":[1,2]}` {
+			t.Error("failed to normalize the arg")
+		}
+
+		if r[1].Filters[1].Args[0] != `{ "foo": [1, 2] }` {
+			t.Error("the input route was modified")
+		}
+
+		if c.Filters[0] != r[1].Filters[0] {
+			t.Error("unexpected copy of the filter without normalized args")
+		}
+	})
+}
@@ -5,6 +5,9 @@ package eskip
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/textproto"
 	"net/url"
 	"regexp"
 	"strings"
@@ -13,13 +16,24 @@ import (
 	"github.com/zalando/skipper/filters/flowid"
 )
 
-const duplicateHeaderPredicateErrorFmt = "duplicate header predicate: %s"
+const (
+	duplicateHeaderPredicateErrorFmt = "duplicate header predicate: %s"
+	invalidWeightErrorFmt            = "invalid weight in route %s: %v, expected a non-negative integer"
+	nestedPredicateErrorFmt          = "nested predicate arg in %s, only supported by Not"
+	inputTooLargeErrorFmt            = "the input exceeds the size limit of %d bytes"
+)
 
 var (
+	invalidFallbackArgsError        = errors.New("the fallback predicate doesn't accept arguments")
 	invalidPredicateArgError        = errors.New("invalid predicate arg")
 	invalidPredicateArgCountError   = errors.New("invalid predicate count arg")
 	duplicatePathTreePredicateError = errors.New("duplicate path tree predicate")
 	duplicateMethodPredicateError   = errors.New("duplicate method predicate")
+	invalidNotArgsError             = errors.New("the Not predicate expects a single predicate arg")
+	invalidQueryParamArgsError      = errors.New("the QueryParam predicate expects a name and an optional regexp")
+	invalidCookieArgsError          = errors.New("the Cookie predicate expects a cookie name and a regexp")
+	invalidBackendError             = errors.New("invalid backend, expected a single backend expression")
+	negativeSizeLimitError          = errors.New("the size limit must not be negative")
 )
 
 // NewEditor creates an Editor PreProcessor, that matches routes and
@@ -42,6 +56,11 @@ func NewEditor(reg *regexp.Regexp, repl string) *Editor {
 type Editor struct {
 	reg  *regexp.Regexp
 	repl string
+
+	// Match, when set, restricts the editing to the routes with the
+	// matching annotations. The routes with the @skip-preprocessing
+	// annotation are never edited.
+	Match AnnotationSelector
 }
 
 // NewClone creates a Clone PreProcessor, that matches routes and
@@ -65,6 +84,31 @@ func NewClone(reg *regexp.Regexp, repl string) *Clone {
 type Clone struct {
 	reg  *regexp.Regexp
 	repl string
+
+	// Match, when set, restricts the cloning to the routes with the
+	// matching annotations. The routes with the @skip-preprocessing or
+	// @skip-clone annotations are never cloned.
+	Match AnnotationSelector
+
+	// TransformClone, when set, is called with every cloned route after
+	// the replacement was applied, and it can be used to adjust the
+	// clone, e.g. to add a filter, or to change its backend, so that the
+	// clone doesn't serve the same traffic as the original route.
+	TransformClone func(*Route)
+}
+
+// returns the edited copy of the route, and whether the editor changed it.
+// The input route is not modified.
+func (e *Editor) edit(r *Route) (*Route, bool) {
+	if !e.Match.Matches(r) || hasAnnotation(r, SkipPreprocessingAnnotation) {
+		return nil, false
+	}
+
+	rr := new(Route)
+	*rr = *r
+	rr = Canonical(rr)
+	rr.Filters = append([]*Filter(nil), rr.Filters...)
+	return rr, doOneRoute(e.reg, e.repl, rr)
 }
 
 func (e *Editor) Do(routes []*Route) []*Route {
@@ -73,11 +117,7 @@ func (e *Editor) Do(routes []*Route) []*Route {
 	}
 
 	for i, r := range routes {
-		rr := new(Route)
-		*rr = *r
-		rr = Canonical(rr)
-
-		if doOneRoute(e.reg, e.repl, rr) {
+		if rr, changed := e.edit(r); changed {
 			routes[i] = rr
 		}
 	}
@@ -85,6 +125,66 @@ func (e *Editor) Do(routes []*Route) []*Route {
 	return routes
 }
 
+// Preview returns the edited versions of the routes that the editor would
+// change, without modifying the input, e.g. to review a migration before
+// applying it. The routes that the editor would not change are not
+// returned.
+func (e *Editor) Preview(routes []*Route) []*Route {
+	if e.reg == nil {
+		return nil
+	}
+
+	var changed []*Route
+	for _, r := range routes {
+		if rr, ok := e.edit(r); ok {
+			changed = append(changed, rr)
+		}
+	}
+
+	return changed
+}
+
+// returns the clone of the route, and whether the replacement changed it,
+// with the TransformClone already applied. The input route is not modified.
+func (c *Clone) clone(r *Route) (*Route, bool) {
+	if !c.Match.Matches(r) ||
+		hasAnnotation(r, SkipPreprocessingAnnotation) ||
+		hasAnnotation(r, SkipCloneAnnotation) {
+		return nil, false
+	}
+
+	rr := new(Route)
+	*rr = *r
+	rr = Canonical(rr)
+
+	rr.Id = "clone_" + rr.Id
+	predicates := make([]*Predicate, len(r.Predicates))
+	for k, p := range r.Predicates {
+		q := *p
+		predicates[k] = &q
+	}
+	rr.Predicates = predicates
+
+	filters := make([]*Filter, len(r.Filters))
+	for k, f := range r.Filters {
+		ff := *f
+		filters[k] = &ff
+	}
+	rr.Filters = filters
+	rr.Annotations = copyAnnotations(r.Annotations)
+	rr.BackendOptions = copyAnnotations(r.BackendOptions)
+
+	if !doOneRoute(c.reg, c.repl, rr) {
+		return nil, false
+	}
+
+	if c.TransformClone != nil {
+		c.TransformClone(rr)
+	}
+
+	return rr, true
+}
+
 func (c *Clone) Do(routes []*Route) []*Route {
 	if c.reg == nil {
 		return routes
@@ -93,31 +193,29 @@ func (c *Clone) Do(routes []*Route) []*Route {
 	result := make([]*Route, len(routes), 2*len(routes))
 	copy(result, routes)
 	for _, r := range routes {
-		rr := new(Route)
-		*rr = *r
-		rr = Canonical(rr)
-
-		rr.Id = "clone_" + rr.Id
-		predicates := make([]*Predicate, len(r.Predicates))
-		for k, p := range r.Predicates {
-			q := *p
-			predicates[k] = &q
+		if rr, ok := c.clone(r); ok {
+			result = append(result, rr)
 		}
-		rr.Predicates = predicates
+	}
 
-		filters := make([]*Filter, len(r.Filters))
-		for k, f := range r.Filters {
-			ff := *f
-			filters[k] = &ff
-		}
-		rr.Filters = filters
+	return result
+}
 
-		if doOneRoute(c.reg, c.repl, rr) {
-			result = append(result, rr)
+// Preview returns the clones that Do would add to the routes, without
+// modifying the input, e.g. to review a migration before applying it.
+func (c *Clone) Preview(routes []*Route) []*Route {
+	if c.reg == nil {
+		return nil
+	}
+
+	var clones []*Route
+	for _, r := range routes {
+		if rr, ok := c.clone(r); ok {
+			clones = append(clones, rr)
 		}
 	}
 
-	return result
+	return clones
 }
 
 func doOneRoute(rx *regexp.Regexp, repl string, r *Route) bool {
@@ -158,6 +256,7 @@ func doOneRoute(rx *regexp.Regexp, repl string, r *Route) bool {
 			continue
 		}
 
+		ff[0].Comment = f.Comment
 		r.Filters[i] = ff[0]
 		changed = true
 	}
@@ -170,6 +269,63 @@ func doOneRoute(rx *regexp.Regexp, repl string, r *Route) bool {
 type DefaultFilters struct {
 	Prepend []*Filter
 	Append  []*Filter
+
+	// PrependForBackendTypes, when set, restricts prepending the filters
+	// to the routes with the listed backend types. Empty means all the
+	// routes.
+	PrependForBackendTypes []BackendType
+
+	// AppendForBackendTypes, when set, restricts appending the filters
+	// to the routes with the listed backend types. Empty means all the
+	// routes.
+	AppendForBackendTypes []BackendType
+
+	// SkipIfPresent tells Do to skip prepending or appending a filter
+	// to the routes that already have a filter with the same name, so
+	// that applying the default filters repeatedly doesn't duplicate
+	// them.
+	SkipIfPresent bool
+}
+
+// returns the filters that are not present by name in the route
+func missingFilters(r *Route, filters []*Filter) []*Filter {
+	var missing []*Filter
+	for _, f := range filters {
+		var found bool
+		for _, rf := range r.Filters {
+			if rf.Name == f.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, f)
+		}
+	}
+
+	return missing
+}
+
+// tells whether the backend type of the route is one of the types, taking
+// the legacy Shunt field into account. Empty types match all routes.
+func hasBackendType(r *Route, types []BackendType) bool {
+	if len(types) == 0 {
+		return true
+	}
+
+	t := r.BackendType
+	if r.Shunt {
+		t = ShuntBackend
+	}
+
+	for _, ti := range types {
+		if ti == t {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Do implements the interface routing.PreProcessor. It appends and
@@ -184,22 +340,135 @@ func (df *DefaultFilters) Do(routes []*Route) []*Route {
 
 	nextRoutes := make([]*Route, len(routes))
 	for i, r := range routes {
-		nextRoutes[i] = new(Route)
-		*nextRoutes[i] = *r
+		prependFilters, appendFilters := df.Prepend, df.Append
+		if !hasBackendType(r, df.PrependForBackendTypes) {
+			prependFilters = nil
+		}
 
-		fn := len(r.Filters)
+		if !hasBackendType(r, df.AppendForBackendTypes) {
+			appendFilters = nil
+		}
 
-		filters := make([]*Filter, fn+pn+an)
-		copy(filters[:pn], df.Prepend)
-		copy(filters[pn:pn+fn], r.Filters)
-		copy(filters[pn+fn:], df.Append)
+		if df.SkipIfPresent {
+			prependFilters, appendFilters = missingFilters(r, prependFilters), missingFilters(r, appendFilters)
+		}
 
-		nextRoutes[i].Filters = filters
+		nextRoutes[i] = withDefaultFilters(r, prependFilters, appendFilters)
 	}
 
 	return nextRoutes
 }
 
+func withDefaultFilters(r *Route, prependFilters, appendFilters []*Filter) *Route {
+	pn := len(prependFilters)
+	an := len(appendFilters)
+	fn := len(r.Filters)
+
+	filters := make([]*Filter, fn+pn+an)
+	copy(filters[:pn], prependFilters)
+	copy(filters[pn:pn+fn], r.Filters)
+	copy(filters[pn+fn:], appendFilters)
+
+	next := new(Route)
+	*next = *r
+	next.Filters = filters
+	return next
+}
+
+// TargetedFilters implements the routing.PreProcessor interface, and it
+// works like DefaultFilters, but it prepends and appends the filters only
+// to the routes with the annotations matching the selector.
+type TargetedFilters struct {
+	Match   AnnotationSelector
+	Prepend []*Filter
+	Append  []*Filter
+}
+
+// Do implements the interface routing.PreProcessor. It appends and
+// prepends the filters to the matching routes, and returns the modified
+// version of the routes.
+func (tf *TargetedFilters) Do(routes []*Route) []*Route {
+	if len(tf.Prepend) == 0 && len(tf.Append) == 0 {
+		return routes
+	}
+
+	nextRoutes := make([]*Route, len(routes))
+	for i, r := range routes {
+		if tf.Match.Matches(r) {
+			nextRoutes[i] = withDefaultFilters(r, tf.Prepend, tf.Append)
+		} else {
+			nextRoutes[i] = r
+		}
+	}
+
+	return nextRoutes
+}
+
+// EnsureFilterWhereMissing appends a copy of the filter to the routes for
+// which missing returns true, e.g. to add an auth filter to the routes
+// without any auth predicate or filter. The routes that already have a
+// filter with the same name are skipped. The routes are modified in place,
+// and it returns the number of the routes that the filter was added to.
+func EnsureFilterWhereMissing(routes []*Route, f *Filter, missing func(*Route) bool) int {
+	var n int
+	for _, r := range routes {
+		if len(missingFilters(r, []*Filter{f})) == 0 || !missing(r) {
+			continue
+		}
+
+		filters := make([]*Filter, len(r.Filters), len(r.Filters)+1)
+		copy(filters, r.Filters)
+		r.Filters = append(filters, CopyFilter(f))
+		n++
+	}
+
+	return n
+}
+
+// MergeFilterArgsInRoute collapses the multiple occurrences of the named
+// filter in the route into a single one, e.g. to union the allowed origins
+// of repeated CORS filters. The merge function receives the args of the
+// occurrences, in their order, and returns the args of the merged filter.
+// The merged filter takes the position and the comment of the first
+// occurrence, and the rest are removed. The route is modified in place,
+// without changing the original filters, and it returns true when there
+// were multiple occurrences to merge.
+func MergeFilterArgsInRoute(r *Route, name string, merge func([][]interface{}) []interface{}) bool {
+	var (
+		args  [][]interface{}
+		first = -1
+	)
+
+	for i, f := range r.Filters {
+		if f.Name != name {
+			continue
+		}
+
+		if first < 0 {
+			first = i
+		}
+
+		args = append(args, f.Args)
+	}
+
+	if len(args) < 2 {
+		return false
+	}
+
+	filters := make([]*Filter, 0, len(r.Filters)-len(args)+1)
+	for i, f := range r.Filters {
+		switch {
+		case i == first:
+			filters = append(filters, &Filter{Name: name, Args: merge(args), Comment: f.Comment})
+		case f.Name != name:
+			filters = append(filters, f)
+		}
+	}
+
+	r.Filters = filters
+	return true
+}
+
 // Represents a matcher condition for incoming requests.
 type matcher struct {
 	// The name of the matcher, e.g. Path or Header
@@ -235,6 +504,7 @@ type parsedRoute struct {
 	backend     string
 	lbAlgorithm string
 	lbEndpoints []string
+	annotations map[string]string
 }
 
 // A Predicate object represents a parsed, in-memory, route matching predicate
@@ -252,7 +522,13 @@ type Predicate struct {
 }
 
 func (p *Predicate) String() string {
-	return fmt.Sprintf("%s(%s)", p.Name, argsString(p.Args))
+	return p.PrintQuoted(DoubleQuotes)
+}
+
+// PrintQuoted serializes the predicate like String, quoting the string args
+// in the requested style.
+func (p *Predicate) PrintQuoted(style QuoteStyle) string {
+	return fmt.Sprintf("%s(%s)", p.Name, argsStringQuoted(p.Args, style))
 }
 
 // A Filter object represents a parsed, in-memory filter expression.
@@ -262,10 +538,21 @@ type Filter struct {
 
 	// filter args applied within a particular route
 	Args []interface{} `json:"args"`
+
+	// Comment holds the comment following the filter in the same
+	// line, e.g. status(418) // added for incident-1234. It doesn't
+	// affect the route.
+	Comment string `json:"-"`
 }
 
 func (f *Filter) String() string {
-	return fmt.Sprintf("%s(%s)", f.Name, argsString(f.Args))
+	return f.PrintQuoted(DoubleQuotes)
+}
+
+// PrintQuoted serializes the filter like String, quoting the string args in
+// the requested style. The comment of the filter is omitted.
+func (f *Filter) PrintQuoted(style QuoteStyle) string {
+	return fmt.Sprintf("%s(%s)", f.Name, argsStringQuoted(f.Args, style))
 }
 
 // A Route object represents a parsed, in-memory route definition.
@@ -284,7 +571,8 @@ type Route struct {
 	// E.g. Host(/[.]example[.]org/)
 	HostRegexps []string
 
-	// Path regular expressions to match.
+	// Path regular expressions to match. When there are multiple, all
+	// of them need to match.
 	// E.g. PathRegexp(/\/api\//)
 	PathRegexps []string
 
@@ -300,6 +588,21 @@ type Route struct {
 	// E.g. HeaderRegexp("Accept", /\Wapplication\/json\W/)
 	HeaderRegexps map[string][]string
 
+	// Names of the query params that need to be present, when parsed
+	// with the QueryParamFields option.
+	// E.g. QueryParam("page")
+	QueryParams []string
+
+	// Query param regular expressions to match, when parsed with the
+	// QueryParamFields option.
+	// E.g. QueryParam("page", /^[0-9]+$/)
+	QueryParamRegexps map[string][]string
+
+	// Cookie regular expressions to match, when parsed with the
+	// CookieFields option.
+	// E.g. Cookie("tcial", /^enabled$/)
+	Cookies map[string][]string
+
 	// Custom predicates to match.
 	// E.g. Traffic(.3)
 	Predicates []*Predicate
@@ -311,7 +614,8 @@ type Route struct {
 	// Indicates that the parsed route has a shunt backend.
 	// (<shunt>, no forwarding to a backend)
 	//
-	// Deprecated, use the BackendType field instead.
+	// Deprecated, use the BackendType field instead. See also
+	// ValidateShuntField().
 	Shunt bool
 
 	// Indicates that the parsed route is a shunt, loopback or
@@ -330,6 +634,60 @@ type Route struct {
 	// load balancing backends.
 	LBEndpoints []string
 
+	// Fallback marks the route as the fallback route of the routing
+	// table, that is meant to handle the requests that are not
+	// matched by any other route. It doesn't affect the route matching.
+	// E.g. Fallback()
+	Fallback bool
+
+	// PredicateOrder contains the names of the predicates in the order
+	// as they were defined in the source, when parsed with the
+	// PredicateOrder option. When set, the stringer prints the
+	// predicates in this order. Only the names are recorded, so the
+	// predicates with the same name are printed in their default
+	// order, e.g. the Header predicates sorted by the header name. It
+	// doesn't affect the route matching.
+	PredicateOrder []string
+
+	// Annotations contain arbitrary metadata of the route, that doesn't
+	// affect the route matching. They are parsed from the comment lines
+	// preceding the route, in the form of:
+	// // @team=gateway
+	Annotations map[string]string
+
+	// Order is an explicit, author controlled evaluation order of the
+	// route, parsed from the @order annotation, e.g. // @order=10. It
+	// is not stored in the Annotations. See SortByOrder(). It doesn't
+	// affect the route matching.
+	Order int
+
+	// BackendOptions contain the options of the backend, that are not
+	// implemented as filters, e.g. timeouts. They are parsed from the
+	// annotations with the backend- prefix, without the prefix, e.g.
+	// // @backend-timeout=5s, and they are not stored in the
+	// Annotations. See ValidateBackendOptions().
+	BackendOptions map[string]string
+
+	// Env is the environment of the route, parsed from the @env
+	// annotation, e.g. // @env=staging. It is not stored in the
+	// Annotations. See SelectByEnv(). It doesn't affect the route
+	// matching.
+	Env string
+
+	// RateLimit is the intended rate limit of the route, parsed from the
+	// @ratelimit annotation, e.g. // @ratelimit=100/s. It is not stored
+	// in the Annotations. See ApplyRateLimitFilters(). It doesn't affect
+	// the route matching.
+	RateLimit string
+
+	// Disabled marks the routes that are kept in the document, but are
+	// not meant to be used, parsed from the @disabled annotation, e.g.
+	// // @disabled: migrating to v2. DisabledReason contains the
+	// optional reason after the colon. They are not stored in the
+	// Annotations. See ActiveRoutes() and DisabledRoutes().
+	Disabled       bool
+	DisabledReason string
+
 	// Name is deprecated and not used.
 	Name string
 
@@ -349,19 +707,19 @@ type RouteInfo struct {
 	ParseError error
 }
 
-// Copy copies a filter to a new filter instance. The argument values are copied in a shallow way.
+// Copy copies a filter to a new filter instance. The argument values are copied in a shallow way,
+// except for the binary and the predicate args.
 func (f *Filter) Copy() *Filter {
 	c := *f
-	c.Args = make([]interface{}, len(f.Args))
-	copy(c.Args, f.Args)
+	c.Args = copyArgs(f.Args)
 	return &c
 }
 
-// Copy copies a predicate to a new filter instance. The argument values are copied in a shallow way.
+// Copy copies a predicate to a new filter instance. The argument values are copied in a shallow way,
+// except for the binary and the predicate args.
 func (p *Predicate) Copy() *Predicate {
 	c := *p
-	c.Args = make([]interface{}, len(p.Args))
-	copy(c.Args, p.Args)
+	c.Args = copyArgs(p.Args)
 	return &c
 }
 
@@ -394,6 +752,24 @@ func (r *Route) Copy() *Route {
 		}
 	}
 
+	if len(r.QueryParams) > 0 {
+		c.QueryParams = copyStrings(r.QueryParams)
+	}
+
+	if len(r.QueryParamRegexps) > 0 {
+		c.QueryParamRegexps = make(map[string][]string)
+		for k, vs := range r.QueryParamRegexps {
+			c.QueryParamRegexps[k] = copyStrings(vs)
+		}
+	}
+
+	if len(r.Cookies) > 0 {
+		c.Cookies = make(map[string][]string)
+		for k, vs := range r.Cookies {
+			c.Cookies[k] = copyStrings(vs)
+		}
+	}
+
 	if len(r.Predicates) > 0 {
 		c.Predicates = make([]*Predicate, len(r.Predicates))
 		for i, p := range r.Predicates {
@@ -413,6 +789,18 @@ func (r *Route) Copy() *Route {
 		copy(c.LBEndpoints, r.LBEndpoints)
 	}
 
+	if len(r.PredicateOrder) > 0 {
+		c.PredicateOrder = copyStrings(r.PredicateOrder)
+	}
+
+	if len(r.Annotations) > 0 {
+		c.Annotations = copyAnnotations(r.Annotations)
+	}
+
+	if len(r.BackendOptions) > 0 {
+		c.BackendOptions = copyAnnotations(r.BackendOptions)
+	}
+
 	return &c
 }
 
@@ -470,84 +858,187 @@ func getStringArgs(n int, args []interface{}) ([]string, error) {
 	return sargs, nil
 }
 
-// Checks and sets the different predicates taken from the yacc result.
-// As the syntax is getting stabilized, this logic soon should be defined as
-// yacc rules. (https://github.com/zalando/skipper/issues/89)
-func applyPredicates(route *Route, proute *parsedRoute) error {
+// the weight is a single, non-negative integer number, e.g. Weight(50)
+func checkWeight(route *Route, pargs []interface{}) error {
+	if len(pargs) != 1 {
+		return invalidPredicateArgCountError
+	}
+
+	w, ok := pargs[0].(float64)
+	if !ok || w < 0 || w != math.Trunc(w) {
+		return fmt.Errorf(invalidWeightErrorFmt, route.Id, argsString(pargs))
+	}
+
+	return nil
+}
+
+// the Not predicate wraps a single predicate, e.g. Not(Header("X-Foo", "bar"))
+func checkNot(pargs []interface{}) error {
+	if len(pargs) != 1 {
+		return invalidNotArgsError
+	}
+
+	p, ok := pargs[0].(*Predicate)
+	if !ok || p.Name == "*" {
+		return invalidNotArgsError
+	}
+
+	if p.Name == "Not" {
+		return checkNot(p.Args)
+	}
+
+	if hasNestedPredicate(p.Args) {
+		return fmt.Errorf(nestedPredicateErrorFmt, p.Name)
+	}
+
+	return nil
+}
+
+func hasNestedPredicate(args []interface{}) bool {
+	for _, a := range args {
+		if _, ok := a.(*Predicate); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks and sets a predicate either in the convenience fields of the route,
+// or in the generic predicates.
+func applyPredicate(route *Route, name string, pargs []interface{}, o ParseOptions) error {
 	var (
-		err       error
-		args      []string
-		pathSet   bool
-		methodSet bool
+		err  error
+		args []string
 	)
 
-	for _, m := range proute.matchers {
-		if err != nil {
-			return err
+	if name != "Not" && hasNestedPredicate(pargs) {
+		return fmt.Errorf(nestedPredicateErrorFmt, name)
+	}
+
+	switch name {
+	case "Path":
+		if route.Path != "" {
+			return duplicatePathTreePredicateError
 		}
 
-		switch m.name {
-		case "Path":
-			if pathSet {
-				return duplicatePathTreePredicateError
-			}
+		if args, err = getStringArgs(1, pargs); err == nil {
+			route.Path = args[0]
+		}
+	case "Host":
+		if args, err = getStringArgs(1, pargs); err == nil {
+			route.HostRegexps = append(route.HostRegexps, args[0])
+		}
+	case "PathRegexp":
+		if args, err = getStringArgs(1, pargs); err == nil {
+			route.PathRegexps = append(route.PathRegexps, args[0])
+		}
+	case "Method":
+		if route.Method != "" {
+			return duplicateMethodPredicateError
+		}
 
-			if args, err = getStringArgs(1, m.args); err == nil {
-				route.Path = args[0]
-				pathSet = true
-			}
-		case "Host":
-			if args, err = getStringArgs(1, m.args); err == nil {
-				route.HostRegexps = append(route.HostRegexps, args[0])
+		if args, err = getStringArgs(1, pargs); err == nil {
+			route.Method = args[0]
+		}
+	case "HeaderRegexp":
+		if args, err = getStringArgs(2, pargs); err == nil {
+			if o.CanonicalHeaderNames {
+				args[0] = textproto.CanonicalMIMEHeaderKey(args[0])
 			}
-		case "PathRegexp":
-			if args, err = getStringArgs(1, m.args); err == nil {
-				route.PathRegexps = append(route.PathRegexps, args[0])
+
+			if route.HeaderRegexps == nil {
+				route.HeaderRegexps = make(map[string][]string)
 			}
-		case "Method":
-			if methodSet {
-				return duplicateMethodPredicateError
+
+			route.HeaderRegexps[args[0]] = append(route.HeaderRegexps[args[0]], args[1])
+		}
+	case "Header":
+		if args, err = getStringArgs(2, pargs); err == nil {
+			if o.CanonicalHeaderNames {
+				args[0] = textproto.CanonicalMIMEHeaderKey(args[0])
 			}
 
-			if args, err = getStringArgs(1, m.args); err == nil {
-				route.Method = args[0]
-				methodSet = true
+			if _, ok := route.Headers[args[0]]; ok {
+				return fmt.Errorf(duplicateHeaderPredicateErrorFmt, args[0])
 			}
-		case "HeaderRegexp":
-			if args, err = getStringArgs(2, m.args); err == nil {
-				if route.HeaderRegexps == nil {
-					route.HeaderRegexps = make(map[string][]string)
-				}
 
-				route.HeaderRegexps[args[0]] = append(route.HeaderRegexps[args[0]], args[1])
+			if route.Headers == nil {
+				route.Headers = make(map[string]string)
 			}
-		case "Header":
-			if args, err = getStringArgs(2, m.args); err == nil {
-				if route.Headers == nil {
-					route.Headers = make(map[string]string)
-				}
 
-				if _, ok := route.Headers[args[0]]; ok {
-					return fmt.Errorf(duplicateHeaderPredicateErrorFmt, args[0])
-				}
+			route.Headers[args[0]] = args[1]
+		}
+	case "QueryParam":
+		err = applyQueryParam(route, pargs, o)
+	case "Cookie":
+		err = applyCookie(route, pargs, o)
+	case "Fallback":
+		if len(pargs) != 0 {
+			return invalidFallbackArgsError
+		}
 
-				route.Headers[args[0]] = args[1]
-			}
-		case "*", "Any":
-			// void
-		default:
-			route.Predicates = append(
-				route.Predicates,
-				&Predicate{m.name, m.args})
+		route.Fallback = true
+	case "Weight":
+		if err = checkWeight(route, pargs); err == nil {
+			route.Predicates = append(route.Predicates, &Predicate{name, pargs})
+		}
+	case "Not":
+		if err = checkNot(pargs); err == nil {
+			route.Predicates = append(route.Predicates, &Predicate{name, pargs})
 		}
+	case "*", "Any":
+		if o.AnyPredicate && !hasAnyPredicate(route) {
+			route.Predicates = append(route.Predicates, &Predicate{Name: "Any"})
+		}
+	default:
+		route.Predicates = append(
+			route.Predicates,
+			&Predicate{name, pargs})
 	}
 
 	return err
 }
 
+func hasAnyPredicate(r *Route) bool {
+	for _, p := range r.Predicates {
+		if p.Name == "Any" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks and sets the different predicates taken from the yacc result.
+// As the syntax is getting stabilized, this logic soon should be defined as
+// yacc rules. (https://github.com/zalando/skipper/issues/89)
+func applyPredicates(route *Route, proute *parsedRoute, o ParseOptions) error {
+	for _, m := range proute.matchers {
+		if err := applyPredicate(route, m.name, m.args, o); err != nil {
+			return err
+		}
+
+		if o.PredicateOrder && m.name != "*" && m.name != "Any" {
+			route.PredicateOrder = append(route.PredicateOrder, m.name)
+		}
+	}
+
+	return nil
+}
+
+// AddPredicate adds a predicate to the route the same way as the parser
+// does: the predicates with a convenience field, like Path, Method or
+// Header, are stored in the convenience fields after validating their
+// arguments, while the rest of the predicates are appended to the generic
+// Predicates.
+func (r *Route) AddPredicate(name string, args ...interface{}) error {
+	return applyPredicate(r, name, args, ParseOptions{})
+}
+
 // Converts a parsing route objects to the exported route definition with
 // pre-processed but not validated matchers.
-func newRouteDefinition(r *parsedRoute) (*Route, error) {
+func newRouteDefinition(r *parsedRoute, o ParseOptions) (*Route, error) {
 	if len(r.lbEndpoints) > 0 {
 		scheme := ""
 		for _, e := range r.lbEndpoints {
@@ -571,6 +1062,9 @@ func newRouteDefinition(r *parsedRoute) (*Route, error) {
 	rd.Backend = r.backend
 	rd.LBAlgorithm = r.lbAlgorithm
 	rd.LBEndpoints = r.lbEndpoints
+	if err := setAnnotations(rd, r.annotations); err != nil {
+		return nil, err
+	}
 
 	switch {
 	case r.shunt:
@@ -585,14 +1079,22 @@ func newRouteDefinition(r *parsedRoute) (*Route, error) {
 		rd.BackendType = NetworkBackend
 	}
 
-	err := applyPredicates(rd, r)
+	err := applyPredicates(rd, r, o)
 
 	return rd, err
 }
 
 // executes the parser.
 func parse(code string) ([]*parsedRoute, error) {
+	return parseCode(code, ParseOptions{})
+}
+
+// executes the parser, with the options affecting the lexer.
+func parseCode(code string, o ParseOptions) ([]*parsedRoute, error) {
 	l := newLexer(code)
+	l.typedLiterals = o.TypedLiterals
+	l.strictEscapes = o.StrictEscapes
+	l.suggestShunt = o.SuggestShunt
 	eskipParse(l)
 	return l.routes, l.err
 }
@@ -616,26 +1118,125 @@ func predicatesToRoute(p string) string {
 	return partialRouteToRoute("%s -> <shunt>", p)
 }
 
+// ParseOptions can be used to control the optional behavior of the parser.
+type ParseOptions struct {
+	// CanonicalHeaderNames tells the parser to store the header names
+	// of the Header and HeaderRegexp predicates in their canonical form,
+	// as returned by textproto.CanonicalMIMEHeaderKey. The header values
+	// are not changed.
+	CanonicalHeaderNames bool
+
+	// PredicateOrder tells the parser to record the names of the
+	// predicates in the order of the source, in the PredicateOrder
+	// field of the routes, so that the stringer can print them in the
+	// same order.
+	PredicateOrder bool
+
+	// InternStrings tells the parser to store only a single instance of
+	// the identical strings, e.g. the predicate and filter names, the
+	// header names or the backend addresses, across the parsed routes.
+	// It reduces the memory retained by large routing tables, where the
+	// same strings are repeated many times, for the cost of a slower
+	// parsing.
+	InternStrings bool
+
+	// AnyPredicate tells the parser to represent the catch-all * and
+	// Any() predicates as an explicit Any predicate in the Predicates
+	// field of the routes, instead of omitting them. Repeated catch-all
+	// predicates are stored only once.
+	AnyPredicate bool
+
+	// QueryParamFields tells the parser to store the QueryParam
+	// predicates in the QueryParams and QueryParamRegexps fields of the
+	// routes, instead of the Predicates field.
+	QueryParamFields bool
+
+	// CookieFields tells the parser to store the Cookie predicates in the
+	// Cookies field of the routes, instead of the Predicates field.
+	CookieFields bool
+
+	// TypedLiterals tells the parser to accept the duration literals,
+	// e.g. 5s or 100ms, and the size literals, e.g. 10MB or 2KiB, as
+	// predicate and filter args, with the types Duration and ByteSize.
+	// Without it, these need to be passed in as strings or numbers.
+	TypedLiterals bool
+
+	// StrictEscapes tells the parser to fail on the unknown escape
+	// sequences in the strings, e.g. \q, instead of keeping them with
+	// the backslash. The error contains the position of the string
+	// with the escape sequence.
+	StrictEscapes bool
+
+	// SuggestShunt tells the parser to report the routes ending with a
+	// filter, without a backend, e.g. * -> status(404), with an error
+	// suggesting the <shunt> backend, instead of a generic syntax error.
+	// These routes are rejected with or without this option.
+	SuggestShunt bool
+}
+
 // Parses a route expression or a routing document to a set of route definitions.
 func Parse(code string) ([]*Route, error) {
-	parsedRoutes, err := parse(code)
+	return ParseWithOptions(code, ParseOptions{})
+}
+
+// ParseWithOptions parses a route expression or a routing document to a set
+// of route definitions, applying the provided options.
+func ParseWithOptions(code string, o ParseOptions) ([]*Route, error) {
+	parsedRoutes, err := parseCode(code, o)
 	if err != nil {
 		return nil, err
 	}
 
+	var interned internTable
+	if o.InternStrings {
+		interned = make(internTable)
+	}
+
 	routeDefinitions := make([]*Route, len(parsedRoutes))
 	for i, r := range parsedRoutes {
-		rd, err := newRouteDefinition(r)
+		rd, err := newRouteDefinition(r, o)
 		if err != nil {
 			return nil, err
 		}
 
+		if interned != nil {
+			interned.internRoute(rd)
+		}
+
 		routeDefinitions[i] = rd
 	}
 
 	return routeDefinitions, nil
 }
 
+// ParseLimited reads a routing document from r, and parses it the same way
+// as Parse(). It reads at most maxBytes, and when the input is longer, it
+// returns an error without parsing it. It returns an error when maxBytes is
+// negative.
+func ParseLimited(r io.Reader, maxBytes int64) ([]*Route, error) {
+	if maxBytes < 0 {
+		return nil, negativeSizeLimitError
+	}
+
+	// reading one more byte than the limit tells whether the input is
+	// longer:
+	limit := maxBytes
+	if limit < math.MaxInt64 {
+		limit++
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(b)) > maxBytes {
+		return nil, fmt.Errorf(inputTooLargeErrorFmt, maxBytes)
+	}
+
+	return Parse(string(b))
+}
+
 func partialParse(f string, partialToRoute func(string) string) (*parsedRoute, error) {
 	rs, err := parse(partialToRoute(f))
 	if err != nil {
@@ -680,6 +1281,42 @@ func ParsePredicates(p string) ([]*Predicate, error) {
 	return ps, nil
 }
 
+// hacks a backend expression into a route expression for parsing.
+func backendToRoute(b string) string {
+	return partialRouteToRoute("* -> %s", b)
+}
+
+// ParseBackend parses a backend expression, the right-hand side of a route
+// after the last ->, e.g. "https://www.example.org", <shunt> or
+// <roundRobin, "https://a.example.org", "https://b.example.org">, and returns
+// the backend in the form of the backend fields of a route. It accepts every
+// backend form that the route parser does, and returns an error when the
+// input is not a single backend expression.
+func ParseBackend(fragment string) (backend string, bt BackendType, lbAlgo string, lbEndpoints []string, err error) {
+	code := backendToRoute(fragment)
+	if code == "" {
+		err = invalidBackendError
+		return
+	}
+
+	var routes []*parsedRoute
+	if routes, err = parse(code); err != nil {
+		return
+	}
+
+	if len(routes) != 1 || len(routes[0].filters) != 0 {
+		err = invalidBackendError
+		return
+	}
+
+	var r *Route
+	if r, err = newRouteDefinition(routes[0], ParseOptions{}); err != nil {
+		return
+	}
+
+	return r.Backend, r.BackendType, r.LBAlgorithm, r.LBEndpoints, nil
+}
+
 const randomIdLength = 16
 
 var routeIdRx = regexp.MustCompile(`\W`)
@@ -706,3 +1343,31 @@ func GenerateIfNeeded(existingId string) string {
 	id = routeIdRx.ReplaceAllString(id, "x")
 	return "route" + id
 }
+
+// Fallbacks returns the routes marked as fallback, see Route.Fallback.
+func Fallbacks(routes []*Route) []*Route {
+	var f []*Route
+	for _, r := range routes {
+		if r.Fallback {
+			f = append(f, r)
+		}
+	}
+
+	return f
+}
+
+// ValidateFallbacks returns an error listing the IDs of the fallback routes,
+// when more than one route is marked as fallback.
+func ValidateFallbacks(routes []*Route) error {
+	f := Fallbacks(routes)
+	if len(f) <= 1 {
+		return nil
+	}
+
+	ids := make([]string, len(f))
+	for i, r := range f {
+		ids[i] = r.Id
+	}
+
+	return fmt.Errorf("multiple fallback routes: %s", strings.Join(ids, ", "))
+}
@@ -1,8 +1,10 @@
 package eskip
 
 import (
+	"math"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -129,6 +131,16 @@ func TestParseRouteExpression(t *testing.T) {
 				{"Custom2", nil}},
 			Backend: "https://www.example.org"},
 		false,
+	}, {
+		"fallback",
+		`* && Fallback() -> "https://www.example.org"`,
+		&Route{Fallback: true, Backend: "https://www.example.org"},
+		false,
+	}, {
+		"fallback with args",
+		`Fallback("foo") -> "https://www.example.org"`,
+		nil,
+		true,
 	}, {
 		"double path predicates",
 		`Path("/one") && Path("/two") -> "https://www.example.org"`,
@@ -342,7 +354,7 @@ func TestRouteJSON(t *testing.T) {
 		`{"id":"","backend":"","predicates":[],"filters":[]}` + "\n",
 	}, {
 		&Route{
-			Filters:    []*Filter{{"xsrf", nil}},
+			Filters:    []*Filter{{Name: "xsrf", Args: nil}},
 			Predicates: []*Predicate{{"Test", nil}},
 		},
 		`{"id":"","backend":"","predicates":[{"name":"Test","args":[]}],"filters":[{"name":"xsrf","args":[]}]}` + "\n",
@@ -364,6 +376,9 @@ func TestRouteJSON(t *testing.T) {
 	}, {
 		&Route{Method: "GET", BackendType: DynamicBackend},
 		`{"id":"","backend":"<dynamic>","predicates":[{"name":"Method","args":["GET"]}],"filters":[]}` + "\n",
+	}, {
+		&Route{BackendType: ShuntBackend, Annotations: map[string]string{"team": "gateway"}},
+		`{"id":"","backend":"<shunt>","predicates":[],"filters":[],"annotations":{"team":"gateway"}}` + "\n",
 	}, {
 		&Route{
 			Method:      "PUT",
@@ -376,8 +391,8 @@ func TestRouteJSON(t *testing.T) {
 				`ap"key`: {"slash/value0", "slash/value1"}},
 			Predicates: []*Predicate{{"Test", []interface{}{3.14, "hello"}}},
 			Filters: []*Filter{
-				{"filter0", []interface{}{float64(3.1415), "argvalue"}},
-				{"filter1", []interface{}{float64(-42), `ap"argvalue`}}},
+				{Name: "filter0", Args: []interface{}{float64(3.1415), "argvalue"}},
+				{Name: "filter1", Args: []interface{}{float64(-42), `ap"argvalue`}}},
 			Shunt:   false,
 			Backend: "https://www.example.org"},
 		`{` +
@@ -472,6 +487,7 @@ func TestClone(t *testing.T) {
 		Predicates:    []*Predicate{{Name: "Foo", Args: []interface{}{"bar", "baz"}}},
 		Filters:       []*Filter{{Name: "foo", Args: []interface{}{42, 84}}},
 		Backend:       "https://www2.example.org",
+		Annotations:   map[string]string{"team": "gateway"},
 	}
 
 	c := r.Copy()
@@ -604,6 +620,260 @@ r2: Method("POST") -> inlineContent("r2") -> <shunt>;
 	}
 }
 
+func TestDefaultFiltersForBackendTypes(t *testing.T) {
+	routes, err := Parse(`
+		network: Path("/network") -> "https://www.example.org";
+		lb: Path("/lb") -> <"https://a.example.org", "https://b.example.org">;
+		shunt: Path("/shunt") -> <shunt>;
+		loopback: Path("/loopback") -> setPath("/network") -> <loopback>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the legacy shunt field
+	routes = append(routes, &Route{Id: "legacyShunt", Shunt: true})
+
+	prepend, err := ParseFilters("first()")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	append, err := ParseFilters(`fifoWithBody(100, 150, "10s")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	df := &DefaultFilters{
+		Prepend:               prepend,
+		Append:                append,
+		AppendForBackendTypes: []BackendType{NetworkBackend, LBBackend},
+	}
+
+	result := df.Do(routes)
+	for _, test := range []struct {
+		id     string
+		expect []string
+	}{{
+		id:     "network",
+		expect: []string{"first", "fifoWithBody"},
+	}, {
+		id:     "lb",
+		expect: []string{"first", "fifoWithBody"},
+	}, {
+		id:     "shunt",
+		expect: []string{"first"},
+	}, {
+		id:     "loopback",
+		expect: []string{"first", "setPath"},
+	}, {
+		id:     "legacyShunt",
+		expect: []string{"first"},
+	}} {
+		t.Run(test.id, func(t *testing.T) {
+			var r *Route
+			for _, ri := range result {
+				if ri.Id == test.id {
+					r = ri
+				}
+			}
+
+			if d := cmp.Diff(test.expect, filterNames(r.Filters)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+
+	df = &DefaultFilters{
+		Prepend:                prepend,
+		PrependForBackendTypes: []BackendType{LoopBackend},
+	}
+
+	result = df.Do(routes)
+	for _, r := range result {
+		if n := filterNames(r.Filters); (r.Id == "loopback") != (len(n) > 0 && n[0] == "first") {
+			t.Errorf("invalid filters in route %s: %v", r.Id, n)
+		}
+	}
+}
+
+func TestDefaultFiltersSkipIfPresent(t *testing.T) {
+	routes, err := Parse(`
+		none: * -> setPath("/") -> <shunt>;
+		prepended: * -> tracingTag("team", "foo") -> setPath("/") -> <shunt>;
+		appended: * -> setPath("/") -> status(200) -> <shunt>;
+		both: * -> status(418) -> setPath("/") -> tracingTag("team", "bar") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prepend, err := ParseFilters(`tracingTag("team", "gateway")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	append, err := ParseFilters("status(404)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("duplicates by default", func(t *testing.T) {
+		df := &DefaultFilters{Prepend: prepend, Append: append}
+		result := df.Do(routes)
+		if d := cmp.Diff([]string{"tracingTag", "tracingTag", "setPath", "status"}, filterNames(result[1].Filters)); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("skip if present", func(t *testing.T) {
+		df := &DefaultFilters{Prepend: prepend, Append: append, SkipIfPresent: true}
+		result := df.Do(routes)
+		for i, expect := range [][]string{
+			{"tracingTag", "setPath", "status"},
+			{"tracingTag", "setPath", "status"},
+			{"tracingTag", "setPath", "status"},
+			{"status", "setPath", "tracingTag"},
+		} {
+			if d := cmp.Diff(expect, filterNames(result[i].Filters)); d != "" {
+				t.Errorf("invalid filters in route %s", result[i].Id)
+				t.Log(d)
+			}
+		}
+
+		if result[1].Filters[0].Args[0] != "team" || result[1].Filters[0].Args[1] != "foo" {
+			t.Error("failed to keep the existing filter")
+		}
+
+		again := df.Do(result)
+		if !EqLists(result, again) {
+			t.Error("failed to apply the default filters idempotently")
+		}
+	})
+}
+
+func TestEnsureFilterWhereMissing(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/public") -> <shunt>;
+		r2: Path("/api") -> setPath("/") -> "https://api.example.org";
+		r3: Path("/admin") && JWTPayloadAnyKV("iss", "example") -> "https://admin.example.org";
+		r4: Path("/internal") -> oauthTokenintrospectionAnyScope("https://issuer.example.org", "read") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &Filter{Name: "oauthTokenintrospectionAnyScope", Args: []interface{}{"https://issuer.example.org", "write"}}
+	missingAuth := func(r *Route) bool {
+		for _, p := range r.Predicates {
+			if strings.HasPrefix(p.Name, "JWT") {
+				return false
+			}
+		}
+
+		return r.Id != "r1"
+	}
+
+	original := r[1].Filters
+	if n := EnsureFilterWhereMissing(r, f, missingAuth); n != 1 {
+		t.Errorf("invalid number of changed routes: %d", n)
+	}
+
+	if d := cmp.Diff([][]string{
+		nil,
+		{"setPath", "oauthTokenintrospectionAnyScope"},
+		nil,
+		{"oauthTokenintrospectionAnyScope"},
+	}, [][]string{
+		filterNames(r[0].Filters),
+		filterNames(r[1].Filters),
+		filterNames(r[2].Filters),
+		filterNames(r[3].Filters),
+	}); d != "" {
+		t.Error(d)
+	}
+
+	if r[3].Filters[0].Args[1] != "read" {
+		t.Error("the existing filter was changed")
+	}
+
+	if len(original) != 1 {
+		t.Error("the original filter chain was modified")
+	}
+
+	if r[1].Filters[1] == f {
+		t.Error("failed to copy the filter")
+	}
+
+	if n := EnsureFilterWhereMissing(r, f, missingAuth); n != 0 {
+		t.Errorf("the filter was added again to %d routes", n)
+	}
+}
+
+func TestMergeFilterArgsInRoute(t *testing.T) {
+	union := func(args [][]interface{}) []interface{} {
+		var merged []interface{}
+		seen := make(map[interface{}]bool)
+		for _, a := range args {
+			for _, ai := range a {
+				if !seen[ai] {
+					seen[ai] = true
+					merged = append(merged, ai)
+				}
+			}
+		}
+
+		return merged
+	}
+
+	for _, test := range []struct {
+		title  string
+		route  string
+		merged bool
+		expect string
+	}{{
+		title:  "no occurrence",
+		route:  `* -> setPath("/") -> <shunt>`,
+		expect: `* -> setPath("/") -> <shunt>`,
+	}, {
+		title:  "single occurrence",
+		route:  `* -> corsOrigin("https://a.example.org") -> <shunt>`,
+		expect: `* -> corsOrigin("https://a.example.org") -> <shunt>`,
+	}, {
+		title: "multiple occurrences",
+		route: `* -> setPath("/") -> corsOrigin("https://a.example.org") // first
+			-> status(200)
+			-> corsOrigin("https://b.example.org", "https://a.example.org")
+			-> corsOrigin()
+			-> <shunt>`,
+		merged: true,
+		expect: `* -> setPath("/") -> corsOrigin("https://a.example.org", "https://b.example.org") // first
+-> status(200) -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.route)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := r[0].Filters
+			originalString := r[0].String()
+			if merged := MergeFilterArgsInRoute(r[0], "corsOrigin", union); merged != test.merged {
+				t.Errorf("invalid merge result, got: %t, expected: %t", merged, test.merged)
+			}
+
+			if s := r[0].String(); s != test.expect {
+				t.Errorf("invalid route, got: %s, expected: %s", s, test.expect)
+			}
+
+			r[0].Filters = original
+			if r[0].String() != originalString {
+				t.Error("the original filters were modified")
+			}
+		})
+	}
+}
+
 func TestEditorPreProcessor(t *testing.T) {
 	r0, err := Parse(`r0: Host("www[.]example[.]org") -> status(201) -> <shunt>`)
 	if err != nil {
@@ -740,6 +1010,10 @@ func TestClonePreProcessor(t *testing.T) {
 	if err != nil {
 		t.Errorf("Failed to parse route: %v", err)
 	}
+	r1Transformed, err := Parse(`clone_r1: ClientIP("1.2.3.4/26") -> status(201) -> tee("https://shadow.example.org") -> <shunt>`)
+	if err != nil {
+		t.Errorf("Failed to parse route: %v", err)
+	}
 
 	for _, tt := range []struct {
 		name   string
@@ -806,6 +1080,18 @@ func TestClonePreProcessor(t *testing.T) {
 			},
 			routes: r1Filter,
 			want:   append(r1Filter, r1FilterChanged...),
+		},
+		{
+			name: "test transform clone should change only the cloned routes",
+			rep: &Clone{
+				reg:  regexp.MustCompile("Source[(](.*)[)]"),
+				repl: "ClientIP($1)",
+				TransformClone: func(r *Route) {
+					r.Filters = append(r.Filters, &Filter{Name: "tee", Args: []interface{}{"https://shadow.example.org"}})
+				},
+			},
+			routes: append(r0, r1...),
+			want:   append(r0, append(r1, r1Transformed...)...),
 		}} {
 		t.Run(tt.name, func(t *testing.T) {
 			r := CanonicalList(tt.routes)
@@ -818,6 +1104,84 @@ func TestClonePreProcessor(t *testing.T) {
 
 }
 
+func TestPreview(t *testing.T) {
+	const doc = `
+		r0: Host("www[.]example[.]org") -> status(201) -> <shunt>;
+		r1: Source("1.2.3.4/26") -> status(201) -> <shunt>;
+		r2: * -> uniformRequestLatency("100ms", "10ms") -> <shunt>;
+
+		// @skip-preprocessing
+		r3: Source("10.0.0.0/8") -> <shunt>;
+	`
+
+	rx := regexp.MustCompile("Source[(](.*)[)]")
+	const repl = "ClientIP($1)"
+	for _, test := range []struct {
+		title   string
+		preview func([]*Route) []*Route
+		expect  []string
+	}{{
+		title:   "empty editor",
+		preview: (&Editor{}).Preview,
+	}, {
+		title:   "editor",
+		preview: NewEditor(rx, repl).Preview,
+		expect: []string{
+			`r1: ClientIP("1.2.3.4/26") -> status(201) -> <shunt>`,
+		},
+	}, {
+		title: "editor, filter",
+		preview: NewEditor(
+			regexp.MustCompile("uniformRequestLatency"),
+			"normalRequestLatency",
+		).Preview,
+		expect: []string{
+			`r2: * -> normalRequestLatency("100ms", "10ms") -> <shunt>`,
+		},
+	}, {
+		title:   "empty clone",
+		preview: (&Clone{}).Preview,
+	}, {
+		title:   "clone",
+		preview: NewClone(rx, repl).Preview,
+		expect: []string{
+			`clone_r1: ClientIP("1.2.3.4/26") -> status(201) -> <shunt>`,
+		},
+	}, {
+		title: "clone with transform",
+		preview: (&Clone{
+			reg:            rx,
+			repl:           repl,
+			TransformClone: func(r *Route) { r.Filters = nil },
+		}).Preview,
+		expect: []string{
+			`clone_r1: ClientIP("1.2.3.4/26") -> <shunt>`,
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := String(r...)
+			filters := r[2].Filters
+			var preview []string
+			for _, ri := range test.preview(r) {
+				preview = append(preview, ri.Id+": "+ri.String())
+			}
+
+			if d := cmp.Diff(test.expect, preview); d != "" {
+				t.Error(d)
+			}
+
+			if String(r...) != original || filters[0].Name != "uniformRequestLatency" {
+				t.Error("the input routes were modified")
+			}
+		})
+	}
+}
+
 func TestPredicateString(t *testing.T) {
 	for _, tt := range []struct {
 		name      string
@@ -889,3 +1253,530 @@ func TestFilterString(t *testing.T) {
 		})
 	}
 }
+
+func TestFallbacks(t *testing.T) {
+	routes, err := Parse(`
+		r1: Path("/foo") -> "https://foo.example.org";
+		r2: Fallback() -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := Fallbacks(routes)
+	if len(f) != 1 || f[0].Id != "r2" {
+		t.Fatalf("failed to find the fallback route: %v", f)
+	}
+
+	if err := ValidateFallbacks(routes); err != nil {
+		t.Fatal(err)
+	}
+
+	printed := String(routes...)
+	if printed != `r1: Path("/foo") -> "https://foo.example.org";`+"\n"+`r2: Fallback() -> "https://www.example.org";` {
+		t.Fatalf("failed to print the fallback route: %s", printed)
+	}
+
+	reparsed, err := Parse(printed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !EqLists(routes, reparsed) {
+		t.Fatal("failed to round-trip the fallback route")
+	}
+
+	routes = append(routes, &Route{Id: "r3", Fallback: true, BackendType: ShuntBackend})
+	if err := ValidateFallbacks(routes); err == nil {
+		t.Fatal("failed to fail with multiple fallback routes")
+	} else if err.Error() != "multiple fallback routes: r2, r3" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseCanonicalHeaderNames(t *testing.T) {
+	const code = `Header("x-foo", "Bar") && HeaderRegexp("x-baz", /^Qux/) -> <shunt>`
+
+	r, err := Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].Headers["x-foo"] != "Bar" || r[0].HeaderRegexps["x-baz"][0] != "^Qux" {
+		t.Error("failed to keep the original header names by default")
+	}
+
+	r, err = ParseWithOptions(code, ParseOptions{CanonicalHeaderNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := &Route{
+		Headers:       map[string]string{"X-Foo": "Bar"},
+		HeaderRegexps: map[string][]string{"X-Baz": {"^Qux"}},
+		BackendType:   ShuntBackend,
+		Shunt:         true,
+	}
+
+	if d := cmp.Diff(expect, r[0]); d != "" {
+		t.Error("failed to canonicalize header names")
+		t.Log(d)
+	}
+
+	_, err = ParseWithOptions(
+		`Header("x-foo", "bar") && Header("X-Foo", "bar") -> <shunt>`,
+		ParseOptions{CanonicalHeaderNames: true},
+	)
+
+	if err == nil {
+		t.Error("failed to detect duplicate header predicates")
+	}
+}
+
+func TestParseBackend(t *testing.T) {
+	type backend struct {
+		Backend     string
+		Type        BackendType
+		LBAlgorithm string
+		LBEndpoints []string
+	}
+
+	for _, test := range []struct {
+		title    string
+		fragment string
+		expect   backend
+		fail     bool
+	}{{
+		title:    "network",
+		fragment: `"https://www.example.org"`,
+		expect:   backend{Backend: "https://www.example.org", Type: NetworkBackend},
+	}, {
+		title:    "shunt",
+		fragment: "<shunt>",
+		expect:   backend{Type: ShuntBackend},
+	}, {
+		title:    "loopback",
+		fragment: " <loopback> ",
+		expect:   backend{Type: LoopBackend},
+	}, {
+		title:    "dynamic",
+		fragment: "<dynamic>",
+		expect:   backend{Type: DynamicBackend},
+	}, {
+		title:    "load balanced",
+		fragment: `<roundRobin, "https://a.example.org", "https://b.example.org">`,
+		expect: backend{
+			Type:        LBBackend,
+			LBAlgorithm: "roundRobin",
+			LBEndpoints: []string{"https://a.example.org", "https://b.example.org"},
+		},
+	}, {
+		title:    "load balanced, default algorithm",
+		fragment: `<"https://a.example.org">`,
+		expect:   backend{Type: LBBackend, LBEndpoints: []string{"https://a.example.org"}},
+	}, {
+		title: "empty",
+		fail:  true,
+	}, {
+		title:    "garbage",
+		fragment: "foo bar",
+		fail:     true,
+	}, {
+		title:    "unknown backend type",
+		fragment: "<foo>",
+		fail:     true,
+	}, {
+		title:    "filters",
+		fragment: `setPath("/") -> <shunt>`,
+		fail:     true,
+	}, {
+		title:    "multiple routes",
+		fragment: `<shunt>; r2: * -> <shunt>`,
+		fail:     true,
+	}, {
+		title:    "mixed protocols",
+		fragment: `<"http://a.example.org", "https://b.example.org">`,
+		fail:     true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var (
+				b   backend
+				err error
+			)
+
+			b.Backend, b.Type, b.LBAlgorithm, b.LBEndpoints, err = ParseBackend(test.fragment)
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, b); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestParseAnyPredicate(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []*Predicate
+		print  string
+	}{{
+		title:  "star",
+		code:   `* -> <shunt>`,
+		expect: []*Predicate{{Name: "Any"}},
+		print:  `Any() -> <shunt>`,
+	}, {
+		title:  "any",
+		code:   `Any() -> <shunt>`,
+		expect: []*Predicate{{Name: "Any"}},
+		print:  `Any() -> <shunt>`,
+	}, {
+		title:  "repeated",
+		code:   `Any() && Any() -> <shunt>`,
+		expect: []*Predicate{{Name: "Any"}},
+		print:  `Any() -> <shunt>`,
+	}, {
+		title:  "with other predicates",
+		code:   `Any() && Custom() -> <shunt>`,
+		expect: []*Predicate{{Name: "Any"}, {Name: "Custom"}},
+		print:  `Custom() -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, p := range r[0].Predicates {
+				if p.Name == "Any" {
+					t.Error("failed to omit the Any predicate by default")
+				}
+			}
+
+			r, err = ParseWithOptions(test.code, ParseOptions{AnyPredicate: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, r[0].Predicates); d != "" {
+				t.Error("invalid predicates")
+				t.Log(d)
+			}
+
+			if s := r[0].Print(PrettyPrintInfo{AnyPredicate: true}); s != test.print {
+				t.Errorf("invalid print, got: %s, expected: %s", s, test.print)
+			}
+
+			if s := r[0].String(); s != strings.Replace(test.print, "Any()", "*", 1) {
+				t.Errorf("invalid default print, got: %s", s)
+			}
+		})
+	}
+}
+
+func TestParseLimited(t *testing.T) {
+	const code = `r1: Path("/foo") -> <shunt>;`
+
+	t.Run("within the limit", func(t *testing.T) {
+		r, err := ParseLimited(strings.NewReader(code), int64(len(code)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(r) != 1 || r[0].Id != "r1" || r[0].Path != "/foo" {
+			t.Errorf("failed to parse the routes: %v", r)
+		}
+	})
+
+	t.Run("exceeding the limit", func(t *testing.T) {
+		_, err := ParseLimited(strings.NewReader(code), int64(len(code)-1))
+		if err == nil || err.Error() != "the input exceeds the size limit of 27 bytes" {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+	})
+
+	t.Run("max limit", func(t *testing.T) {
+		r, err := ParseLimited(strings.NewReader(code), math.MaxInt64)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(r) != 1 || r[0].Id != "r1" {
+			t.Errorf("failed to parse the routes: %v", r)
+		}
+	})
+
+	t.Run("negative limit", func(t *testing.T) {
+		if _, err := ParseLimited(strings.NewReader(code), -1); err == nil {
+			t.Error("failed to fail")
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		if _, err := ParseLimited(strings.NewReader("r1: Path("), 1024); err == nil {
+			t.Error("failed to fail")
+		}
+	})
+}
+
+func TestAddPredicate(t *testing.T) {
+	r := &Route{BackendType: ShuntBackend}
+	for _, p := range []struct {
+		name string
+		args []interface{}
+	}{
+		{"Path", []interface{}{"/foo"}},
+		{"Host", []interface{}{"^www[.]example[.]org$"}},
+		{"PathRegexp", []interface{}{"^/foo"}},
+		{"Method", []interface{}{"GET"}},
+		{"Header", []interface{}{"X-Foo", "bar"}},
+		{"HeaderRegexp", []interface{}{"X-Bar", "^baz"}},
+		{"Traffic", []interface{}{.3}},
+		{"Custom", nil},
+		{"Any", nil},
+	} {
+		if err := r.AddPredicate(p.name, p.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expect, err := Parse(`
+		Path("/foo") &&
+		Host(/^www[.]example[.]org$/) &&
+		PathRegexp(/^\/foo/) &&
+		Method("GET") &&
+		Header("X-Foo", "bar") &&
+		HeaderRegexp("X-Bar", /^baz/) &&
+		Traffic(.3) &&
+		Custom()
+		-> <shunt>
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect[0].Shunt = false
+	if d := cmp.Diff(expect[0], r); d != "" {
+		t.Error("failed to add predicates")
+		t.Log(d)
+	}
+
+	for _, p := range []struct {
+		name string
+		args []interface{}
+	}{
+		{"Path", []interface{}{"/bar"}},
+		{"Method", []interface{}{"POST"}},
+		{"Header", []interface{}{"X-Foo", "qux"}},
+		{"Host", []interface{}{42}},
+		{"HeaderRegexp", []interface{}{"X-Foo"}},
+		{"Fallback", []interface{}{"foo"}},
+	} {
+		if err := r.AddPredicate(p.name, p.args...); err == nil {
+			t.Errorf("failed to fail to add invalid or duplicate predicate: %s", p.name)
+		}
+	}
+}
+
+func TestPreProcessorsPreserveAnnotations(t *testing.T) {
+	r, err := Parse(`
+		// @team=gateway
+		r1: Source("1.2.3.4/26") -> status(201) -> <shunt>
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]string{"team": "gateway"}
+	for _, test := range []struct {
+		title        string
+		preProcessor interface{ Do([]*Route) []*Route }
+	}{{
+		title:        "editor",
+		preProcessor: NewEditor(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)"),
+	}, {
+		title:        "clone",
+		preProcessor: NewClone(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)"),
+	}, {
+		title:        "default filters",
+		preProcessor: &DefaultFilters{Prepend: []*Filter{{Name: "foo"}}},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			rr := test.preProcessor.Do(CopyRoutes(r))
+			for _, ri := range rr {
+				if d := cmp.Diff(expect, ri.Annotations); d != "" {
+					t.Errorf("failed to preserve annotations of %s", ri.Id)
+					t.Log(d)
+				}
+			}
+		})
+	}
+}
+
+func TestWeightPredicate(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []interface{}
+		err    string
+	}{{
+		title:  "positive integer",
+		code:   `r1: Weight(50) -> <shunt>`,
+		expect: []interface{}{float64(50)},
+	}, {
+		title:  "zero",
+		code:   `r1: Weight(0) -> <shunt>`,
+		expect: []interface{}{float64(0)},
+	}, {
+		title: "negative",
+		code:  `r1: Weight(-5) -> <shunt>`,
+		err:   "invalid weight in route r1: -5, expected a non-negative integer",
+	}, {
+		title: "fractional",
+		code:  `r1: Weight(3.14) -> <shunt>`,
+		err:   "invalid weight in route r1: 3.14, expected a non-negative integer",
+	}, {
+		title: "string",
+		code:  `r1: Weight("50") -> <shunt>`,
+		err:   `invalid weight in route r1: "50", expected a non-negative integer`,
+	}, {
+		title: "missing",
+		code:  `r1: Weight() -> <shunt>`,
+		err:   invalidPredicateArgCountError.Error(),
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("unexpected error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r[0].Predicates) != 1 || r[0].Predicates[0].Name != "Weight" {
+				t.Fatal("failed to parse the weight predicate")
+			}
+
+			if d := cmp.Diff(test.expect, r[0].Predicates[0].Args); d != "" {
+				t.Error("invalid weight")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestNotPredicate(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []*Predicate
+		err    string
+	}{{
+		title: "header",
+		code:  `r1: Not(Header("X-Foo", "bar")) -> <shunt>`,
+		expect: []*Predicate{{
+			Name: "Not",
+			Args: []interface{}{&Predicate{Name: "Header", Args: []interface{}{"X-Foo", "bar"}}},
+		}},
+	}, {
+		title: "nested not with other predicates",
+		code:  `r1: Path("/foo") && Not(Not(Custom(42, /^bar/))) -> <shunt>`,
+		expect: []*Predicate{{
+			Name: "Not",
+			Args: []interface{}{&Predicate{
+				Name: "Not",
+				Args: []interface{}{&Predicate{Name: "Custom", Args: []interface{}{float64(42), "^bar"}}},
+			}},
+		}},
+	}, {
+		title: "zero arg inner predicate",
+		code:  `r1: Not(Custom()) -> <shunt>`,
+		expect: []*Predicate{{
+			Name: "Not",
+			Args: []interface{}{&Predicate{Name: "Custom"}},
+		}},
+	}, {
+		title: "no predicate arg",
+		code:  `r1: Not("foo") -> <shunt>`,
+		err:   invalidNotArgsError.Error(),
+	}, {
+		title: "catch all",
+		code:  `r1: Not(*) -> <shunt>`,
+		err:   invalidNotArgsError.Error(),
+	}, {
+		title: "nested predicate in other predicate",
+		code:  `r1: Custom(Header("X-Foo", "bar")) -> <shunt>`,
+		err:   "nested predicate arg in Custom, only supported by Not",
+	}, {
+		title: "nested predicate in the wrapped predicate",
+		code:  `r1: Not(Custom(Header("X-Foo", "bar"))) -> <shunt>`,
+		err:   "nested predicate arg in Custom, only supported by Not",
+	}, {
+		title: "multiple args",
+		code:  `r1: Not(Header("X-Foo", "bar"), Custom()) -> <shunt>`,
+		err:   "syntax error",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if test.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+					t.Errorf("unexpected error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, r[0].Predicates); d != "" {
+				t.Error("failed to parse the Not predicate")
+				t.Log(d)
+			}
+
+			rr, err := Parse(r[0].String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr[0].Id = r[0].Id
+			if !Eq(r[0], rr[0]) {
+				t.Errorf("failed to round-trip the route: %s", r[0].String())
+			}
+
+			c := r[0].Copy()
+			c.Predicates[0].Args[0].(*Predicate).Name = "Changed"
+			if r[0].Predicates[0].Args[0].(*Predicate).Name == "Changed" {
+				t.Error("failed to copy the wrapped predicate")
+			}
+		})
+	}
+}
+
+func TestPrintNotPredicate(t *testing.T) {
+	const code = `r1: Not(Header("X-Foo", "bar")) && Not(Not(Custom())) -> <shunt>`
+	r, err := Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := r[0].String(); s != `Not(Header("X-Foo", "bar")) && Not(Not(Custom())) -> <shunt>` {
+		t.Errorf("invalid route string: %s", s)
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Package eskiptest implements assertions for comparing eskip routes in tests,
+with field level failure messages.
+*/
+package eskiptest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zalando/skipper/eskip"
+)
+
+func stringsEqual(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i := range left {
+		if left[i] != right[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type item interface {
+	fmt.Stringer
+	name() string
+	args() []interface{}
+}
+
+type predicate struct{ *eskip.Predicate }
+
+func (p predicate) name() string        { return p.Name }
+func (p predicate) args() []interface{} { return p.Args }
+
+type filter struct{ *eskip.Filter }
+
+func (f filter) name() string        { return f.Name }
+func (f filter) args() []interface{} { return f.Args }
+
+func assertItems(t testing.TB, id, kind string, got, want []item) bool {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf(
+			"route %s: invalid number of %ss, got: %d, expected: %d",
+			id, kind, len(got), len(want),
+		)
+
+		return false
+	}
+
+	ok := true
+	for i := range got {
+		if got[i].name() != want[i].name() || !eskip.ArgsEqual(got[i].args(), want[i].args()) {
+			t.Errorf("route %s: invalid %s %d, got: %v, expected: %v", id, kind, i, got[i], want[i])
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func predicates(p []*eskip.Predicate) []item {
+	items := make([]item, len(p))
+	for i := range p {
+		items[i] = predicate{p[i]}
+	}
+
+	return items
+}
+
+func filters(f []*eskip.Filter) []item {
+	items := make([]item, len(f))
+	for i := range f {
+		items[i] = filter{f[i]}
+	}
+
+	return items
+}
+
+// AssertRouteEqual compares the canonical form of two routes, see
+// eskip.Canonical(), and reports each different field as a test error. It
+// returns true when the routes are equal.
+func AssertRouteEqual(t testing.TB, got, want *eskip.Route) bool {
+	t.Helper()
+
+	if got == nil || want == nil {
+		if got != want {
+			t.Errorf("invalid route, got: %v, expected: %v", got, want)
+			return false
+		}
+
+		return true
+	}
+
+	g, w := eskip.Canonical(got), eskip.Canonical(want)
+	ok := true
+	if g.Id != w.Id {
+		t.Errorf("invalid route id, got: %s, expected: %s", g.Id, w.Id)
+		ok = false
+	}
+
+	id := w.Id
+	ok = assertItems(t, id, "predicate", predicates(g.Predicates), predicates(w.Predicates)) && ok
+	ok = assertItems(t, id, "filter", filters(g.Filters), filters(w.Filters)) && ok
+
+	if g.Fallback != w.Fallback {
+		t.Errorf("route %s: invalid fallback, got: %t, expected: %t", id, g.Fallback, w.Fallback)
+		ok = false
+	}
+
+	if g.BackendType != w.BackendType {
+		t.Errorf("route %s: invalid backend type, got: %v, expected: %v", id, g.BackendType, w.BackendType)
+		ok = false
+	}
+
+	if g.Backend != w.Backend {
+		t.Errorf("route %s: invalid backend, got: %s, expected: %s", id, g.Backend, w.Backend)
+		ok = false
+	}
+
+	if g.LBAlgorithm != w.LBAlgorithm {
+		t.Errorf("route %s: invalid LB algorithm, got: %s, expected: %s", id, g.LBAlgorithm, w.LBAlgorithm)
+		ok = false
+	}
+
+	if !stringsEqual(g.LBEndpoints, w.LBEndpoints) {
+		t.Errorf("route %s: invalid LB endpoints, got: %v, expected: %v", id, g.LBEndpoints, w.LBEndpoints)
+		ok = false
+	}
+
+	return ok
+}
+
+func routesByID(t testing.TB, kind string, r []*eskip.Route) (map[string]*eskip.Route, bool) {
+	t.Helper()
+
+	m := make(map[string]*eskip.Route)
+	ok := true
+	for _, ri := range r {
+		if _, exists := m[ri.Id]; exists {
+			t.Errorf("duplicate route id in the %s routes: %s", kind, ri.Id)
+			ok = false
+		}
+
+		m[ri.Id] = ri
+	}
+
+	return m, ok
+}
+
+// AssertRoutesEqual compares two lists of routes, matching the routes by
+// their ID, and ignoring the order of the routes. It reports the missing,
+// the unexpected and the duplicate routes, and compares the routes with the
+// same ID with AssertRouteEqual. It returns true when the lists are equal.
+func AssertRoutesEqual(t testing.TB, got, want []*eskip.Route) bool {
+	t.Helper()
+
+	g, gok := routesByID(t, "received", got)
+	w, wok := routesByID(t, "expected", want)
+	ok := gok && wok
+	for _, wr := range want {
+		gr, exists := g[wr.Id]
+		if !exists {
+			t.Errorf("missing route: %s", wr.Id)
+			ok = false
+			continue
+		}
+
+		ok = AssertRouteEqual(t, gr, wr) && ok
+	}
+
+	for _, gr := range got {
+		if _, exists := w[gr.Id]; !exists {
+			t.Errorf("unexpected route: %s", gr.Id)
+			ok = false
+		}
+	}
+
+	return ok
+}
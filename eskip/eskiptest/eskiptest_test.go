@@ -0,0 +1,167 @@
+package eskiptest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zalando/skipper/eskip"
+)
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func parse(t *testing.T, doc string) []*eskip.Route {
+	r, err := eskip.Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestAssertRouteEqual(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		got    string
+		want   string
+		errors []string
+	}{{
+		title: "equal",
+		got:   `r1: Path("/foo") && Method("GET") -> setPath("/") -> "https://www.example.org"`,
+		want:  `r1: Method("GET") && Path("/foo") -> setPath("/") -> "https://www.example.org"`,
+	}, {
+		title: "different fields",
+		got:   `r1: Path("/foo") -> setPath("/") -> status(201) -> <shunt>`,
+		want:  `r2: Path("/bar") -> setPath("/") -> "https://www.example.org"`,
+		errors: []string{
+			"invalid route id, got: r1, expected: r2",
+			`route r2: invalid predicate 0, got: Path("/foo"), expected: Path("/bar")`,
+			"route r2: invalid number of filters, got: 2, expected: 1",
+			"route r2: invalid backend type, got: shunt, expected: network",
+			"route r2: invalid backend, got: , expected: https://www.example.org",
+		},
+	}, {
+		title: "different LB endpoints",
+		got:   `r1: * -> <roundRobin, "http://a.example.org">`,
+		want:  `r1: * -> <random, "http://b.example.org">`,
+		errors: []string{
+			"route r1: invalid LB algorithm, got: roundRobin, expected: random",
+			"route r1: invalid LB endpoints, got: [http://a.example.org], expected: [http://b.example.org]",
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r := &recorder{TB: t}
+			ok := AssertRouteEqual(r, parse(t, test.got)[0], parse(t, test.want)[0])
+			if ok != (len(test.errors) == 0) {
+				t.Error("invalid result")
+			}
+
+			if d := cmp.Diff(test.errors, r.errors); d != "" {
+				t.Error("invalid errors")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestAssertRouteEqualArgTypes(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		got    string
+		want   string
+		errors []string
+	}{{
+		title: "equal binary args",
+		got:   `r1: * -> inlineContent(b64"SGVsbG8=") -> <shunt>`,
+		want:  `r1: * -> inlineContent(b64"SGVsbG8=") -> <shunt>`,
+	}, {
+		title: "different binary args",
+		got:   `r1: * -> inlineContent(b64"SGVsbG8=") -> <shunt>`,
+		want:  `r1: * -> inlineContent(b64"SGk=") -> <shunt>`,
+		errors: []string{
+			`route r1: invalid filter 0, got: inlineContent(b64"SGVsbG8="), expected: inlineContent(b64"SGk=")`,
+		},
+	}, {
+		title: "equal predicate args",
+		got:   `r1: Not(Method("GET")) -> <shunt>`,
+		want:  `r1: Not(Method("GET")) -> <shunt>`,
+	}, {
+		title: "different predicate args",
+		got:   `r1: Not(Method("GET")) -> <shunt>`,
+		want:  `r1: Not(Method("POST")) -> <shunt>`,
+		errors: []string{
+			`route r1: invalid predicate 0, got: Not(Method("GET")), expected: Not(Method("POST"))`,
+		},
+	}, {
+		title: "equal duration and size args",
+		got:   `r1: * -> f(5s, 10MB) -> <shunt>`,
+		want:  `r1: * -> f(5s, 10MB) -> <shunt>`,
+	}, {
+		title: "different duration and size args",
+		got:   `r1: * -> f(5s, 10MB) -> <shunt>`,
+		want:  `r1: * -> f(5m, 10MiB) -> <shunt>`,
+		errors: []string{
+			"route r1: invalid filter 0, got: f(5s, 10MB), expected: f(5m0s, 10MiB)",
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			o := eskip.ParseOptions{TypedLiterals: true}
+			got, err := eskip.ParseWithOptions(test.got, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, err := eskip.ParseWithOptions(test.want, o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &recorder{TB: t}
+			ok := AssertRouteEqual(r, got[0], want[0])
+			if ok != (len(test.errors) == 0) {
+				t.Error("invalid result")
+			}
+
+			if d := cmp.Diff(test.errors, r.errors); d != "" {
+				t.Error("invalid errors")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestAssertRoutesEqual(t *testing.T) {
+	r := &recorder{TB: t}
+	ok := AssertRoutesEqual(
+		r,
+		parse(t, `r1: * -> <shunt>; r2: * -> <shunt>; r4: * -> <shunt>`),
+		parse(t, `r2: * -> <shunt>; r1: * -> <loopback>; r3: * -> <shunt>`),
+	)
+
+	if ok {
+		t.Error("failed to fail")
+	}
+
+	if d := cmp.Diff([]string{
+		"route r1: invalid backend type, got: shunt, expected: loopback",
+		"missing route: r3",
+		"unexpected route: r4",
+	}, r.errors); d != "" {
+		t.Error("invalid errors")
+		t.Log(d)
+	}
+
+	r = &recorder{TB: t}
+	if !AssertRoutesEqual(r, parse(t, `r1: * -> <shunt>; r2: * -> <shunt>`), parse(t, `r2: * -> <shunt>; r1: * -> <shunt>`)) {
+		t.Errorf("unexpected errors: %v", r.errors)
+	}
+}
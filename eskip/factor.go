@@ -0,0 +1,74 @@
+package eskip
+
+func eqPredicate(a, b *Predicate) bool {
+	return a.Name == b.Name && eqArgs(a.Args, b.Args)
+}
+
+// FactorCommonPredicates extracts the predicates that are shared by all the
+// routes in the list. It returns the common predicates, and the canonical
+// copies of the routes without them. Repeated predicates are considered
+// common only as many times as they appear in every route. The input routes
+// are not modified. See also DistributePredicates().
+func FactorCommonPredicates(routes []*Route) (common []*Predicate, rest []*Route) {
+	if len(routes) == 0 {
+		return nil, nil
+	}
+
+	rest = CopyRoutes(routes)
+	used := make([][]bool, len(rest))
+	for i, r := range rest {
+		used[i] = make([]bool, len(r.Predicates))
+	}
+
+	for pi, p := range rest[0].Predicates {
+		matches := []int{pi}
+		for _, r := range rest[1:] {
+			i := len(matches)
+			for j, rp := range r.Predicates {
+				if !used[i][j] && eqPredicate(p, rp) {
+					matches = append(matches, j)
+					break
+				}
+			}
+
+			if len(matches) == i {
+				break
+			}
+		}
+
+		if len(matches) < len(rest) {
+			continue
+		}
+
+		for i, j := range matches {
+			used[i][j] = true
+		}
+
+		common = append(common, CopyPredicate(p))
+	}
+
+	for i, r := range rest {
+		var p []*Predicate
+		for j, pj := range r.Predicates {
+			if !used[i][j] {
+				p = append(p, pj)
+			}
+		}
+
+		r.Predicates = p
+	}
+
+	return common, rest
+}
+
+// DistributePredicates is the inverse of FactorCommonPredicates(): it returns
+// the canonical copies of the routes with the common predicates added to
+// each of them. The input routes are not modified.
+func DistributePredicates(common []*Predicate, routes []*Route) []*Route {
+	c := CopyRoutes(routes)
+	for _, r := range c {
+		r.Predicates = append(CopyPredicates(common), r.Predicates...)
+	}
+
+	return c
+}
@@ -0,0 +1,95 @@
+package eskip
+
+import "testing"
+
+func TestFactorCommonPredicates(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		common string
+		rest   string
+	}{{
+		title: "no routes",
+	}, {
+		title:  "single route",
+		routes: `r1: Host("example.org") && Path("/foo") -> <shunt>`,
+		common: `Host("example.org") && Path("/foo")`,
+		rest:   `r1: * -> <shunt>`,
+	}, {
+		title: "shared host",
+		routes: `
+			r1: Host("example.org") && Path("/foo") -> "https://foo.example.org";
+			r2: Path("/bar") && Host("example.org") -> "https://bar.example.org";
+			r3: Host("example.org") -> <shunt>;
+		`,
+		common: `Host("example.org")`,
+		rest: `
+			r1: Path("/foo") -> "https://foo.example.org";
+			r2: Path("/bar") -> "https://bar.example.org";
+			r3: * -> <shunt>;
+		`,
+	}, {
+		title: "nothing in common",
+		routes: `
+			r1: Host("foo.example.org") -> <shunt>;
+			r2: Host("bar.example.org") -> <shunt>;
+		`,
+		rest: `
+			r1: Host("foo.example.org") -> <shunt>;
+			r2: Host("bar.example.org") -> <shunt>;
+		`,
+	}, {
+		title: "repeated predicates",
+		routes: `
+			r1: Custom("a") && Custom("b") && Custom("a") -> <shunt>;
+			r2: Custom("a") && Custom("b") -> <shunt>;
+		`,
+		common: `Custom("a") && Custom("b")`,
+		rest: `
+			r1: Custom("a") -> <shunt>;
+			r2: * -> <shunt>;
+		`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expectCommon []*Predicate
+			if test.common != "" {
+				expectCommon, err = ParsePredicates(test.common)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expectRest, err := Parse(test.rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := CopyRoutes(r)
+			common, rest := FactorCommonPredicates(r)
+			if !Eq(&Route{Predicates: expectCommon}, &Route{Predicates: common}) {
+				t.Errorf(
+					"invalid common predicates, got: %s, expected: %s",
+					(&Route{Predicates: common}).String(),
+					(&Route{Predicates: expectCommon}).String(),
+				)
+			}
+
+			if !EqLists(expectRest, rest) {
+				t.Errorf("invalid rest, got: %s, expected: %s", Print(PrettyPrintInfo{}, rest...), test.rest)
+			}
+
+			if !EqLists(original, r) {
+				t.Error("the input routes were modified")
+			}
+
+			if !EqLists(r, DistributePredicates(common, rest)) {
+				t.Error("failed to distribute the common predicates")
+			}
+		})
+	}
+}
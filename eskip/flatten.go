@@ -0,0 +1,255 @@
+package eskip
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// the state of a request between the loopbacks, as far as it can be derived
+// from the filters of the routes
+type loopbackRequest struct {
+	// the predicates of the original route, that the request satisfies
+	// unless the filters changed the related part of the request
+	predicates []*Predicate
+
+	// the request, as known from the predicates and the filters
+	input MatchInput
+
+	pathChanged    bool
+	headersChanged map[string]bool
+}
+
+func newLoopbackRequest(r *Route) *loopbackRequest {
+	lr := &loopbackRequest{
+		predicates:     Canonical(r).Predicates,
+		input:          MatchInput{Headers: make(http.Header)},
+		headersChanged: make(map[string]bool),
+	}
+
+	for _, p := range lr.predicates {
+		switch p.Name {
+		case "Path":
+			if a, err := getStringArgs(1, p.Args); err == nil && !strings.ContainsAny(a[0], ":*") {
+				lr.input.Path = a[0]
+			}
+		case "Method":
+			if a, err := getStringArgs(1, p.Args); err == nil {
+				lr.input.Method = a[0]
+			}
+		case "Header":
+			if a, err := getStringArgs(2, p.Args); err == nil {
+				lr.input.Headers.Add(a[0], a[1])
+			}
+		}
+	}
+
+	return lr
+}
+
+// applies the effect of the filters that are known to change the request
+func (lr *loopbackRequest) applyFilters(filters []*Filter) {
+	for _, f := range filters {
+		switch f.Name {
+		case "setPath":
+			lr.pathChanged = true
+			lr.input.Path = ""
+			if a, err := getStringArgs(1, f.Args); err == nil {
+				lr.input.Path = a[0]
+			}
+		case "modPath":
+			lr.pathChanged = true
+			a, err := getStringArgs(2, f.Args)
+			if err != nil {
+				lr.input.Path = ""
+				continue
+			}
+
+			rx, err := compileRegexp(a[0])
+			if err != nil {
+				lr.input.Path = ""
+				continue
+			}
+
+			lr.input.Path = rx.ReplaceAllString(lr.input.Path, a[1])
+		case "setRequestHeader", "appendRequestHeader":
+			a, err := getStringArgs(2, f.Args)
+			if err != nil {
+				continue
+			}
+
+			lr.headersChanged[textproto.CanonicalMIMEHeaderKey(a[0])] = true
+			if f.Name == "setRequestHeader" {
+				lr.input.Headers.Set(a[0], a[1])
+			} else {
+				lr.input.Headers.Add(a[0], a[1])
+			}
+
+			if strings.EqualFold(a[0], "Host") {
+				lr.input.Host = lr.input.Headers.Get("Host")
+			}
+		case "dropRequestHeader":
+			if a, err := getStringArgs(1, f.Args); err == nil {
+				lr.headersChanged[textproto.CanonicalMIMEHeaderKey(a[0])] = true
+				lr.input.Headers.Del(a[0])
+			}
+		}
+	}
+}
+
+// tells whether a predicate of the original route still holds for the
+// request
+func (lr *loopbackRequest) holds(p *Predicate) bool {
+	switch p.Name {
+	case "Path", "PathSubtree", "PathRegexp":
+		if lr.pathChanged {
+			return false
+		}
+	case "Host":
+		if lr.headersChanged["Host"] {
+			return false
+		}
+	case "Header", "HeaderRegexp":
+		if a, err := getStringArgs(2, p.Args); err != nil || lr.headersChanged[textproto.CanonicalMIMEHeaderKey(a[0])] {
+			return false
+		}
+	}
+
+	for _, pi := range lr.predicates {
+		if pi.Name == p.Name && eqArgs(pi.Args, p.Args) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (lr *loopbackRequest) matches(r *Route) bool {
+	for _, p := range Canonical(r).Predicates {
+		if !lr.holds(p) && !matchPredicate(p, lr.input) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// finds the route that the request would loop back into. From the matching
+// routes, it selects the one with the most predicates, as an approximation
+// of the routing priority.
+func (lr *loopbackRequest) target(routes []*Route) *Route {
+	var (
+		t *Route
+		n int
+	)
+
+	for _, r := range routes {
+		if !lr.matches(r) {
+			continue
+		}
+
+		if np := len(Canonical(r).Predicates); t == nil || np > n {
+			t, n = r, np
+		}
+	}
+
+	return t
+}
+
+func flattenRoute(routes []*Route, r *Route, maxDepth int) (*Route, error) {
+	f := Copy(r)
+	if f.BackendType != LoopBackend {
+		return f, nil
+	}
+
+	lr := newLoopbackRequest(r)
+	lr.applyFilters(r.Filters)
+	for depth := 0; f.BackendType == LoopBackend; depth++ {
+		t := lr.target(routes)
+		if t == nil {
+			// the loopback cannot be resolved, the route is kept with
+			// the loopback backend
+			return f, nil
+		}
+
+		if depth >= maxDepth {
+			return nil, fmt.Errorf(
+				"failed to flatten route %s: exceeded the max depth of %d loopbacks",
+				r.Id, maxDepth,
+			)
+		}
+
+		t = Copy(t)
+		f.Filters = append(f.Filters, t.Filters...)
+		f.BackendType = t.BackendType
+		f.Backend = t.Backend
+		f.LBAlgorithm = t.LBAlgorithm
+		f.LBEndpoints = t.LBEndpoints
+		lr.applyFilters(t.Filters)
+	}
+
+	return f, nil
+}
+
+// Flatten returns an approximate view of the routes, where the loopback
+// routes are composed with the routes that they loop back into. The filters
+// of the flattened route are the filters of the loopback route followed by
+// the filters of the target routes, and its backend is the backend of the
+// last target.
+//
+// The target of a loopback is found with a heuristic: a route is considered
+// matching, when each of its predicates is either a predicate of the
+// original route that was not invalidated by the filters, or it matches the
+// request known from the predicates of the original route and from the
+// filters setPath, modPath, setRequestHeader, appendRequestHeader and
+// dropRequestHeader. From the matching routes, the one with the most
+// predicates is selected. When no target is found, the route is returned
+// with its loopback backend.
+//
+// Flatten returns an error when a route exceeds maxDepth loopbacks, which
+// also guards against the loopback cycles. The routes without a loopback
+// backend are returned as canonical copies. The input routes are not
+// modified.
+func Flatten(routes []*Route, maxDepth int) ([]*Route, error) {
+	result := make([]*Route, len(routes))
+	for i, r := range routes {
+		f, err := flattenRoute(routes, r, maxDepth)
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = f
+	}
+
+	return result, nil
+}
+
+// LoopbackGraph returns the IDs of the routes that the loopback routes may
+// loop back into, mapped by the IDs of the loopback routes. The targets are
+// found with the same heuristic as in Flatten, but every matching route is
+// listed, in the order of the input, not only the one with the most
+// predicates. The loopback routes without any matching route are mapped to
+// an empty list. The routes without a loopback backend are not included as
+// keys.
+func LoopbackGraph(routes []*Route) map[string][]string {
+	g := make(map[string][]string)
+	for _, r := range routes {
+		if Canonical(r).BackendType != LoopBackend {
+			continue
+		}
+
+		lr := newLoopbackRequest(r)
+		lr.applyFilters(r.Filters)
+		targets := []string{}
+		for _, t := range routes {
+			if lr.matches(t) {
+				targets = append(targets, t.Id)
+			}
+		}
+
+		g[r.Id] = targets
+	}
+
+	return g
+}
@@ -0,0 +1,166 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFlatten(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		routes   string
+		maxDepth int
+		expect   string
+		err      string
+	}{{
+		title: "no loopbacks",
+		routes: `
+			r1: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+			r2: * -> <shunt>;
+		`,
+		expect: `
+			r1: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+			r2: * -> <shunt>;
+		`,
+	}, {
+		title:    "loopback by path",
+		maxDepth: 3,
+		routes: `
+			r1: Path("/foo") -> setPath("/bar") -> <loopback>;
+			r2: Path("/bar") -> setRequestHeader("X-Bar", "baz") -> "https://bar.example.org";
+			r3: * -> <shunt>;
+		`,
+		expect: `
+			r1: Path("/foo") -> setPath("/bar") -> setRequestHeader("X-Bar", "baz") -> "https://bar.example.org";
+			r2: Path("/bar") -> setRequestHeader("X-Bar", "baz") -> "https://bar.example.org";
+			r3: * -> <shunt>;
+		`,
+	}, {
+		title:    "most specific target with the carried over predicates",
+		maxDepth: 3,
+		routes: `
+			r1: Host(/^www[.]example[.]org$/) && PathSubtree("/api") -> setRequestHeader("X-Loop", "1") -> <loopback>;
+			r2: Host(/^www[.]example[.]org$/) && PathSubtree("/api") && Header("X-Loop", "1") -> status(204) -> <shunt>;
+			r3: * -> <shunt>;
+		`,
+		expect: `
+			r1: Host(/^www[.]example[.]org$/) && PathSubtree("/api") -> setRequestHeader("X-Loop", "1") -> status(204) -> <shunt>;
+			r2: Host(/^www[.]example[.]org$/) && PathSubtree("/api") && Header("X-Loop", "1") -> status(204) -> <shunt>;
+			r3: * -> <shunt>;
+		`,
+	}, {
+		title:    "multiple hops",
+		maxDepth: 3,
+		routes: `
+			r1: Path("/a") -> modPath("^/a", "/b") -> <loopback>;
+			r2: Path("/b") -> setPath("/c") -> <loopback>;
+			r3: PathSubtree("/c") -> "https://c.example.org";
+		`,
+		expect: `
+			r1: Path("/a") -> modPath("^/a", "/b") -> setPath("/c") -> "https://c.example.org";
+			r2: Path("/b") -> setPath("/c") -> "https://c.example.org";
+			r3: PathSubtree("/c") -> "https://c.example.org";
+		`,
+	}, {
+		title:    "unresolved loopback",
+		maxDepth: 3,
+		routes: `
+			r1: Path("/a") -> setPath("/b") -> <loopback>;
+			r2: Path("/c") -> "https://c.example.org";
+		`,
+		expect: `
+			r1: Path("/a") -> setPath("/b") -> <loopback>;
+			r2: Path("/c") -> "https://c.example.org";
+		`,
+	}, {
+		title:    "max depth exceeded",
+		maxDepth: 1,
+		routes: `
+			r1: Path("/a") -> setPath("/b") -> <loopback>;
+			r2: Path("/b") -> setPath("/c") -> <loopback>;
+			r3: Path("/c") -> "https://c.example.org";
+		`,
+		err: "failed to flatten route r1: exceeded the max depth of 1 loopbacks",
+	}, {
+		title:    "cycle",
+		maxDepth: 9,
+		routes: `
+			r1: Path("/a") -> setPath("/b") -> <loopback>;
+			r2: Path("/b") -> setPath("/a") -> <loopback>;
+		`,
+		err: "failed to flatten route r1: exceeded the max depth of 9 loopbacks",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := Flatten(r, test.maxDepth)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("unexpected error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !EqLists(expect, f) {
+				t.Error("invalid flattened routes")
+				t.Log(cmp.Diff(String(CanonicalList(expect)...), String(CanonicalList(f)...)))
+			}
+		})
+	}
+}
+
+func TestFlattenDoesNotModifyInput(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/a") -> setPath("/b") -> <loopback>;
+		r2: Path("/b") -> status(204) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := String(r...)
+	if _, err := Flatten(r, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if String(r...) != s {
+		t.Error("failed to preserve the input routes")
+	}
+}
+
+func TestLoopbackGraph(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/a") -> modPath("^/a", "/b") -> <loopback>;
+		r2: Path("/b") -> setPath("/c") -> <loopback>;
+		r3: PathSubtree("/c") -> "https://c.example.org";
+		r4: * -> <shunt>;
+		r5: Path("/loop") -> setRequestHeader("X-Loop", "1") -> <loopback>;
+		r6: Path("/d") && Header("X-Foo", "bar") -> setPath("/e") -> <loopback>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(map[string][]string{
+		"r1": {"r2", "r4"},
+		"r2": {"r3", "r4"},
+		"r5": {"r4", "r5"},
+		"r6": {"r4"},
+	}, LoopbackGraph(r)); d != "" {
+		t.Error(d)
+	}
+}
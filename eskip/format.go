@@ -0,0 +1,88 @@
+package eskip
+
+import "sort"
+
+// the route in the form used by Format: the canonical predicates are sorted
+// by their name and args, and stored again in the convenience fields where
+// possible, so that they are printed in their dedicated form, e.g. Host with
+// a regexp literal
+func formatRoute(r *Route) *Route {
+	c := NormalizeBackend(Canonical(r), false)
+	predicates := c.Predicates
+	sort.SliceStable(predicates, func(i, j int) bool {
+		if predicates[i].Name != predicates[j].Name {
+			return predicates[i].Name < predicates[j].Name
+		}
+
+		return argsString(predicates[i].Args) < argsString(predicates[j].Args)
+	})
+
+	c.Predicates = nil
+	c.PredicateOrder = nil
+	for _, p := range predicates {
+		if err := applyPredicate(c, p.Name, p.Args, ParseOptions{}); err != nil {
+			c.Predicates = append(c.Predicates, p)
+		}
+	}
+
+	return c
+}
+
+// Format returns the canonical text form of the routes, meant for storing
+// the routing tables e.g. in git, where a stable and reviewable form is
+// needed. The routes are sorted by their ID, and printed in their canonical
+// form, see Canonical(), with the predicates and the headers in a
+// deterministic order, the backend addresses normalized, see
+// NormalizeBackend(), and in the pretty printed format. The load balancer
+// endpoints are sorted. The annotations and the filter comments are kept.
+//
+// Format is idempotent: parsing and formatting its output again results in
+// the same text.
+func Format(routes []*Route) string {
+	f := make([]*Route, len(routes))
+	for i, r := range routes {
+		f[i] = formatRoute(r)
+	}
+
+	sort.SliceStable(f, compareRouteID(f))
+	s := Print(PrettyPrintInfo{Pretty: true, IndentStr: "  "}, f...)
+	if s != "" {
+		s += "\n"
+	}
+
+	return s
+}
+
+func canonicalString(r *Route, includeID bool) string {
+	c := NormalizeBackend(formatRoute(r), true)
+	filters := make([]*Filter, len(c.Filters))
+	for i, f := range c.Filters {
+		filters[i] = &Filter{Name: f.Name, Args: f.Args}
+	}
+
+	c.Filters = filters
+	s := c.Print(PrettyPrintInfo{})
+	if includeID {
+		s = c.Id + ": " + s
+	}
+
+	return s
+}
+
+// CanonicalString returns the canonical, single-line text form of the route
+// expression, without the route ID. Routes that are equal by Eq() produce
+// the same string, so it can be used e.g. as a key in deduplication. The
+// predicates are printed in their canonical form, sorted by their name and
+// args, the headers are sorted, the backend address is normalized, and the
+// load balancer endpoints are sorted. The annotations and the filter
+// comments are omitted. See also CanonicalDefinitionString().
+func (r *Route) CanonicalString() string {
+	return canonicalString(r, false)
+}
+
+// CanonicalDefinitionString returns the same as CanonicalString(), but
+// prefixed with the route ID, in the form of a route definition without the
+// closing semicolon.
+func (r *Route) CanonicalDefinitionString() string {
+	return canonicalString(r, true)
+}
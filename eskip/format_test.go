@@ -0,0 +1,109 @@
+package eskip
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	const (
+		input = `
+			// @team=payments
+			b:   Header("X-B", "2")&&Method("GET") && Path("/foo") && Custom(2) && Custom(1) &&
+			     HeaderRegexp("X-A", /b/) && Host(/^www[.]example[.]org$/)
+			     -> setPath("/") // keep the comment
+			     -> status(200) -> "HTTPS://WWW.Example.org:443/foo";
+			a: * -> <roundRobin, "http://b.example.org:80", "http://a.example.org">;
+			c: Fallback() -> <shunt>
+		`
+
+		expect = `a: *
+  -> <roundRobin, "http://a.example.org", "http://b.example.org">;
+
+// @team=payments
+b: Path("/foo") && Host(/^www[.]example[.]org$/) && Method("GET") && HeaderRegexp("X-A", /b/) && Header("X-B", "2") && Custom(1) && Custom(2)
+  -> setPath("/") // keep the comment
+  -> status(200)
+  -> "https://www.example.org/foo";
+
+c: Fallback()
+  -> <shunt>;
+`
+	)
+
+	r, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := Format(r)
+	if s != expect {
+		t.Fatalf("invalid format, got:\n%s\nexpected:\n%s", s, expect)
+	}
+
+	rr, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := Format(rr); f != s {
+		t.Errorf("format is not idempotent, got:\n%s\nexpected:\n%s", f, s)
+	}
+
+	if r[0].Id != "b" || r[0].Backend != "HTTPS://WWW.Example.org:443/foo" {
+		t.Error("the input routes were modified")
+	}
+}
+
+func TestFormatEmpty(t *testing.T) {
+	if s := Format(nil); s != "" {
+		t.Errorf("unexpected output: %s", s)
+	}
+}
+
+func TestCanonicalString(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect string
+	}{{
+		title:  "catch-all",
+		routes: `r1: * -> <shunt>`,
+		expect: `* -> <shunt>`,
+	}, {
+		title: "equal routes in different forms",
+		routes: `
+			r1: Method("GET") && Header("X-B", "2") && Header("X-A", "1") && Path("/foo") && Custom("b") && Custom("a")
+			  -> setPath("/") // comment
+			  -> <roundRobin, "HTTPS://B.example.org:443", "https://a.example.org">;
+
+			// @team=foo
+			r2: Custom("a") && Path("/foo") && Header("X-A", "1") && Header("X-B", "2") && Custom("b") && Method("GET")
+			  -> setPath("/")
+			  -> <roundRobin, "https://a.example.org", "https://b.example.org">;
+		`,
+		expect: `Path("/foo") && Method("GET") && Header("X-A", "1") && Header("X-B", "2") && Custom("a") && Custom("b")` +
+			` -> setPath("/") -> <roundRobin, "https://a.example.org", "https://b.example.org">`,
+	}, {
+		title: "network backend",
+		routes: `
+			r1: PathRegexp(/^\/foo/) && Host(/^www[.]example[.]org$/) -> "HTTPS://WWW.example.org:443";
+			r2: Host(/^www[.]example[.]org$/) && PathRegexp(/^\/foo/) -> "https://www.example.org";
+		`,
+		expect: `Host(/^www[.]example[.]org$/) && PathRegexp(/^\/foo/) -> "https://www.example.org"`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, ri := range r {
+				if s := ri.CanonicalString(); s != test.expect {
+					t.Errorf("invalid canonical string of %s, got: %s, expected: %s", ri.Id, s, test.expect)
+				}
+
+				if s := ri.CanonicalDefinitionString(); s != ri.Id+": "+test.expect {
+					t.Errorf("invalid canonical definition string of %s: %s", ri.Id, s)
+				}
+			}
+		})
+	}
+}
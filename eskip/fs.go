@@ -0,0 +1,83 @@
+package eskip
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// FileErrors contains the errors of the files that failed to load or parse,
+// by the paths of the files.
+type FileErrors map[string]error
+
+func (e FileErrors) Error() string {
+	files := make([]string, 0, len(e))
+	for f := range e {
+		files = append(files, f)
+	}
+
+	sort.Strings(files)
+	s := make([]string, len(files))
+	for i, f := range files {
+		s[i] = fmt.Sprintf("%s: %v", f, e[f])
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// ParseFS parses the routes from every file of the file system matching
+// the glob, in the format of fs.Glob, e.g. routes/*.eskip. It works with
+// any fs.FS implementation, e.g. os.DirFS, embed.FS or a zip archive opened
+// with archive/zip. The routes are returned in the order of the file paths
+// and in the order of the routes within the files, and each route is
+// annotated with the path of its file, see SourceFileAnnotation.
+//
+// When some of the files fail to load or parse, the other files are still
+// parsed, to report every error, and ParseFS returns a FileErrors error
+// without routes. It returns an error, too, when the glob is invalid.
+func ParseFS(fsys fs.FS, glob string) ([]*Route, error) {
+	files, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		routes []*Route
+		errs   = make(FileErrors)
+	)
+
+	for _, f := range files {
+		if info, err := fs.Stat(fsys, f); err == nil && info.IsDir() {
+			continue
+		}
+
+		b, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			errs[f] = err
+			continue
+		}
+
+		r, err := Parse(string(b))
+		if err != nil {
+			errs[f] = err
+			continue
+		}
+
+		for _, ri := range r {
+			if ri.Annotations == nil {
+				ri.Annotations = make(map[string]string)
+			}
+
+			ri.Annotations[SourceFileAnnotation] = f
+		}
+
+		routes = append(routes, r...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,122 @@
+package eskip
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"routes/b.eskip":        {Data: []byte(`b1: Path("/b") -> <shunt>;`)},
+		"routes/a.eskip":        {Data: []byte("// @team=payments\na1: Path(\"/a\") -> <shunt>;\na2: * -> <shunt>;")},
+		"routes/readme.md":      {Data: []byte("# routes")},
+		"routes/dir.eskip/c":    {Data: []byte(`c1: * -> <shunt>;`)},
+		"routes/nested/d.eskip": {Data: []byte(`d1: * -> <shunt>;`)},
+	}
+
+	sources := func(r []*Route) []string {
+		var s []string
+		for _, ri := range r {
+			s = append(s, ri.Id+" "+ri.Annotations[SourceFileAnnotation])
+		}
+
+		return s
+	}
+
+	t.Run("map fs", func(t *testing.T) {
+		r, err := ParseFS(fsys, "routes/*.eskip")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff([]string{
+			"a1 routes/a.eskip",
+			"a2 routes/a.eskip",
+			"b1 routes/b.eskip",
+		}, sources(r)); d != "" {
+			t.Error(d)
+		}
+
+		if r[0].Annotations["team"] != "payments" {
+			t.Error("failed to keep the annotations")
+		}
+
+		rr, err := Parse(String(r...))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff(r, rr); d != "" {
+			t.Errorf("failed to round-trip the source annotations: %s", d)
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := zip.NewWriter(&buf)
+		for _, name := range []string{"routes/a.eskip", "routes/b.eskip"} {
+			f, err := w.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := f.Write(fsys[name].Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := ParseFS(zr, "routes/*.eskip")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff([]string{
+			"a1 routes/a.eskip",
+			"a2 routes/a.eskip",
+			"b1 routes/b.eskip",
+		}, sources(r)); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("errors per file", func(t *testing.T) {
+		invalid := fstest.MapFS{
+			"a.eskip": {Data: []byte(`a1: Path("/a") -> ;`)},
+			"b.eskip": {Data: []byte(`b1: * -> <shunt>;`)},
+			"c.eskip": {Data: []byte(`c1: * -> <shunt`)},
+		}
+
+		r, err := ParseFS(invalid, "*.eskip")
+		if r != nil {
+			t.Error("unexpected routes")
+		}
+
+		errs, ok := err.(FileErrors)
+		if !ok {
+			t.Fatalf("failed to fail with the file errors: %v", err)
+		}
+
+		if len(errs) != 2 || errs["a.eskip"] == nil || errs["c.eskip"] == nil {
+			t.Errorf("invalid file errors: %v", errs)
+		}
+	})
+
+	t.Run("invalid glob", func(t *testing.T) {
+		if _, err := ParseFS(fsys, "routes/[.eskip"); err == nil {
+			t.Error("failed to fail")
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package eskip
+
+import (
+	"fmt"
+	"sort"
+)
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func headersToPredicates(headers map[string]string, headerRegexps map[string][]string) []*Predicate {
+	all := make(map[string][]string)
+	for k := range headers {
+		all[k] = nil
+	}
+
+	for k := range headerRegexps {
+		all[k] = nil
+	}
+
+	var p []*Predicate
+	for _, k := range sortedKeys(all) {
+		if v, ok := headers[k]; ok {
+			p = append(p, &Predicate{Name: "Header", Args: []interface{}{k, v}})
+		}
+
+		values := make([]string, len(headerRegexps[k]))
+		copy(values, headerRegexps[k])
+		sort.Strings(values)
+		for _, v := range values {
+			p = append(p, &Predicate{Name: "HeaderRegexp", Args: []interface{}{k, v}})
+		}
+	}
+
+	return p
+}
+
+func predicatesToHeaders(p []*Predicate) (map[string]string, map[string][]string, error) {
+	var (
+		headers       map[string]string
+		headerRegexps map[string][]string
+	)
+
+	for _, pi := range p {
+		switch pi.Name {
+		case "Header":
+			args, err := getStringArgs(2, pi.Args)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if _, ok := headers[args[0]]; ok {
+				return nil, nil, fmt.Errorf(duplicateHeaderPredicateErrorFmt, args[0])
+			}
+
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+
+			headers[args[0]] = args[1]
+		case "HeaderRegexp":
+			args, err := getStringArgs(2, pi.Args)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if headerRegexps == nil {
+				headerRegexps = make(map[string][]string)
+			}
+
+			headerRegexps[args[0]] = append(headerRegexps[args[0]], args[1])
+		}
+	}
+
+	return headers, headerRegexps, nil
+}
+
+// HeaderPredicates returns the Header and HeaderRegexp predicates, that are
+// stored in the Headers and HeaderRegexps fields of the route, in a stable
+// order: sorted by the header name, and for the same header name, the
+// Header predicate first, followed by the HeaderRegexp predicates sorted by
+// their regular expression. The predicates in the Predicates field of the
+// route are not included.
+func HeaderPredicates(r *Route) []*Predicate {
+	return headersToPredicates(r.Headers, r.HeaderRegexps)
+}
+
+// HeadersFromPredicates is the inverse of HeaderPredicates. It collects the
+// Header and HeaderRegexp predicates from the input, in the form of the
+// Headers and HeaderRegexps fields of a route, and ignores the rest of the
+// predicates. It returns an error when the arguments of a header predicate
+// are invalid, or when the input contains multiple Header predicates with
+// the same header name.
+func HeadersFromPredicates(p []*Predicate) (headers map[string]string, headerRegexps map[string][]string, err error) {
+	return predicatesToHeaders(p)
+}
+
+func headerSortKey(p *Predicate) (key, value string) {
+	if len(p.Args) > 0 {
+		key = fmt.Sprint(p.Args[0])
+	}
+
+	if len(p.Args) > 1 {
+		value = fmt.Sprint(p.Args[1])
+	}
+
+	return
+}
+
+// StableHeaderPredicates returns all the Header and HeaderRegexp predicates
+// of the route, both from the Headers and HeaderRegexps fields and from the
+// generic Predicates, sorted by the header name, then by the value, and then
+// by the predicate name. The order doesn't depend on the map iteration or on
+// the order of the predicates in the route, therefore it can be used e.g. to
+// build a stable signature of the route.
+func StableHeaderPredicates(r *Route) []*Predicate {
+	p := HeaderPredicates(r)
+	for _, pi := range r.Predicates {
+		if pi.Name == "Header" || pi.Name == "HeaderRegexp" {
+			p = append(p, pi)
+		}
+	}
+
+	sort.SliceStable(p, func(i, j int) bool {
+		ki, vi := headerSortKey(p[i])
+		kj, vj := headerSortKey(p[j])
+		switch {
+		case ki != kj:
+			return ki < kj
+		case vi != vj:
+			return vi < vj
+		default:
+			return p[i].Name < p[j].Name
+		}
+	})
+
+	return p
+}
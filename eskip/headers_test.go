@@ -0,0 +1,131 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHeaderPredicates(t *testing.T) {
+	r := &Route{
+		Headers: map[string]string{
+			"X-B": "b",
+			"X-A": "a",
+		},
+		HeaderRegexps: map[string][]string{
+			"X-C": {"^c2", "^c1"},
+			"X-A": {"^a"},
+		},
+		Predicates: []*Predicate{{Name: "Traffic", Args: []interface{}{.3}}},
+	}
+
+	expect := []*Predicate{
+		{Name: "Header", Args: []interface{}{"X-A", "a"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-A", "^a"}},
+		{Name: "Header", Args: []interface{}{"X-B", "b"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-C", "^c1"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-C", "^c2"}},
+	}
+
+	p := HeaderPredicates(r)
+	if d := cmp.Diff(expect, p); d != "" {
+		t.Error("invalid header predicates")
+		t.Log(d)
+	}
+
+	if d := cmp.Diff([]string{"^c2", "^c1"}, r.HeaderRegexps["X-C"]); d != "" {
+		t.Error("the route was modified")
+		t.Log(d)
+	}
+
+	h, hrx, err := HeadersFromPredicates(append(p, r.Predicates...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(r.Headers, h); d != "" {
+		t.Error("failed to convert the header predicates")
+		t.Log(d)
+	}
+
+	if d := cmp.Diff(map[string][]string{"X-C": {"^c1", "^c2"}, "X-A": {"^a"}}, hrx); d != "" {
+		t.Error("failed to convert the header regexp predicates")
+		t.Log(d)
+	}
+}
+
+func TestHeadersFromPredicatesErrors(t *testing.T) {
+	for _, test := range []struct {
+		title      string
+		predicates []*Predicate
+		err        string
+	}{{
+		title: "duplicate header",
+		predicates: []*Predicate{
+			{Name: "Header", Args: []interface{}{"X-A", "a"}},
+			{Name: "Header", Args: []interface{}{"X-A", "b"}},
+		},
+		err: "duplicate header predicate: X-A",
+	}, {
+		title:      "invalid args",
+		predicates: []*Predicate{{Name: "HeaderRegexp", Args: []interface{}{"X-A"}}},
+		err:        invalidPredicateArgCountError.Error(),
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, _, err := HeadersFromPredicates(test.predicates)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("unexpected error, got: %v, expected: %s", err, test.err)
+			}
+		})
+	}
+}
+
+func TestHeaderPredicatesStableString(t *testing.T) {
+	r, err := Parse(`
+		Header("X-C", "c") && Header("X-A", "a") && Header("X-B", "b") &&
+		HeaderRegexp("X-A", /^b/) && HeaderRegexp("X-A", /^a/) -> <shunt>
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expect = `Header("X-A", "a") && HeaderRegexp("X-A", /^a/) && HeaderRegexp("X-A", /^b/) && ` +
+		`Header("X-B", "b") && Header("X-C", "c") -> <shunt>`
+	for i := 0; i < 10; i++ {
+		if s := r[0].String(); s != expect {
+			t.Fatalf("invalid route string, got: %s, expected: %s", s, expect)
+		}
+	}
+}
+
+func TestStableHeaderPredicates(t *testing.T) {
+	r := &Route{
+		Headers:       map[string]string{"X-C": "c", "X-A": "z"},
+		HeaderRegexps: map[string][]string{"X-A": {"^b", "^a"}, "X-B": {"b"}},
+		Predicates: []*Predicate{
+			{Name: "Path", Args: []interface{}{"/foo"}},
+			{Name: "Header", Args: []interface{}{"X-B", "b"}},
+			{Name: "HeaderRegexp", Args: []interface{}{"X-A", "^c"}},
+		},
+	}
+
+	expect := []*Predicate{
+		{Name: "HeaderRegexp", Args: []interface{}{"X-A", "^a"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-A", "^b"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-A", "^c"}},
+		{Name: "Header", Args: []interface{}{"X-A", "z"}},
+		{Name: "Header", Args: []interface{}{"X-B", "b"}},
+		{Name: "HeaderRegexp", Args: []interface{}{"X-B", "b"}},
+		{Name: "Header", Args: []interface{}{"X-C", "c"}},
+	}
+
+	for i := 0; i < 10; i++ {
+		if d := cmp.Diff(expect, StableHeaderPredicates(r)); d != "" {
+			t.Fatal(d)
+		}
+	}
+
+	if len(r.Predicates) != 3 || r.Predicates[1].Name != "Header" {
+		t.Error("the route was modified")
+	}
+}
@@ -0,0 +1,170 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splits a regexp on the | operators that are not escaped and not inside a
+// group or a character class
+func splitAlternatives(rx string) []string {
+	var (
+		alts    []string
+		start   int
+		depth   int
+		inClass bool
+		escaped bool
+	)
+
+	for i := 0; i < len(rx); i++ {
+		c := rx[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == escapeChar:
+			escaped = true
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '|' && depth == 0:
+			alts = append(alts, rx[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(alts, rx[start:])
+}
+
+// tells whether the regexp is a single group, e.g. (a|b), that encloses the
+// whole expression
+func isEnclosingGroup(rx string) bool {
+	if !strings.HasPrefix(rx, "(") || !strings.HasSuffix(rx, ")") {
+		return false
+	}
+
+	// the parens at the start and at the end need to be the same group:
+	inner := rx[1 : len(rx)-1]
+	depth := 0
+	escaped, inClass := false, false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == escapeChar:
+			escaped = true
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+
+	return depth == 0 && !escaped
+}
+
+// returns the alternatives of a host regexp, each of them a standalone
+// regexp. Either the top level alternatives are used, e.g. ^a$|^b$, or the
+// alternatives of a group enclosing the whole expression, e.g. ^(a|b)$. The
+// regexp is not validated.
+func hostAlternatives(rx string) []string {
+	if alts := splitAlternatives(rx); len(alts) > 1 {
+		return alts
+	}
+
+	prefix, suffix, group := "", "", rx
+	if strings.HasPrefix(group, "^") {
+		prefix, group = "^", group[1:]
+	}
+
+	if strings.HasSuffix(group, "$") && !strings.HasSuffix(group, `\$`) {
+		suffix, group = "$", group[:len(group)-1]
+	}
+
+	if !isEnclosingGroup(group) {
+		return []string{rx}
+	}
+
+	group = strings.TrimPrefix(group[1:len(group)-1], "?:")
+	alts := splitAlternatives(group)
+	for i := range alts {
+		alts[i] = prefix + alts[i] + suffix
+	}
+
+	return alts
+}
+
+// SplitByHost splits a route with a host constraint matching multiple hosts
+// into a route for each host, e.g. for per-host metrics. The host
+// constraint is the single Host predicate of the route, either in the
+// HostRegexps field or in the generic Predicates, and its regexp is split
+// by its alternatives, either at the top level, e.g.
+// Host(/^www[.]example[.]org$|^api[.]example[.]org$/), or in a group
+// enclosing the whole expression, e.g.
+// Host(/^(www[.]example[.]org|api[.]example[.]org)$/). The alternatives
+// in a partial group, e.g. in Host(/^(www|api)[.]example[.]org$/), are not
+// split.
+//
+// The returned routes are deep copies of the input route, with their host
+// constraint replaced by one of the alternatives, and with the ID suffixed
+// by the index of the alternative, e.g. route1_0 and route1_1. When the
+// host regexp has no alternatives, a single copy is returned with the
+// original ID. It returns an error when the route has no host constraint,
+// or it has multiple, because then each of them needs to match, and they
+// cannot be split.
+func SplitByHost(r *Route) ([]*Route, error) {
+	hostPredicate := -1
+	hosts := r.HostRegexps
+	for i, p := range r.Predicates {
+		if p.Name != "Host" {
+			continue
+		}
+
+		a, err := getStringArgs(1, p.Args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid host predicate in route %s: %w", r.Id, err)
+		}
+
+		hostPredicate = i
+		hosts = append(hosts[:len(hosts):len(hosts)], a[0])
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("route %s has no host constraint to split on", r.Id)
+	}
+
+	if len(hosts) > 1 {
+		return nil, fmt.Errorf("route %s has multiple host constraints", r.Id)
+	}
+
+	alts := hostAlternatives(hosts[0])
+	routes := make([]*Route, len(alts))
+	for i, h := range alts {
+		c := r.Copy()
+		if hostPredicate >= 0 {
+			c.Predicates[hostPredicate].Args = []interface{}{h}
+		} else {
+			c.HostRegexps = []string{h}
+		}
+
+		if len(alts) > 1 {
+			c.Id = fmt.Sprintf("%s_%d", r.Id, i)
+		}
+
+		routes[i] = c
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,118 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitByHost(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		route  *Route
+		expect string
+		err    string
+	}{{
+		title: "top level alternatives",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^www[.]example[.]org$|^api[.]example[.]org$`},
+			Path:        "/foo",
+			Filters:     []*Filter{{Name: "setPath", Args: []interface{}{"/"}}},
+			Backend:     "https://backend.example.org",
+		},
+		expect: `
+			r1_0: Host(/^www[.]example[.]org$/) && Path("/foo") -> setPath("/") -> "https://backend.example.org";
+			r1_1: Host(/^api[.]example[.]org$/) && Path("/foo") -> setPath("/") -> "https://backend.example.org";
+		`,
+	}, {
+		title: "enclosing group",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^(?:www[.]example[.]org|api[.]example[.]org|(a|b)[.]example[.]org)$`},
+			BackendType: ShuntBackend,
+		},
+		expect: `
+			r1_0: Host(/^www[.]example[.]org$/) -> <shunt>;
+			r1_1: Host(/^api[.]example[.]org$/) -> <shunt>;
+			r1_2: Host(/^(a|b)[.]example[.]org$/) -> <shunt>;
+		`,
+	}, {
+		title: "partial group not split",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^(www|api)[.]example[.]org$`},
+			BackendType: ShuntBackend,
+		},
+		expect: `r1: Host(/^(www|api)[.]example[.]org$/) -> <shunt>;`,
+	}, {
+		title: "separate groups not split",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^(www|api)[.](example|test)$`},
+			BackendType: ShuntBackend,
+		},
+		expect: `r1: Host(/^(www|api)[.](example|test)$/) -> <shunt>;`,
+	}, {
+		title: "alternatives in a class or escaped not split",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^www[|]example\|org$`},
+			BackendType: ShuntBackend,
+		},
+		expect: `r1: Host(/^www[|]example\|org$/) -> <shunt>;`,
+	}, {
+		title: "generic predicate",
+		route: &Route{
+			Id:          "r1",
+			Predicates:  []*Predicate{{Name: "Host", Args: []interface{}{`^www[.]example[.]org$|^api[.]example[.]org$`}}},
+			BackendType: ShuntBackend,
+		},
+		expect: `
+			r1_0: Host(/^www[.]example[.]org$/) -> <shunt>;
+			r1_1: Host(/^api[.]example[.]org$/) -> <shunt>;
+		`,
+	}, {
+		title: "no host",
+		route: &Route{Id: "r1", Path: "/foo", BackendType: ShuntBackend},
+		err:   "route r1 has no host constraint to split on",
+	}, {
+		title: "multiple hosts",
+		route: &Route{
+			Id:          "r1",
+			HostRegexps: []string{`^www[.]example[.]org$`},
+			Predicates:  []*Predicate{{Name: "Host", Args: []interface{}{`example`}}},
+			BackendType: ShuntBackend,
+		},
+		err: "route r1 has multiple host constraints",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			original := test.route.Copy()
+			r, err := SplitByHost(test.route)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("failed to fail with the right error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !EqLists(r, expect) {
+				t.Errorf("invalid routes, got: %s, expected: %s", String(r...), String(expect...))
+			}
+
+			if d := cmp.Diff(original, test.route); d != "" {
+				t.Errorf("the input route was modified: %s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RewriteIDs sets the ID of each route to the value returned by the
+// transform function for the current ID, e.g. to add a prefix to the IDs of
+// the routes imported from another namespace. The routes are updated in
+// place. To keep the original routes, pass in a copy of them, see
+// CopyRoutes().
+//
+// When the transform produces the same ID for more than one route, RewriteIDs
+// returns an error listing every colliding ID, with the original IDs of the
+// routes, and doesn't change any of the routes.
+func RewriteIDs(routes []*Route, transform func(old string) string) error {
+	ids := make([]string, len(routes))
+	original := make(map[string][]string)
+	var collisions []string
+	for i, r := range routes {
+		id := transform(r.Id)
+		if len(original[id]) == 1 {
+			collisions = append(collisions, id)
+		}
+
+		original[id] = append(original[id], r.Id)
+		ids[i] = id
+	}
+
+	if len(collisions) > 0 {
+		c := make([]string, len(collisions))
+		for i, id := range collisions {
+			c[i] = fmt.Sprintf("%s (from %s)", id, strings.Join(original[id], ", "))
+		}
+
+		return fmt.Errorf("duplicate route ids after rewrite: %s", strings.Join(c, ", "))
+	}
+
+	for i, r := range routes {
+		r.Id = ids[i]
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package eskip
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRewriteIDs(t *testing.T) {
+	const code = `
+		foo: Path("/foo") -> <shunt>;
+		bar: Path("/bar") -> <shunt>;
+		bar_v2: Path("/bar/v2") -> <shunt>;
+		baz: Path("/baz") -> <shunt>;
+		baz_v2: Path("/baz/v2") -> <shunt>;
+		bar_v3: Path("/bar/v3") -> <shunt>;
+	`
+
+	t.Run("prefix", func(t *testing.T) {
+		r, err := Parse(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := RewriteIDs(r, func(id string) string { return "team_" + id }); err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff([]string{"team_foo", "team_bar", "team_bar_v2", "team_baz", "team_baz_v2", "team_bar_v3"}, routeIDs(r)); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("collisions", func(t *testing.T) {
+		r, err := Parse(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = RewriteIDs(r, func(id string) string { return strings.Split(id, "_")[0] })
+		if err == nil || err.Error() !=
+			"duplicate route ids after rewrite: bar (from bar, bar_v2, bar_v3), baz (from baz, baz_v2)" {
+			t.Errorf("failed to fail with the right error: %v", err)
+		}
+
+		if d := cmp.Diff([]string{"foo", "bar", "bar_v2", "baz", "baz_v2", "bar_v3"}, routeIDs(r)); d != "" {
+			t.Error("the routes were modified")
+			t.Log(d)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package eskip
+
+// stores a single instance of the identical strings, used during a single
+// parse, when the InternStrings option is set
+type internTable map[string]string
+
+func (t internTable) intern(s string) string {
+	if i, ok := t[s]; ok {
+		return i
+	}
+
+	t[s] = s
+	return s
+}
+
+func (t internTable) internStrings(s []string) {
+	for i := range s {
+		s[i] = t.intern(s[i])
+	}
+}
+
+func (t internTable) internArgs(a []interface{}) {
+	for i := range a {
+		if s, ok := a[i].(string); ok {
+			a[i] = t.intern(s)
+		}
+	}
+}
+
+func (t internTable) internMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	im := make(map[string]string, len(m))
+	for k, v := range m {
+		im[t.intern(k)] = t.intern(v)
+	}
+
+	return im
+}
+
+func (t internTable) internMultiMap(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string][]string, len(m))
+	for k, v := range m {
+		t.internStrings(v)
+		c[t.intern(k)] = v
+	}
+
+	return c
+}
+
+func (t internTable) internRoute(r *Route) {
+	r.Path = t.intern(r.Path)
+	r.Method = t.intern(r.Method)
+	r.Backend = t.intern(r.Backend)
+	r.LBAlgorithm = t.intern(r.LBAlgorithm)
+	t.internStrings(r.HostRegexps)
+	t.internStrings(r.PathRegexps)
+	t.internStrings(r.LBEndpoints)
+	t.internStrings(r.PredicateOrder)
+	r.Headers = t.internMap(r.Headers)
+	r.Annotations = t.internMap(r.Annotations)
+
+	t.internStrings(r.QueryParams)
+	r.HeaderRegexps = t.internMultiMap(r.HeaderRegexps)
+	r.QueryParamRegexps = t.internMultiMap(r.QueryParamRegexps)
+	r.Cookies = t.internMultiMap(r.Cookies)
+
+	for _, p := range r.Predicates {
+		p.Name = t.intern(p.Name)
+		t.internArgs(p.Args)
+	}
+
+	for _, f := range r.Filters {
+		f.Name = t.intern(f.Name)
+		t.internArgs(f.Args)
+	}
+}
@@ -0,0 +1,84 @@
+package eskip
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func largeRoutingDocument(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(
+			&b,
+			`route%d: Path("/api/%d") && Method("GET") && Header("X-Tenant", "tenant-a") && HeaderRegexp("Accept", "json") `+
+				`-> setRequestHeader("X-Forwarded-Service", "api-gateway") -> ratelimit(20, "1m") `+
+				`-> <roundRobin, "https://backend-a.example.org", "https://backend-b.example.org">;`+"\n",
+			i, i,
+		)
+	}
+
+	return b.String()
+}
+
+func TestInternStrings(t *testing.T) {
+	doc := largeRoutingDocument(3) + `
+		// @team=payments
+		r: Host("^example[.]org$") && PathRegexp("^/foo") && Custom("tenant-a", 42) -> "https://backend-a.example.org";
+	`
+
+	expect, err := Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ParseWithOptions(doc, ParseOptions{InternStrings: true, PredicateOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ri := range r {
+		ri.PredicateOrder = nil
+	}
+
+	if d := cmp.Diff(expect, r); d != "" {
+		t.Error("interning the strings changed the routes")
+		t.Log(d)
+	}
+}
+
+func benchmarkParseRetained(b *testing.B, o ParseOptions) {
+	doc := largeRoutingDocument(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var retained uint64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		r, err := ParseWithOptions(doc, o)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained += after.HeapAlloc - before.HeapAlloc
+		runtime.KeepAlive(r)
+	}
+
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	benchmarkParseRetained(b, ParseOptions{})
+}
+
+func BenchmarkParseLargeInternStrings(b *testing.B) {
+	benchmarkParseRetained(b, ParseOptions{InternStrings: true})
+}
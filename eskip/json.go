@@ -2,7 +2,11 @@ package eskip
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
 )
 
 func marshalJsonPredicates(r *Route) []*Predicate {
@@ -36,32 +40,84 @@ func marshalJsonPredicates(r *Route) []*Predicate {
 		})
 	}
 
-	for k, v := range r.Headers {
-		rjf = append(rjf, &Predicate{
-			Name: "Header",
-			Args: []interface{}{k, v},
-		})
-	}
+	rjf = append(rjf, HeaderPredicates(r)...)
 
-	for k, list := range r.HeaderRegexps {
-		for _, v := range list {
-			rjf = append(rjf, &Predicate{
-				Name: "HeaderRegexp",
-				Args: []interface{}{k, v},
-			})
-		}
-	}
+	rjf = append(rjf, QueryParamPredicates(r)...)
+
+	rjf = append(rjf, CookiePredicates(r)...)
 
 	rjf = append(rjf, r.Predicates...)
 
+	if r.Fallback {
+		rjf = append(rjf, &Predicate{Name: "Fallback"})
+	}
+
 	return rjf
 }
 
-func marshalNameArgs(name string, args []interface{}) ([]byte, error) {
+// the JSON representation of the binary args, e.g.
+// {"type": "base64", "value": "SGVsbG8="}
+type jsonBinaryArg struct {
+	Type  string `json:"type"`
+	Value []byte `json:"value"`
+}
+
+const jsonBinaryArgType = "base64"
+
+func marshalJSONArgs(args []interface{}) []interface{} {
 	if args == nil {
-		args = []interface{}{}
+		return []interface{}{}
+	}
+
+	var margs []interface{}
+	for i, a := range args {
+		b, ok := a.([]byte)
+		if !ok {
+			continue
+		}
+
+		if margs == nil {
+			margs = make([]interface{}, len(args))
+			copy(margs, args)
+		}
+
+		margs[i] = jsonBinaryArg{Type: jsonBinaryArgType, Value: b}
+	}
+
+	if margs == nil {
+		return args
+	}
+
+	return margs
+}
+
+// restores the binary args from the JSON representation
+func unmarshalJSONArgs(args []interface{}) error {
+	for i, a := range args {
+		m, ok := a.(map[string]interface{})
+		if !ok || m["type"] != jsonBinaryArgType {
+			continue
+		}
+
+		v, ok := m["value"].(string)
+		if !ok {
+			return invalidBase64
+		}
+
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return invalidBase64
+		}
+
+		args[i] = b
 	}
 
+	return nil
+}
+
+func marshalNameArgs(name string, args []interface{}) ([]byte, error) {
+	args = marshalJSONArgs(args)
+
 	return json.Marshal(&struct {
 		Name string        `json:"name"`
 		Args []interface{} `json:"args"`
@@ -79,31 +135,180 @@ func (p *Predicate) MarshalJSON() ([]byte, error) {
 	return marshalNameArgs(p.Name, p.Args)
 }
 
-func (r *Route) MarshalJSON() ([]byte, error) {
-	backend := r.backendString()
+type jsonRoute struct {
+	Id          string            `json:"id"`
+	Backend     string            `json:"backend"`
+	Predicates  []*Predicate      `json:"predicates"`
+	Filters     []*Filter         `json:"filters"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
 
+func newJSONRoute(r *Route) *jsonRoute {
 	filters := r.Filters
 	if filters == nil {
 		filters = []*Filter{}
 	}
 
+	return &jsonRoute{
+		Id:          r.Id,
+		Backend:     r.backendString(),
+		Predicates:  marshalJsonPredicates(r),
+		Filters:     filters,
+		Annotations: routeAnnotations(r),
+	}
+}
+
+func (r *Route) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e := json.NewEncoder(&buf)
 	e.SetEscapeHTML(false)
 
-	if err := e.Encode(&struct {
-		Id         string       `json:"id"`
-		Backend    string       `json:"backend"`
-		Predicates []*Predicate `json:"predicates"`
-		Filters    []*Filter    `json:"filters"`
-	}{
-		Id:         r.Id,
-		Backend:    backend,
-		Predicates: marshalJsonPredicates(r),
-		Filters:    filters,
-	}); err != nil {
+	if err := e.Encode(newJSONRoute(r)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unmarshalJsonBackend(r *Route, backend string) error {
+	switch backend {
+	case "<shunt>":
+		r.BackendType = ShuntBackend
+		r.Shunt = true
+	case "<loopback>":
+		r.BackendType = LoopBackend
+	case "<dynamic>":
+		r.BackendType = DynamicBackend
+	default:
+		if !strings.HasPrefix(backend, "<") {
+			r.Backend = backend
+			return nil
+		}
+
+		rs, err := Parse("* -> " + backend)
+		if err != nil {
+			return fmt.Errorf("invalid backend: %s: %w", backend, err)
+		}
+
+		r.BackendType = rs[0].BackendType
+		r.LBAlgorithm = rs[0].LBAlgorithm
+		r.LBEndpoints = rs[0].LBEndpoints
+	}
+
+	return nil
+}
+
+// UnmarshalJSON parses a route from the same JSON format that MarshalJSON
+// produces. Like the parser, it stores the predicates with a convenience
+// field, e.g. Path or Header, in the convenience fields.
+func (r *Route) UnmarshalJSON(data []byte) error {
+	var jr jsonRoute
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return err
+	}
+
+	var u Route
+	u.Id = jr.Id
+	if err := setAnnotations(&u, jr.Annotations); err != nil {
+		return err
+	}
+
+	for _, p := range jr.Predicates {
+		if err := unmarshalJSONArgs(p.Args); err != nil {
+			return err
+		}
+
+		name := p.Name
+		if name == "HostRegexp" {
+			// MarshalJSON uses the HostRegexp name for the Host predicates
+			name = "Host"
+		}
+
+		if err := applyPredicate(&u, name, p.Args, ParseOptions{}); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range jr.Filters {
+		if err := unmarshalJSONArgs(f.Args); err != nil {
+			return err
+		}
+	}
+
+	if len(jr.Filters) > 0 {
+		u.Filters = jr.Filters
+	}
+
+	if err := unmarshalJsonBackend(&u, jr.Backend); err != nil {
+		return err
+	}
+
+	*r = u
+	return nil
+}
+
+// the buffer of WriteJSONArray is flushed to the writer when it grows larger
+// than this limit
+const jsonArrayFlushSize = 1 << 16
+
+// WriteJSONArray serializes a list of routes as a JSON array to w. It
+// produces the same output as MarshalRoutesJSON, but it uses a single
+// encoder and buffer for all the routes, and it writes to w in chunks,
+// which makes it suitable for large routing tables. The nil routes are
+// written as null.
+func WriteJSONArray(w io.Writer, routes []*Route) error {
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+
+	buf.WriteByte('[')
+	for i, r := range routes {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		// like encoding/json, it writes null for the nil routes:
+		if r == nil {
+			buf.WriteString("null")
+		} else {
+			if err := e.Encode(newJSONRoute(r)); err != nil {
+				return err
+			}
+
+			// the encoder terminates each value with a newline:
+			buf.Truncate(buf.Len() - 1)
+		}
+
+		if buf.Len() > jsonArrayFlushSize {
+			if _, err := buf.WriteTo(w); err != nil {
+				return err
+			}
+		}
+	}
+
+	buf.WriteString("]\n")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// MarshalRoutesJSON serializes a list of routes as a JSON array, with the
+// same format for the items as MarshalJSON.
+func MarshalRoutesJSON(routes []*Route) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteJSONArray(&buf, routes); err != nil {
 		return nil, err
 	}
 
 	return buf.Bytes(), nil
 }
+
+// UnmarshalRoutesJSON parses a list of routes from a JSON array, with the
+// same format for the items as UnmarshalJSON.
+func UnmarshalRoutesJSON(data []byte) ([]*Route, error) {
+	var routes []*Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
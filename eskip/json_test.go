@@ -0,0 +1,265 @@
+package eskip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const jsonRoutesDoc = `
+	r1: Path("/foo") && Host(/^www[.]example[.]org$/) && Method("GET") &&
+	    Header("X-Foo", "bar") && HeaderRegexp("X-Bar", /^baz/) && Traffic(.3)
+	    -> setPath("/") -> status(418) -> "https://foo.example.org";
+	r2: * -> inlineContent("<h1>Hello</h1>") -> <shunt>;
+	r3: * -> setPath("/bar") -> <loopback>;
+	r4: * -> setDynamicBackendUrl("https://www.example.org") -> <dynamic>;
+	r5: Fallback() -> "https://www.example.org";
+	r6: * -> inlineContent(b64"SGVsbG8=", "application/octet-stream") -> <shunt>;
+`
+
+func TestRoutesJSONRoundTrip(t *testing.T) {
+	routes, err := Parse(jsonRoutesDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := MarshalRoutesJSON(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := UnmarshalRoutesJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(routes, rr); d != "" {
+		t.Error("failed to round-trip routes through JSON")
+		t.Log(d)
+	}
+}
+
+func TestMarshalRoutesJSON(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes []*Route
+		expect string
+	}{{
+		title:  "nil",
+		expect: "[]\n",
+	}, {
+		title: "same format as the single routes",
+		routes: []*Route{{
+			Id:          "r1",
+			Filters:     []*Filter{{Name: "inlineContent", Args: []interface{}{"<h1>Hello</h1>"}}},
+			BackendType: ShuntBackend,
+		}, {
+			Id:      "r2",
+			Backend: "https://www.example.org",
+		}},
+		expect: `[{"id":"r1","backend":"<shunt>","predicates":[],"filters":[{"name":"inlineContent","args":["\u003ch1\u003eHello\u003c/h1\u003e"]}]},` +
+			`{"id":"r2","backend":"https://www.example.org","predicates":[],"filters":[]}]` + "\n",
+	}, {
+		title: "binary args",
+		routes: []*Route{{
+			Id:          "r1",
+			Predicates:  []*Predicate{{Name: "Custom", Args: []interface{}{[]byte{0, 255}}}},
+			Filters:     []*Filter{{Name: "inlineContent", Args: []interface{}{[]byte("Hello"), "text/plain"}}},
+			BackendType: ShuntBackend,
+		}},
+		expect: `[{"id":"r1","backend":"<shunt>","predicates":[{"name":"Custom","args":[{"type":"base64","value":"AP8="}]}],` +
+			`"filters":[{"name":"inlineContent","args":[{"type":"base64","value":"SGVsbG8="},"text/plain"]}]}]` + "\n",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			b, err := MarshalRoutesJSON(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != test.expect {
+				t.Errorf("invalid JSON, got: %s, expected: %s", string(b), test.expect)
+			}
+
+			for _, r := range test.routes {
+				rb, err := r.MarshalJSON()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !strings.Contains(string(b), strings.TrimSpace(string(rb))) {
+					t.Errorf("inconsistent JSON format for route: %s", string(rb))
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalRouteJSON(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		json   string
+		expect *Route
+		fail   bool
+	}{{
+		title:  "empty",
+		json:   `{}`,
+		expect: &Route{},
+	}, {
+		title: "lb backend",
+		json:  `{"id":"r1","backend":"<roundRobin, \"http://foo.example.org\", \"http://bar.example.org\">"}`,
+		expect: &Route{
+			Id:          "r1",
+			BackendType: LBBackend,
+			LBAlgorithm: "roundRobin",
+			LBEndpoints: []string{"http://foo.example.org", "http://bar.example.org"},
+		},
+	}, {
+		title: "invalid backend",
+		json:  `{"id":"r1","backend":"<shnt>"}`,
+		fail:  true,
+	}, {
+		title: "invalid predicate",
+		json:  `{"id":"r1","predicates":[{"name":"Path","args":[42]}]}`,
+		fail:  true,
+	}, {
+		title: "binary args",
+		json:  `{"id":"r1","filters":[{"name":"custom","args":[{"type":"base64","value":"AP8="},{"foo":"bar"}]}]}`,
+		expect: &Route{
+			Id:      "r1",
+			Filters: []*Filter{{Name: "custom", Args: []interface{}{[]byte{0, 255}, map[string]interface{}{"foo": "bar"}}}},
+		},
+	}, {
+		title: "invalid binary arg",
+		json:  `{"id":"r1","filters":[{"name":"custom","args":[{"type":"base64","value":"AP8"}]}]}`,
+		fail:  true,
+	}, {
+		title: "invalid json",
+		json:  `{"id":42}`,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var r Route
+			err := r.UnmarshalJSON([]byte(test.json))
+			if test.fail {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, &r); d != "" {
+				t.Error("failed to unmarshal route")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+// encodes the routes the naive way, calling MarshalJSON for each route
+func encodeRoutesNaive(routes []*Route) ([]byte, error) {
+	if routes == nil {
+		routes = []*Route{}
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(routes); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func largeRoutingTable(n int) []*Route {
+	routes := make([]*Route, n)
+	for i := range routes {
+		routes[i] = &Route{
+			Id:          fmt.Sprintf("route%d", i),
+			Method:      "GET",
+			Path:        fmt.Sprintf("/api/%d", i),
+			HostRegexps: []string{"^www[.]example[.]org$"},
+			Headers:     map[string]string{"X-Foo": "bar"},
+			Predicates:  []*Predicate{{Name: "Traffic", Args: []interface{}{.3}}},
+			Filters: []*Filter{
+				{Name: "setPath", Args: []interface{}{"/"}},
+				{Name: "inlineContent", Args: []interface{}{"<h1>Hello</h1>"}},
+			},
+			Backend: "https://www.example.org",
+		}
+	}
+
+	return routes
+}
+
+func TestWriteJSONArray(t *testing.T) {
+	parsed, err := Parse(jsonRoutesDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title  string
+		routes []*Route
+	}{{
+		title: "nil",
+	}, {
+		title:  "empty",
+		routes: []*Route{},
+	}, {
+		title:  "parsed routes",
+		routes: parsed,
+	}, {
+		title:  "nil routes",
+		routes: []*Route{nil, parsed[0], nil},
+	}, {
+		title:  "larger than the flush size",
+		routes: largeRoutingTable(1000),
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			expect, err := encodeRoutesNaive(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteJSONArray(&buf, test.routes); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(expect, buf.Bytes()) {
+				t.Errorf("invalid JSON, got: %s, expected: %s", buf.String(), string(expect))
+			}
+		})
+	}
+}
+
+func BenchmarkMarshalJSONNaive(b *testing.B) {
+	routes := largeRoutingTable(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeRoutesNaive(routes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteJSONArray(b *testing.B) {
+	routes := largeRoutingTable(50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteJSONArray(io.Discard, routes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package eskip
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// The names of the labels returned by MetricsLabels.
+const (
+	LabelBackendHost = "backend_host"
+	LabelBackendType = "backend_type"
+	LabelMethod      = "method"
+	LabelPathPrefix  = "path_prefix"
+)
+
+func backendHost(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Host)
+}
+
+func lbBackendHosts(endpoints []string) string {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, ep := range endpoints {
+		if h := backendHost(ep); h != "" && !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+
+	sort.Strings(hosts)
+	return strings.Join(hosts, ",")
+}
+
+// the first segment of the path, up to the first wildcard
+func pathPrefix(p string) string {
+	s := pathSegments(p)
+	if len(s) == 0 {
+		return "/"
+	}
+
+	if strings.HasPrefix(s[0], ":") || strings.HasPrefix(s[0], "*") {
+		return "/"
+	}
+
+	return "/" + s[0]
+}
+
+// MetricsLabels returns a stable set of labels of the route, that can be
+// used when emitting route metrics. The labels are derived from the fields
+// of the route, without changing it. Every label is always set, when a
+// label is not applicable, its value is empty. The labels are:
+//
+// - backend_host: the host of a network backend, or the sorted, comma
+// separated hosts of the endpoints of a load balanced backend, e.g.
+// www.example.org:8080,
+//
+// - backend_type: network, shunt, loopback, dynamic or lb,
+//
+// - method: the upper case method from the Method predicate, e.g. GET,
+//
+// - path_prefix: the first segment of the path from the Path or the
+// PathSubtree predicate, or / when the first segment is a wildcard, e.g.
+// /api for Path("/api/v1/users").
+func (r *Route) MetricsLabels() map[string]string {
+	c := Canonical(r)
+	labels := map[string]string{
+		LabelBackendHost: "",
+		LabelBackendType: c.BackendType.String(),
+		LabelMethod:      "",
+		LabelPathPrefix:  "",
+	}
+
+	switch c.BackendType {
+	case NetworkBackend:
+		labels[LabelBackendHost] = backendHost(c.Backend)
+	case LBBackend:
+		labels[LabelBackendHost] = lbBackendHosts(c.LBEndpoints)
+	}
+
+	for _, p := range c.Predicates {
+		switch p.Name {
+		case "Method":
+			if a, err := getStringArgs(1, p.Args); err == nil {
+				labels[LabelMethod] = strings.ToUpper(a[0])
+			}
+		case "Path", "PathSubtree":
+			if a, err := getStringArgs(1, p.Args); err == nil {
+				labels[LabelPathPrefix] = pathPrefix(a[0])
+			}
+		}
+	}
+
+	return labels
+}
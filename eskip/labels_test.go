@@ -0,0 +1,79 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetricsLabels(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		route  string
+		expect map[string]string
+	}{{
+		title: "network backend",
+		route: `Method("get") && Path("/api/v1/users") -> "https://WWW.example.org:8080/foo"`,
+		expect: map[string]string{
+			"backend_host": "www.example.org:8080",
+			"backend_type": "network",
+			"method":       "GET",
+			"path_prefix":  "/api",
+		},
+	}, {
+		title: "shunt without method and path",
+		route: `* -> status(404) -> <shunt>`,
+		expect: map[string]string{
+			"backend_host": "",
+			"backend_type": "shunt",
+			"method":       "",
+			"path_prefix":  "",
+		},
+	}, {
+		title: "wildcard path subtree",
+		route: `PathSubtree("/:tenant/api") -> <loopback>`,
+		expect: map[string]string{
+			"backend_host": "",
+			"backend_type": "loopback",
+			"method":       "",
+			"path_prefix":  "/",
+		},
+	}, {
+		title: "root path",
+		route: `Path("/") -> <dynamic>`,
+		expect: map[string]string{
+			"backend_host": "",
+			"backend_type": "dynamic",
+			"method":       "",
+			"path_prefix":  "/",
+		},
+	}, {
+		title: "load balanced backend",
+		route: `PathSubtree("/static") -> <roundRobin, "http://b.example.org", "http://a.example.org", "http://b.example.org">`,
+		expect: map[string]string{
+			"backend_host": "a.example.org,b.example.org",
+			"backend_type": "lb",
+			"method":       "",
+			"path_prefix":  "/static",
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.route)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, r[0].MetricsLabels()); d != "" {
+				t.Error("invalid labels")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestMetricsLabelsLegacyShunt(t *testing.T) {
+	l := (&Route{Shunt: true, Backend: "https://www.example.org"}).MetricsLabels()
+	if l[LabelBackendType] != "shunt" || l[LabelBackendHost] != "" {
+		t.Errorf("invalid labels of legacy shunt route: %v", l)
+	}
+}
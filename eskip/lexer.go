@@ -1,10 +1,15 @@
 package eskip
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 type token struct {
@@ -25,10 +30,19 @@ func (sf scannerFunc) scan(code string) (token, string, error) { return sf(code)
 type eskipLex struct {
 	code          string
 	lastToken     *token
+	prevToken     *token
 	lastRouteID   string
 	err           error
 	initialLength int
 	routes        []*parsedRoute
+	newline       bool
+	comment       string
+	annotations   map[string]string
+	keepErr       bool
+	typedLiterals bool
+	strictEscapes bool
+	suggestShunt  bool
+	inFilters     bool
 }
 
 type fixedScanner string
@@ -38,6 +52,10 @@ const (
 	decimalChar = '.'
 	newlineChar = '\n'
 	underscore  = '_'
+	minusChar   = '-'
+
+	annotationPrefix = "@"
+	base64Prefix     = "b64\""
 )
 
 var (
@@ -46,6 +64,15 @@ var (
 	unexpectedToken  = errors.New("unexpected token")
 	void             = errors.New("void")
 	eof              = errors.New("eof")
+	invalidNumber    = errors.New("invalid number")
+	invalidBase64    = errors.New("invalid base64 literal")
+
+	invalidDurationLiteral = errors.New("invalid duration literal")
+	unknownEscape          = errors.New("unknown escape sequence")
+
+	unknownBackendType = errors.New("unknown backend type")
+	backendNotLast     = errors.New("backend must be the last element of the route")
+	missingBackend     = errors.New("missing backend after the last filter, use -> <shunt> when the route doesn't forward the requests")
 )
 
 // now this needs to be sorted
@@ -125,7 +152,51 @@ func scanVoid(code string, p charPredicate) string {
 	return rest
 }
 
+// decodes the hex digits of a \uXXXX or \UXXXXXXXX escape sequence at the
+// start of code, following the escape char. It returns the rune and the
+// length of the sequence, or false when the sequence is invalid. A \u
+// escaped UTF-16 surrogate pair, as used by JSON, is decoded to a single
+// rune.
+func scanUnicodeEscape(code string) (rune, int, bool) {
+	n := 4
+	if code[0] == 'U' {
+		n = 8
+	}
+
+	if len(code) < n+1 {
+		return 0, 0, false
+	}
+
+	v, err := strconv.ParseUint(code[1:n+1], 16, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	r := rune(v)
+	if n == 4 && utf16.IsSurrogate(r) && len(code) >= 11 && code[5] == escapeChar && code[6] == 'u' {
+		if low, err := strconv.ParseUint(code[7:11], 16, 32); err == nil {
+			if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+				return pair, 11, true
+			}
+		}
+	}
+
+	if !utf8.ValidRune(r) {
+		return 0, 0, false
+	}
+
+	return r, n + 1, true
+}
+
 func scanEscaped(delimiter byte, code string) ([]byte, string) {
+	b, rest, _ := scanEscapedStrict(delimiter, code, false)
+	return b, rest
+}
+
+// scans the escaped content of a string until the delimiter. When strict
+// is set, it fails on the unknown escape sequences, otherwise it keeps them
+// unchanged.
+func scanEscapedStrict(delimiter byte, code string, strict bool) ([]byte, string, error) {
 	var b []byte
 	escaped := false
 	for len(code) > 0 {
@@ -133,6 +204,16 @@ func scanEscaped(delimiter byte, code string) ([]byte, string) {
 		isDelimiter := c == delimiter
 		isEscapeChar := c == escapeChar
 
+		if escaped && (c == 'u' || c == 'U') {
+			if r, n, ok := scanUnicodeEscape(code); ok {
+				var rb [utf8.UTFMax]byte
+				b = append(b, rb[:utf8.EncodeRune(rb[:], r)]...)
+				escaped = false
+				code = code[n:]
+				continue
+			}
+		}
+
 		if escaped {
 			switch c {
 			case 'a':
@@ -152,6 +233,10 @@ func scanEscaped(delimiter byte, code string) ([]byte, string) {
 			case delimiter:
 			case escapeChar:
 			default:
+				if strict {
+					return b, code, fmt.Errorf("%w: \\%c", unknownEscape, c)
+				}
+
 				b = append(b, escapeChar)
 			}
 
@@ -159,7 +244,7 @@ func scanEscaped(delimiter byte, code string) ([]byte, string) {
 			escaped = false
 		} else {
 			if isDelimiter {
-				return b, code
+				return b, code, nil
 			}
 
 			if isEscapeChar {
@@ -172,44 +257,65 @@ func scanEscaped(delimiter byte, code string) ([]byte, string) {
 		code = code[1:]
 	}
 
-	return b, code
+	return b, code, nil
 }
 
+// scans a slash delimited regular expression. The / delimiter needs to be
+// escaped as \/, except in character classes, e.g. [/], and the escaped
+// delimiter is unescaped. All the other escape sequences, including the
+// escaped backslash \\, are kept unchanged for the regexp syntax.
 func scanRegexp(code string) ([]byte, string) {
 	var b []byte
 	escaped := false
-	var insideGroup = false
+	insideGroup := false
+	groupStart := 0
 	for len(code) > 0 {
 		c := code[0]
 		isDelimiter := c == '/'
 		isEscapeChar := c == escapeChar
 
-		//Check if starting [... or ending ...]. Ignore if group character is escaped i.e. \[ or \]
-		if !escaped && !insideGroup && c == '[' {
-			insideGroup = true
-		} else if !escaped && insideGroup && c == ']' {
-			insideGroup = false
-		}
-
 		if escaped {
 			//delimeter / is escaped in PathRegexp so that it means no end PathRegexp(/\//)
-			if !isDelimiter && !isEscapeChar {
+			if !isDelimiter {
 				b = append(b, escapeChar)
 			}
+
 			b = append(b, c)
 			escaped = false
-		} else {
-			if isDelimiter && !insideGroup {
-				return b, code
-			}
-			if isEscapeChar {
-				escaped = true
-			} else {
-				b = append(b, c)
+			code = code[1:]
+			continue
+		}
+
+		switch {
+		case isEscapeChar:
+			escaped = true
+			code = code[1:]
+			continue
+		case isDelimiter && !insideGroup:
+			return b, code
+		case !insideGroup && c == '[':
+			// starting [...
+			insideGroup = true
+			groupStart = len(b) + 1
+		case insideGroup && c == '^' && len(b) == groupStart:
+			// negated group, [^...
+			groupStart++
+		case insideGroup && c == ']' && len(b) > groupStart:
+			// ending ...], while []...] and [^]...] contain a literal ]
+			insideGroup = false
+		case insideGroup && strings.HasPrefix(code, "[:"):
+			// named class inside a group, e.g. [[:alpha:]/]
+			if n := strings.Index(code, ":]"); n > 0 {
+				b = append(b, code[:n+2]...)
+				code = code[n+2:]
+				continue
 			}
 		}
+
+		b = append(b, c)
 		code = code[1:]
 	}
+
 	return b, code
 }
 
@@ -235,6 +341,7 @@ func scanRegexpOrComment(code string) (t token, rest string, err error) {
 
 	if code[1] == '/' {
 		rest = scanComment(code)
+		t.val = strings.TrimSpace(code[2 : len(code)-len(rest)])
 		err = void
 		return
 	}
@@ -244,7 +351,19 @@ func scanRegexpOrComment(code string) (t token, rest string, err error) {
 }
 
 func scanStringLiteral(delimiter byte, code string) (t token, rest string, err error) {
-	b, rest := scanEscaped(delimiter, code[1:])
+	return scanStringLiteralStrict(delimiter, code, false)
+}
+
+func scanStringLiteralStrict(delimiter byte, code string, strict bool) (t token, rest string, err error) {
+	var b []byte
+	b, rest, err = scanEscapedStrict(delimiter, code[1:], strict)
+	if err != nil {
+		// the rest is reset to the start of the string, so that the
+		// error is reported at the position of the string
+		rest = code
+		return
+	}
+
 	if len(rest) == 0 {
 		err = incompleteToken
 		return
@@ -256,35 +375,107 @@ func scanStringLiteral(delimiter byte, code string) (t token, rest string, err e
 	return
 }
 
+// selects the string scanners failing on the unknown escape sequences
+func selectStrictString(code string) scanner {
+	switch code[0] {
+	case '"', '\'', '`':
+		delimiter := code[0]
+		return scannerFunc(func(code string) (token, string, error) {
+			return scanStringLiteralStrict(delimiter, code, true)
+		})
+	default:
+		return nil
+	}
+}
+
 func scanWhitespace(code string) string { return scanVoid(code, isWhitespace) }
 func scanComment(code string) string {
 	return scanVoid(code, func(c byte) bool { return !isNewline(c) })
 }
 func scanDoubleQuote(code string) (token, string, error) { return scanStringLiteral('"', code) }
+func scanSingleQuote(code string) (token, string, error) { return scanStringLiteral('\'', code) }
 func scanBacktick(code string) (token, string, error)    { return scanStringLiteral('`', code) }
 
+// scans a base64 literal, e.g. b64"SGVsbG8=", and decodes its value
+func scanBase64(code string) (t token, rest string, err error) {
+	t, rest, err = scanStringLiteral('"', code[len(base64Prefix)-1:])
+	if err != nil {
+		return
+	}
+
+	b, derr := base64.StdEncoding.DecodeString(t.val)
+	if derr != nil {
+		err = invalidBase64
+		return
+	}
+
+	t.id = b64literal
+	t.val = string(b)
+	return
+}
+
+func isNegativeNumber(code string) bool {
+	return len(code) > 1 && code[0] == minusChar && isNumberChar(code[1])
+}
+
+func isHexPrefix(code string) bool {
+	return len(code) > 1 && code[0] == '0' && (code[1] == 'x' || code[1] == 'X')
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// parses a number token, that can be a decimal number, or a hexadecimal
+// integer with the 0x prefix, and it can contain underscores as digit
+// separators, following the Go syntax, e.g. 0xFF or 1_000_000.
+func parseNumber(s string) (float64, error) {
+	if isHexPrefix(strings.TrimPrefix(s, "-")) {
+		n, err := strconv.ParseInt(s, 0, 64)
+		return float64(n), err
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
 func scanNumber(code string) (t token, rest string, err error) {
-	decimal := false
-	b, rest := scanWhile(code, func(c byte) bool {
-		if isDecimalChar(c) {
-			if decimal {
-				return false
-			}
+	var sign []byte
+	if code[0] == minusChar {
+		sign, code = []byte{minusChar}, code[1:]
+	}
 
-			decimal = true
-			return true
-		}
+	var b []byte
+	if isHexPrefix(code) {
+		b, rest = scanWhile(code[2:], func(c byte) bool { return isHexDigit(c) || isUnderscore(c) })
+		b = append([]byte(code[:2]), b...)
+	} else {
+		decimal := false
+		b, rest = scanWhile(code, func(c byte) bool {
+			if isDecimalChar(c) {
+				if decimal {
+					return false
+				}
+
+				decimal = true
+				return true
+			}
 
-		return isDigit(c)
-	})
+			return isDigit(c) || isUnderscore(c)
+		})
+	}
 
 	if isDecimalChar(b[len(b)-1]) {
 		err = incompleteToken
 		return
 	}
 
+	t.val = string(append(sign, b...))
+	if _, perr := parseNumber(t.val); perr != nil {
+		err = invalidNumber
+		return
+	}
+
 	t.id = number
-	t.val = string(b)
 	return
 }
 
@@ -312,11 +503,13 @@ func selectVaryingScanner(code string) scanner {
 		sf = scanRegexpOrComment
 	case '"':
 		sf = scanDoubleQuote
+	case '\'':
+		sf = scanSingleQuote
 	case '`':
 		sf = scanBacktick
 	}
 
-	if isNumberChar(code[0]) {
+	if isNumberChar(code[0]) || isNegativeNumber(code) {
 		sf = scanNumber
 	}
 
@@ -324,6 +517,10 @@ func selectVaryingScanner(code string) scanner {
 		sf = scanSymbol
 	}
 
+	if strings.HasPrefix(code, base64Prefix) {
+		sf = scanBase64
+	}
+
 	if sf != nil {
 		return scanner(sf)
 	}
@@ -331,7 +528,35 @@ func selectVaryingScanner(code string) scanner {
 	return nil
 }
 
+var backendTypeTokenRx = regexp.MustCompile(`^<\s*([A-Za-z_][A-Za-z0-9_]*)\s*>`)
+
+// detects the malformed <...> backend types, e.g. <shnt>, to return a more
+// specific error than the generic syntax error.
+func selectUnknownBackendType(code string) scanner {
+	m := backendTypeTokenRx.FindStringSubmatch(code)
+	if len(m) == 0 {
+		return nil
+	}
+
+	switch m[1] {
+	case "shunt", "loopback", "dynamic":
+		return nil
+	}
+
+	return scannerFunc(func(code string) (token, string, error) {
+		return token{}, code, fmt.Errorf(
+			"%w '%s', expected one of <shunt>, <loopback>, <dynamic> or <algorithm, \"endpoint\", ...>",
+			unknownBackendType,
+			m[1],
+		)
+	})
+}
+
 func selectScanner(code string) scanner {
+	if s := selectUnknownBackendType(code); s != nil {
+		return s
+	}
+
 	if s := selectFixed(code); s != nil {
 		return s
 	}
@@ -340,13 +565,30 @@ func selectScanner(code string) scanner {
 }
 
 func (l *eskipLex) next() (t token, err error) {
-	l.code = scanWhitespace(l.code)
+	rest := scanWhitespace(l.code)
+	if strings.ContainsRune(l.code[:len(l.code)-len(rest)], newlineChar) {
+		l.newline = true
+	}
+
+	l.code = rest
 	if len(l.code) == 0 {
 		err = eof
 		return
 	}
 
-	s := selectScanner(l.code)
+	var s scanner
+	if l.typedLiterals {
+		s = selectTypedLiteral(l.code)
+	}
+
+	if s == nil && l.strictEscapes {
+		s = selectStrictString(l.code)
+	}
+
+	if s == nil {
+		s = selectScanner(l.code)
+	}
+
 	if s == nil {
 		err = unexpectedToken
 		return
@@ -354,16 +596,65 @@ func (l *eskipLex) next() (t token, err error) {
 
 	t, l.code, err = s.scan(l.code)
 	if err == void {
+		// only the comments in the same line as the previous token are
+		// kept, to be attached to the previous filter, while the
+		// annotations are taken from the comments in their own line:
+		if l.lastToken != nil && !l.newline {
+			l.comment = t.val
+		} else if strings.HasPrefix(t.val, annotationPrefix) {
+			l.annotate(t.val)
+		}
+
 		return l.next()
 	}
 
 	if err == nil {
+		// tracks whether the route has reached the filters or the
+		// backend, reset at the start of the next route:
+		if l.lastToken != nil && l.lastToken.id == semicolon {
+			l.inFilters = false
+		}
+
+		if t.id == arrow {
+			l.inFilters = true
+		}
+
+		l.prevToken = l.lastToken
 		l.lastToken = &t
+		l.newline = false
 	}
 
 	return
 }
 
+// parses an annotation comment of the form @key=value, or @key with an empty
+// value.
+func (l *eskipLex) annotate(comment string) {
+	body := comment[len(annotationPrefix):]
+
+	// the disabled annotation accepts the reason after a colon, too:
+	if strings.HasPrefix(body, DisabledAnnotation+":") {
+		body = DisabledAnnotation + "=" + body[len(DisabledAnnotation)+1:]
+	}
+
+	kv := strings.SplitN(body, "=", 2)
+	key := strings.TrimSpace(kv[0])
+	if key == "" {
+		return
+	}
+
+	var value string
+	if len(kv) == 2 {
+		value = strings.TrimSpace(kv[1])
+	}
+
+	if l.annotations == nil {
+		l.annotations = make(map[string]string)
+	}
+
+	l.annotations[key] = value
+}
+
 func (l *eskipLex) Lex(lval *eskipSymType) int {
 	token, err := l.next()
 	if err == eof {
@@ -372,14 +663,66 @@ func (l *eskipLex) Lex(lval *eskipSymType) int {
 
 	if err != nil {
 		l.Error(err.Error())
+
+		// keep the unknown backend type and escape errors, as they are
+		// more specific than the subsequent syntax error:
+		l.keepErr = errors.Is(err, unknownBackendType) || errors.Is(err, unknownEscape)
 		return -1
 	}
 
 	lval.token = token.val
+	lval.comment = l.comment
+	lval.annotations = l.annotations
+	l.comment = ""
+	l.annotations = nil
 	return token.id
 }
 
+// tells whether the token can be the last token of a backend, when it is
+// followed by an arrow
+func isBackendEnd(t *token) bool {
+	if t == nil {
+		return false
+	}
+
+	switch t.id {
+	case stringliteral, shunt, loopback, dynamic, closearrow:
+		return true
+	default:
+		return false
+	}
+}
+
+// tells whether the current route ended after the closing paren of a
+// filter, either at the end of the document or at a semicolon
+func (l *eskipLex) endsWithFilter() bool {
+	if !l.inFilters || l.lastToken == nil {
+		return false
+	}
+
+	if len(l.code) == 0 && l.lastToken.id == closeparen {
+		return true
+	}
+
+	return l.lastToken.id == semicolon && l.prevToken != nil && l.prevToken.id == closeparen
+}
+
 func (l *eskipLex) Error(err string) {
+	if l.keepErr {
+		return
+	}
+
+	// a common mistake is to place the backend before the filters, and
+	// the syntax error is not obvious in this case:
+	if strings.HasPrefix(err, "syntax error") &&
+		l.lastToken != nil && l.lastToken.id == arrow && isBackendEnd(l.prevToken) {
+		err = backendNotLast.Error()
+	}
+
+	if strings.HasPrefix(err, "syntax error") && l.suggestShunt && l.endsWithFilter() {
+		err = missingBackend.Error()
+	}
+
 	l.err = fmt.Errorf(
 		"parse failed after token %v, last route id: %v, position %d: %s",
 		l.lastToken, l.lastRouteID, l.initialLength-len(l.code), err)
@@ -0,0 +1,170 @@
+package eskip
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is the type of the duration literal args, e.g. 5s or 1m30s, when
+// the routes are parsed with the TypedLiterals option. The literals follow
+// the format of time.ParseDuration.
+type Duration time.Duration
+
+// ByteSize is the type of the size literal args, e.g. 10MB or 2KiB, when the
+// routes are parsed with the TypedLiterals option. Its value is the number
+// of bytes. The supported units are B, KB, MB, GB and TB, with a base of
+// 1000, and KiB, MiB, GiB and TiB, with a base of 1024.
+type ByteSize int64
+
+var (
+	durationLiteralRx = regexp.MustCompile(`^-?([0-9]+([.][0-9]+)?(ns|us|µs|ms|s|m|h))+`)
+	sizeLiteralRx     = regexp.MustCompile(`^([0-9]+([.][0-9]+)?)(B|KB|MB|GB|TB|KiB|MiB|GiB|TiB)`)
+
+	invalidSizeLiteral = errors.New("invalid size literal")
+)
+
+// the size units from the largest to the smallest
+var sizeUnits = []struct {
+	name string
+	size int64
+}{
+	{"TiB", 1 << 40},
+	{"TB", 1e12},
+	{"GiB", 1 << 30},
+	{"GB", 1e9},
+	{"MiB", 1 << 20},
+	{"MB", 1e6},
+	{"KiB", 1 << 10},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+// String returns the literal form of the duration, e.g. 1m30s.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON represents the duration as a JSON string in its literal form.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// String returns the literal form of the size, using the largest unit that
+// represents it as an integer, e.g. 2KiB.
+func (s ByteSize) String() string {
+	if s == 0 {
+		return "0B"
+	}
+
+	for _, u := range sizeUnits {
+		if s%ByteSize(u.size) == 0 {
+			return strconv.FormatInt(int64(s)/u.size, 10) + u.name
+		}
+	}
+
+	// not reached, every size is divisible by 1
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+// MarshalJSON represents the size as a JSON string in its literal form.
+func (s ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func parseSizeLiteral(s string) (ByteSize, error) {
+	m := sizeLiteralRx.FindStringSubmatch(s)
+	if len(m) == 0 || len(m[0]) != len(s) {
+		return 0, invalidSizeLiteral
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, invalidSizeLiteral
+	}
+
+	var unit int64
+	for _, u := range sizeUnits {
+		if u.name == m[3] {
+			unit = u.size
+			break
+		}
+	}
+
+	// float64(math.MaxInt64) rounds up to 1<<63, which doesn't fit in
+	// an int64:
+	b := n * float64(unit)
+	if b != math.Trunc(b) || b >= math.MaxInt64 {
+		return 0, invalidSizeLiteral
+	}
+
+	return ByteSize(b), nil
+}
+
+// conversion errors ignored, the lexer already checked the format
+func convertDuration(s string) Duration {
+	d, _ := time.ParseDuration(s)
+	return Duration(d)
+}
+
+func convertSize(s string) ByteSize {
+	b, _ := parseSizeLiteral(s)
+	return b
+}
+
+// the typed literals need to be followed by a character that cannot
+// continue a symbol, otherwise they are scanned as numbers
+func matchLiteral(rx *regexp.Regexp, code string) string {
+	l := rx.FindString(code)
+	if l == "" || len(l) < len(code) && isSymbolChar(code[len(l)]) {
+		return ""
+	}
+
+	return l
+}
+
+func scanDurationLiteral(code string) (t token, rest string, err error) {
+	l := matchLiteral(durationLiteralRx, code)
+	if _, perr := time.ParseDuration(l); perr != nil {
+		err = invalidDurationLiteral
+		return
+	}
+
+	t.id = durationliteral
+	t.val = l
+	rest = code[len(l):]
+	return
+}
+
+func scanSizeLiteral(code string) (t token, rest string, err error) {
+	l := matchLiteral(sizeLiteralRx, code)
+	if _, err = parseSizeLiteral(l); err != nil {
+		return
+	}
+
+	t.id = sizeliteral
+	t.val = l
+	rest = code[len(l):]
+	return
+}
+
+// selects the scanner of the typed literals, when the code starts with one
+func selectTypedLiteral(code string) scanner {
+	if !isDigit(code[0]) && !strings.HasPrefix(code, "-") {
+		return nil
+	}
+
+	if matchLiteral(durationLiteralRx, code) != "" {
+		return scannerFunc(scanDurationLiteral)
+	}
+
+	if matchLiteral(sizeLiteralRx, code) != "" {
+		return scannerFunc(scanSizeLiteral)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package eskip
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTypedLiterals(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		code  string
+		args  []interface{}
+		print string
+		fail  bool
+	}{{
+		title: "durations",
+		code:  `* -> foo(5s, 100ms, 1m30s, -2h, 1.5µs) -> <shunt>`,
+		args: []interface{}{
+			Duration(5 * time.Second),
+			Duration(100 * time.Millisecond),
+			Duration(90 * time.Second),
+			Duration(-2 * time.Hour),
+			Duration(1500 * time.Nanosecond),
+		},
+		print: `* -> foo(5s, 100ms, 1m30s, -2h0m0s, 1.5µs) -> <shunt>`,
+	}, {
+		title: "sizes",
+		code:  `* -> foo(10MB, 2KiB, 0B, 1.5GB, 1024KB) -> <shunt>`,
+		args: []interface{}{
+			ByteSize(10_000_000),
+			ByteSize(2048),
+			ByteSize(0),
+			ByteSize(1_500_000_000),
+			ByteSize(1_024_000),
+		},
+		print: `* -> foo(10MB, 2KiB, 0B, 1500MB, 1000KiB) -> <shunt>`,
+	}, {
+		title: "mixed with the other args",
+		code:  `Custom(3, 5s, "5s", 0x10) -> foo(-1, 2B) -> <shunt>`,
+		print: `Custom(3, 5s, "5s", 16) -> foo(-1, 2B) -> <shunt>`,
+	}, {
+		title: "invalid size",
+		code:  `* -> foo(1.5B) -> <shunt>`,
+		fail:  true,
+	}, {
+		title: "largest size",
+		code:  `* -> foo(8388607TiB) -> <shunt>`,
+		args:  []interface{}{ByteSize(8388607 << 40)},
+		print: `* -> foo(8388607TiB) -> <shunt>`,
+	}, {
+		title: "size overflow",
+		code:  `* -> foo(8388608TiB) -> <shunt>`,
+		fail:  true,
+	}, {
+		title: "unknown unit",
+		code:  `* -> foo(5xs) -> <shunt>`,
+		fail:  true,
+	}, {
+		title: "not a literal",
+		code:  `* -> foo(5sec) -> <shunt>`,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if _, err := Parse(test.code); err == nil {
+				t.Error("failed to fail without the option")
+			}
+
+			r, err := ParseWithOptions(test.code, ParseOptions{TypedLiterals: true})
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.args != nil {
+				if d := cmp.Diff(test.args, r[0].Filters[0].Args); d != "" {
+					t.Error(d)
+				}
+			}
+
+			s := r[0].String()
+			if s != test.print {
+				t.Errorf("invalid route string, got: %s, expected: %s", s, test.print)
+			}
+
+			rr, err := ParseWithOptions(s, ParseOptions{TypedLiterals: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !Eq(r[0], rr[0]) {
+				t.Errorf("failed to round-trip the typed literals: %s", rr[0].String())
+			}
+		})
+	}
+}
+
+func TestTypedLiteralsJSON(t *testing.T) {
+	b, err := json.Marshal(&Filter{Name: "foo", Args: []interface{}{Duration(5 * time.Second), ByteSize(2048)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(b); s != `{"name":"foo","args":["5s","2KiB"]}` {
+		t.Errorf("invalid JSON: %s", s)
+	}
+}
@@ -0,0 +1,230 @@
+package eskip
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// MatchInput describes a request for the static evaluation of the route
+// predicates with MatchRoutes.
+type MatchInput struct {
+	// Path of the request, e.g. /api/users.
+	Path string
+
+	// Method of the request, e.g. GET.
+	Method string
+
+	// Host of the request, as it would be found in the Host header.
+	Host string
+
+	// Headers of the request.
+	Headers http.Header
+
+	// MatchCustomPredicates tells how to evaluate the predicates, that
+	// cannot be evaluated statically, like Traffic() or Cron(). When
+	// true, these predicates are considered matching, otherwise they
+	// make the route non-matching.
+	MatchCustomPredicates bool
+}
+
+var regexpCache sync.Map
+
+// compiles a regular expression and caches the result for the lifetime of
+// the process.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if rx, ok := regexpCache.Load(expr); ok {
+		return rx.(*regexp.Regexp), nil
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	regexpCache.Store(expr, rx)
+	return rx, nil
+}
+
+func matchRegexpArg(args []interface{}, value string) bool {
+	a, err := getStringArgs(1, args)
+	if err != nil {
+		return false
+	}
+
+	rx, err := compileRegexp(a[0])
+	if err != nil {
+		return false
+	}
+
+	return rx.MatchString(value)
+}
+
+func pathSegments(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// matches a path with the wildcard syntax of the Path and PathSubtree
+// predicates, where :name matches a single segment, and *name matches the
+// rest of the path.
+func matchPathPattern(pattern, path string, subtree bool) bool {
+	ps, s := pathSegments(pattern), pathSegments(path)
+	for i, pi := range ps {
+		if strings.HasPrefix(pi, "*") {
+			return true
+		}
+
+		if i >= len(s) {
+			return false
+		}
+
+		if !strings.HasPrefix(pi, ":") && pi != s[i] {
+			return false
+		}
+	}
+
+	return subtree || len(ps) == len(s)
+}
+
+func matchHeaderValues(h http.Header, key string, check func(string) bool) bool {
+	for _, v := range h.Values(key) {
+		if check(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchPredicate(p *Predicate, in MatchInput) bool {
+	switch p.Name {
+	case "Path", "PathSubtree":
+		a, err := getStringArgs(1, p.Args)
+		return err == nil && matchPathPattern(a[0], in.Path, p.Name == "PathSubtree")
+	case "PathRegexp":
+		return matchRegexpArg(p.Args, in.Path)
+	case "Host":
+		return matchRegexpArg(p.Args, in.Host)
+	case "Method":
+		a, err := getStringArgs(1, p.Args)
+		return err == nil && strings.EqualFold(a[0], in.Method)
+	case "Methods":
+		for _, a := range p.Args {
+			if m, ok := a.(string); ok && strings.EqualFold(m, in.Method) {
+				return true
+			}
+		}
+
+		return false
+	case "Header":
+		a, err := getStringArgs(2, p.Args)
+		return err == nil && matchHeaderValues(in.Headers, a[0], func(v string) bool { return v == a[1] })
+	case "HeaderRegexp":
+		a, err := getStringArgs(2, p.Args)
+		if err != nil {
+			return false
+		}
+
+		rx, err := compileRegexp(a[1])
+		return err == nil && matchHeaderValues(in.Headers, a[0], rx.MatchString)
+	case "Any", "True", "Weight":
+		return true
+	case "False":
+		return false
+	default:
+		return in.MatchCustomPredicates
+	}
+}
+
+func matchRoute(r *Route, in MatchInput) bool {
+	for _, p := range Canonical(r).Predicates {
+		if !matchPredicate(p, in) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchRoutes returns the routes whose predicates match the request
+// described by the input, evaluating its static predicates: Path,
+// PathSubtree, PathRegexp, Host, Method, Methods, Header and
+// HeaderRegexp. The candidate routes are returned in the order of the
+// input list. When a route has multiple predicates, all of them need to
+// match, including multiple regular expressions of the same kind.
+//
+// Predicates that cannot be evaluated statically are handled according to
+// the MatchCustomPredicates field of the input.
+func MatchRoutes(routes []*Route, in MatchInput) []*Route {
+	var m []*Route
+	for _, r := range routes {
+		if matchRoute(r, in) {
+			m = append(m, r)
+		}
+	}
+
+	return m
+}
+
+// CompiledRegexp describes a regexp predicate of a route, with its compiled
+// regular expression.
+type CompiledRegexp struct {
+	// Predicate is the name of the predicate, e.g. PathRegexp.
+	Predicate string
+
+	// Header contains the header name of the HeaderRegexp predicates.
+	Header string
+
+	// Source is the regular expression as it appears in the route.
+	Source string
+
+	// Regexp is the compiled regular expression.
+	Regexp *regexp.Regexp
+}
+
+// RegexpPredicates returns the PathRegexp, Host, HostRegexp and HeaderRegexp
+// predicates of the route, with their compiled regular expressions, e.g. to
+// test them against sample inputs. The predicates are returned in the order
+// of the canonical form of the route, see Canonical(). The compiled regular
+// expressions are cached and shared, and they must not be modified. When a
+// regular expression is invalid, it returns an error naming the predicate.
+func (r *Route) RegexpPredicates() ([]CompiledRegexp, error) {
+	var c []CompiledRegexp
+	for _, p := range Canonical(r).Predicates {
+		var header, source string
+		switch p.Name {
+		case "PathRegexp", "Host", "HostRegexp":
+			a, err := getStringArgs(1, p.Args)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s predicate: %w", p.Name, err)
+			}
+
+			source = a[0]
+		case "HeaderRegexp":
+			a, err := getStringArgs(2, p.Args)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s predicate: %w", p.Name, err)
+			}
+
+			header, source = a[0], a[1]
+		default:
+			continue
+		}
+
+		rx, err := compileRegexp(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp in predicate %s: %w", p.Name, err)
+		}
+
+		c = append(c, CompiledRegexp{Predicate: p.Name, Header: header, Source: source, Regexp: rx})
+	}
+
+	return c, nil
+}
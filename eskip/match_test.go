@@ -0,0 +1,193 @@
+package eskip
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const matchRoutesDoc = `
+	catchAll: * -> "https://www.example.org";
+	path: Path("/foo") -> "https://foo.example.org";
+	pathWildcard: Path("/users/:id") -> "https://users.example.org";
+	pathFreeWildcard: Path("/static/*file") -> "https://static.example.org";
+	pathSubtree: PathSubtree("/api") -> "https://api.example.org";
+	pathRegexp: PathRegexp(/^\/v[0-9]+\//) -> "https://versioned.example.org";
+	host: Host(/^www[.]example[.]org$/) -> "https://host.example.org";
+	method: Method("POST") && Path("/foo") -> "https://post.example.org";
+	methods: Methods("PUT", "patch") && Path("/foo") -> "https://put.example.org";
+	header: Header("X-Foo", "bar") -> "https://header.example.org";
+	headerRegexp: HeaderRegexp("X-Foo", /^ba/) -> "https://header-regexp.example.org";
+	custom: Traffic(.3) -> "https://custom.example.org";
+`
+
+func TestMatchRoutes(t *testing.T) {
+	routes, err := Parse(matchRoutesDoc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title  string
+		input  MatchInput
+		expect []string
+	}{{
+		title:  "catch all only",
+		input:  MatchInput{Path: "/bar", Method: "GET"},
+		expect: []string{"catchAll"},
+	}, {
+		title:  "custom predicates matching",
+		input:  MatchInput{Path: "/bar", Method: "GET", MatchCustomPredicates: true},
+		expect: []string{"catchAll", "custom"},
+	}, {
+		title:  "exact path",
+		input:  MatchInput{Path: "/foo", Method: "GET"},
+		expect: []string{"catchAll", "path"},
+	}, {
+		title:  "method",
+		input:  MatchInput{Path: "/foo", Method: "POST"},
+		expect: []string{"catchAll", "path", "method"},
+	}, {
+		title:  "method in a different case",
+		input:  MatchInput{Path: "/foo", Method: "post"},
+		expect: []string{"catchAll", "path", "method"},
+	}, {
+		title:  "methods in a different case",
+		input:  MatchInput{Path: "/foo", Method: "put"},
+		expect: []string{"catchAll", "path", "methods"},
+	}, {
+		title:  "methods",
+		input:  MatchInput{Path: "/foo", Method: "PATCH"},
+		expect: []string{"catchAll", "path", "methods"},
+	}, {
+		title:  "path wildcard",
+		input:  MatchInput{Path: "/users/42"},
+		expect: []string{"catchAll", "pathWildcard"},
+	}, {
+		title:  "path wildcard doesn't match sub path",
+		input:  MatchInput{Path: "/users/42/orders"},
+		expect: []string{"catchAll"},
+	}, {
+		title:  "path free wildcard",
+		input:  MatchInput{Path: "/static/css/main.css"},
+		expect: []string{"catchAll", "pathFreeWildcard"},
+	}, {
+		title:  "path subtree root",
+		input:  MatchInput{Path: "/api"},
+		expect: []string{"catchAll", "pathSubtree"},
+	}, {
+		title:  "path subtree",
+		input:  MatchInput{Path: "/api/users"},
+		expect: []string{"catchAll", "pathSubtree"},
+	}, {
+		title:  "path regexp",
+		input:  MatchInput{Path: "/v2/users"},
+		expect: []string{"catchAll", "pathRegexp"},
+	}, {
+		title:  "host",
+		input:  MatchInput{Path: "/bar", Host: "www.example.org"},
+		expect: []string{"catchAll", "host"},
+	}, {
+		title:  "header exact and regexp",
+		input:  MatchInput{Path: "/bar", Headers: http.Header{"X-Foo": []string{"bar"}}},
+		expect: []string{"catchAll", "header", "headerRegexp"},
+	}, {
+		title:  "header regexp",
+		input:  MatchInput{Path: "/bar", Headers: http.Header{"X-Foo": []string{"baz"}}},
+		expect: []string{"catchAll", "headerRegexp"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var ids []string
+			for _, r := range MatchRoutes(routes, test.input) {
+				ids = append(ids, r.Id)
+			}
+
+			if d := cmp.Diff(test.expect, ids); d != "" {
+				t.Error("failed to match routes")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestRegexpPredicates(t *testing.T) {
+	r, err := Parse(`
+		HeaderRegexp("X-Foo", /^bar/) && Path("/foo") && PathRegexp(/[.]json$/) && Host(/^www[.]example[.]org$/) && Custom("^foo$")
+		-> <shunt>
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := r[0].RegexpPredicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct{ Predicate, Header, Source string }
+	var items []item
+	for _, ci := range c {
+		if ci.Regexp == nil || ci.Regexp.String() != ci.Source {
+			t.Errorf("invalid compiled regexp for %s", ci.Predicate)
+		}
+
+		items = append(items, item{ci.Predicate, ci.Header, ci.Source})
+	}
+
+	if d := cmp.Diff([]item{
+		{"HeaderRegexp", "X-Foo", "^bar"},
+		{"Host", "", "^www[.]example[.]org$"},
+		{"PathRegexp", "", "[.]json$"},
+	}, items); d != "" {
+		t.Error(d)
+	}
+
+	if !c[0].Regexp.MatchString("barbaz") || c[2].Regexp.MatchString("/foo.xml") {
+		t.Error("failed to compile the regexps")
+	}
+
+	_, err = (&Route{PathRegexps: []string{"^(foo"}}).RegexpPredicates()
+	if err == nil || err.Error() != "invalid regexp in predicate PathRegexp: error parsing regexp: missing closing ): `^(foo`" {
+		t.Errorf("failed to fail with the right error: %v", err)
+	}
+}
+
+func TestMatchRoutesMultiplePathRegexps(t *testing.T) {
+	routes, err := Parse(`
+		both: PathRegexp("^/some") && PathRegexp(/\w+Id$/) -> "https://both.example.org";
+		catchAll: * -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff([]string{"^/some", `\w+Id$`}, routes[0].PathRegexps); d != "" {
+		t.Fatal(d)
+	}
+
+	for _, test := range []struct {
+		path   string
+		expect []string
+	}{{
+		path:   "/some/userId",
+		expect: []string{"both", "catchAll"},
+	}, {
+		path:   "/some/user",
+		expect: []string{"catchAll"},
+	}, {
+		path:   "/other/userId",
+		expect: []string{"catchAll"},
+	}} {
+		t.Run(test.path, func(t *testing.T) {
+			var ids []string
+			for _, r := range MatchRoutes(routes, MatchInput{Path: test.path}) {
+				ids = append(ids, r.Id)
+			}
+
+			if d := cmp.Diff(test.expect, ids); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package eskip
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergePolicy tells MergeTables how to resolve the conflicts between the
+// routes with the same ID.
+type MergePolicy int
+
+const (
+	// OverrideWins keeps the route of the override table.
+	OverrideWins MergePolicy = iota
+
+	// BaseWins keeps the route of the base table.
+	BaseWins
+
+	// ErrorOnConflict makes MergeTables fail when the tables have
+	// routes with the same ID.
+	ErrorOnConflict
+)
+
+func routesByID(routes []*Route) map[string]*Route {
+	m := make(map[string]*Route, len(routes))
+	for _, r := range routes {
+		m[r.Id] = r
+	}
+
+	return m
+}
+
+// MergeTables merges two routing tables, resolving the conflicts between
+// the routes with the same ID by the policy. The result is ordered by the
+// route IDs. With the ErrorOnConflict policy, it returns an error listing
+// every conflicting route ID. When a table contains the same ID multiple
+// times, its last route with the ID is used. The routes are not copied.
+func MergeTables(base, override []*Route, policy MergePolicy) ([]*Route, error) {
+	if policy < OverrideWins || policy > ErrorOnConflict {
+		return nil, fmt.Errorf("unknown merge policy: %d", policy)
+	}
+
+	merged, overrides := routesByID(base), routesByID(override)
+	var conflicts []string
+	for id, r := range overrides {
+		if _, ok := merged[id]; ok {
+			conflicts = append(conflicts, id)
+			if policy == BaseWins {
+				continue
+			}
+		}
+
+		merged[id] = r
+	}
+
+	if policy == ErrorOnConflict && len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("conflicting route ids: %s", strings.Join(conflicts, ", "))
+	}
+
+	result := make([]*Route, 0, len(merged))
+	for _, r := range merged {
+		result = append(result, r)
+	}
+
+	sort.Slice(result, compareRouteID(result))
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeTables(t *testing.T) {
+	base, err := Parse(`
+		r2: Path("/base") -> <shunt>;
+		r1: Path("/foo") -> "https://base.example.org";
+		r3: Path("/bar") -> "https://base.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	override, err := Parse(`
+		r3: Path("/bar") -> "https://override.example.org";
+		r4: Path("/override") -> <shunt>;
+		r1: Path("/foo") -> "https://override.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := func(r []*Route) []string {
+		var b []string
+		for _, ri := range r {
+			b = append(b, ri.Id+" "+ri.Backend)
+		}
+
+		return b
+	}
+
+	for _, test := range []struct {
+		title  string
+		policy MergePolicy
+		expect []string
+		err    string
+	}{{
+		title:  "override wins",
+		policy: OverrideWins,
+		expect: []string{
+			"r1 https://override.example.org",
+			"r2 ",
+			"r3 https://override.example.org",
+			"r4 ",
+		},
+	}, {
+		title:  "base wins",
+		policy: BaseWins,
+		expect: []string{
+			"r1 https://base.example.org",
+			"r2 ",
+			"r3 https://base.example.org",
+			"r4 ",
+		},
+	}, {
+		title:  "error on conflict",
+		policy: ErrorOnConflict,
+		err:    "conflicting route ids: r1, r3",
+	}, {
+		title:  "unknown policy",
+		policy: ErrorOnConflict + 1,
+		err:    "unknown merge policy: 3",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := MergeTables(base, override, test.policy)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("failed to fail with the right error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, backends(r)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+
+	t.Run("no conflicts", func(t *testing.T) {
+		r, err := MergeTables(base[:1], override[1:2], ErrorOnConflict)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d := cmp.Diff([]string{"r2", "r4"}, routeIDs(r)); d != "" {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package eskip
+
+// the deprecated predicate names and their replacements, with the same args
+var deprecatedPredicates = map[string]string{
+	"Source":     "ClientIP",
+	"HostRegexp": "Host",
+}
+
+// Migration describes a deprecated predicate replaced in a route by
+// MigrateDeprecatedPredicates.
+type Migration struct {
+	RouteID string
+	From    string
+	To      string
+}
+
+// replaces the deprecated predicates in place
+func migratePredicates(id string, p []*Predicate) []Migration {
+	var m []Migration
+	for _, pi := range p {
+		if to, ok := deprecatedPredicates[pi.Name]; ok {
+			m = append(m, Migration{RouteID: id, From: pi.Name, To: to})
+			pi.Name = to
+		}
+
+		if pi.Name == "Not" {
+			for _, a := range pi.Args {
+				if np, ok := a.(*Predicate); ok {
+					m = append(m, migratePredicates(id, []*Predicate{np})...)
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// MigrateDeprecatedPredicates replaces the deprecated predicates in the
+// routes with their current form, e.g. Source() with ClientIP(), keeping
+// their args. The predicates wrapped by Not() are migrated, too.
+//
+// It returns the routes with the migrated routes replaced by their
+// modified copies, and the list of the replaced predicates. The input
+// routes are not modified.
+func MigrateDeprecatedPredicates(routes []*Route) ([]*Route, []Migration) {
+	var migrations []Migration
+	result := make([]*Route, len(routes))
+	for i, r := range routes {
+		c := r.Copy()
+		if m := migratePredicates(c.Id, c.Predicates); len(m) > 0 {
+			result[i] = c
+			migrations = append(migrations, m...)
+		} else {
+			result[i] = r
+		}
+	}
+
+	return result, migrations
+}
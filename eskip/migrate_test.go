@@ -0,0 +1,87 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrateDeprecatedPredicates(t *testing.T) {
+	for _, test := range []struct {
+		title      string
+		routes     string
+		expect     string
+		migrations []Migration
+	}{{
+		title:  "no deprecated predicates",
+		routes: `r1: Path("/foo") && ClientIP("10.0.0.0/8") -> <shunt>`,
+		expect: `r1: Path("/foo") && ClientIP("10.0.0.0/8") -> <shunt>`,
+	}, {
+		title:  "source",
+		routes: `r1: Source("1.2.3.4/26", "10.5.5.0/24") -> status(201) -> <shunt>`,
+		expect: `r1: ClientIP("1.2.3.4/26", "10.5.5.0/24") -> status(201) -> <shunt>`,
+		migrations: []Migration{
+			{RouteID: "r1", From: "Source", To: "ClientIP"},
+		},
+	}, {
+		title: "only the predicate name is matched",
+		routes: `
+			r1: SourceFromLast("10.0.0.0/8") -> setRequestHeader("X-Source", "Source(1)") -> <shunt>;
+			r2: Path("/Source(foo)") -> <shunt>
+		`,
+		expect: `
+			r1: SourceFromLast("10.0.0.0/8") -> setRequestHeader("X-Source", "Source(1)") -> <shunt>;
+			r2: Path("/Source(foo)") -> <shunt>
+		`,
+	}, {
+		title: "multiple routes and predicates",
+		routes: `
+			r1: Source("10.0.0.0/8") && HostRegexp(/^example[.]org$/) -> <shunt>;
+			r2: Path("/bar") -> <shunt>;
+			r3: Not(Source("10.0.0.0/8")) -> <shunt>
+		`,
+		expect: `
+			r1: ClientIP("10.0.0.0/8") && Host(/^example[.]org$/) -> <shunt>;
+			r2: Path("/bar") -> <shunt>;
+			r3: Not(ClientIP("10.0.0.0/8")) -> <shunt>
+		`,
+		migrations: []Migration{
+			{RouteID: "r1", From: "Source", To: "ClientIP"},
+			{RouteID: "r1", From: "HostRegexp", To: "Host"},
+			{RouteID: "r3", From: "Source", To: "ClientIP"},
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			original := CopyRoutes(r)
+			result, migrations := MigrateDeprecatedPredicates(r)
+			if !EqLists(expect, result) {
+				t.Errorf("invalid migration, got: %s, expected: %s", Print(PrettyPrintInfo{}, result...), test.expect)
+			}
+
+			if d := cmp.Diff(test.migrations, migrations); d != "" {
+				t.Error("invalid migration report")
+				t.Log(d)
+			}
+
+			if !EqLists(original, r) {
+				t.Error("the input routes were modified")
+			}
+
+			for i := range r {
+				if !Eq(r[i], expect[i]) && result[i] == r[i] {
+					t.Errorf("failed to copy the migrated route %s", r[i].Id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package eskip
+
+import "sort"
+
+func routeWeight(predicates []*Predicate) float64 {
+	var w float64
+	for _, p := range predicates {
+		if p.Name != "Weight" || len(p.Args) != 1 {
+			continue
+		}
+
+		if wi, ok := p.Args[0].(float64); ok {
+			w += wi
+		}
+	}
+
+	return w
+}
+
+// SortByOrder sorts the routes in place, in ascending order of their Order
+// field. When the order of two routes is the same, the route with the higher
+// weight, as set by the Weight predicate, comes first, and when the weights
+// are the same, the route with more predicates comes first. Otherwise, the
+// original order of the routes is kept.
+func SortByOrder(routes []*Route) {
+	predicates := make(map[*Route][]*Predicate, len(routes))
+	for _, r := range routes {
+		predicates[r] = Canonical(r).Predicates
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		ri, rj := routes[i], routes[j]
+		if ri.Order != rj.Order {
+			return ri.Order < rj.Order
+		}
+
+		pi, pj := predicates[ri], predicates[rj]
+		if wi, wj := routeWeight(pi), routeWeight(pj); wi != wj {
+			return wi > wj
+		}
+
+		return len(pi) > len(pj)
+	})
+}
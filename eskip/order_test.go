@@ -0,0 +1,133 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOrderAnnotation(t *testing.T) {
+	t.Run("parse", func(t *testing.T) {
+		r, err := Parse(`
+			// @order=10
+			// @team=payments
+			r1: * -> <shunt>;
+
+			// @order=-2
+			r2: * -> <shunt>;
+
+			r3: * -> <shunt>;
+		`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r[0].Order != 10 || r[1].Order != -2 || r[2].Order != 0 {
+			t.Errorf("failed to parse the order: %d, %d, %d", r[0].Order, r[1].Order, r[2].Order)
+		}
+
+		if d := cmp.Diff(map[string]string{"team": "payments"}, r[0].Annotations); d != "" {
+			t.Error("invalid annotations")
+			t.Log(d)
+		}
+
+		if r[1].Annotations != nil {
+			t.Error("unexpected annotations")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Parse(`
+			// @order=first
+			r1: * -> <shunt>;
+		`)
+
+		const expect = "invalid order annotation in route r1: first"
+		if err == nil || err.Error() != expect {
+			t.Errorf("unexpected error, got: %v, expected: %s", err, expect)
+		}
+	})
+
+	t.Run("round-trip", func(t *testing.T) {
+		routes := []*Route{{
+			Id:          "r1",
+			Order:       10,
+			Annotations: map[string]string{"team": "payments"},
+			BackendType: ShuntBackend,
+		}, {
+			Id:          "r2",
+			BackendType: ShuntBackend,
+		}}
+
+		s := String(routes...)
+		const expect = "// @order=10\n// @team=payments\nr1: * -> <shunt>;\nr2: * -> <shunt>;"
+		if s != expect {
+			t.Errorf("invalid routes string, got: %s, expected: %s", s, expect)
+		}
+
+		r, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r[0].Order != 10 || r[1].Order != 0 {
+			t.Error("failed to round-trip the order")
+		}
+
+		b, err := MarshalRoutesJSON(routes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rj, err := UnmarshalRoutesJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rj[0].Order != 10 || rj[0].Annotations["team"] != "payments" || len(rj[0].Annotations) != 1 {
+			t.Errorf("failed to round-trip the order through JSON: %s", string(b))
+		}
+
+		if c := routes[0].Copy(); c.Order != 10 {
+			t.Error("failed to copy the order")
+		}
+
+		if c := Copy(routes[0]); c.Order != 10 {
+			t.Error("failed to copy the order")
+		}
+	})
+}
+
+func TestSortByOrder(t *testing.T) {
+	r, err := Parse(`
+		// @order=2
+		late: Path("/late") -> <shunt>;
+
+		unordered: Path("/unordered") -> <shunt>;
+
+		// @order=1
+		general: Path("/foo") -> <shunt>;
+
+		// @order=1
+		specific: Path("/foo") && Method("GET") -> <shunt>;
+
+		// @order=1
+		weighted: Path("/foo") && Weight(5) -> <shunt>;
+
+		// @order=-1
+		first: * -> <shunt>;
+
+		unordered2: Path("/unordered2") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SortByOrder(r)
+	if d := cmp.Diff(
+		[]string{"first", "unordered", "unordered2", "weighted", "specific", "general", "late"},
+		routeIDs(r),
+	); d != "" {
+		t.Error(d)
+	}
+}
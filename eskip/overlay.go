@@ -0,0 +1,162 @@
+package eskip
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FilterOverlay contains the filters to be applied to a single route by
+// ApplyFilterOverlay.
+//
+// In JSON, each field can contain either an eskip filter chain as a string,
+// or an array of filters in the same format as MarshalJSON produces:
+//
+//	{
+//		"r1": {"prepend": "setRequestHeader(\"X-Env\", \"prod\")"},
+//		"r2": {"replace": [{"name": "status", "args": [404]}]}
+//	}
+type FilterOverlay struct {
+	// Prepend contains the filters to be inserted before the filters
+	// of the route.
+	Prepend []*Filter `json:"prepend,omitempty"`
+
+	// Append contains the filters to be appended after the filters of
+	// the route.
+	Append []*Filter `json:"append,omitempty"`
+
+	// Replace, when not nil, replaces the original filters of the
+	// route. An empty, non-nil Replace removes the original filters.
+	Replace []*Filter `json:"replace,omitempty"`
+}
+
+func unmarshalOverlayFilters(data json.RawMessage) ([]*Filter, error) {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+
+	var chain string
+	if err := json.Unmarshal(data, &chain); err == nil {
+		f, err := ParseFilters(chain)
+		if err != nil {
+			return nil, err
+		}
+
+		if f == nil {
+			f = []*Filter{}
+		}
+
+		return f, nil
+	}
+
+	var f []*Filter
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	if f == nil {
+		f = []*Filter{}
+	}
+
+	return f, nil
+}
+
+// UnmarshalJSON parses a filter overlay, where the filters can be defined
+// either as eskip filter chains or as arrays of filters.
+func (o *FilterOverlay) UnmarshalJSON(data []byte) error {
+	var jo struct {
+		Prepend json.RawMessage `json:"prepend"`
+		Append  json.RawMessage `json:"append"`
+		Replace json.RawMessage `json:"replace"`
+	}
+
+	if err := json.Unmarshal(data, &jo); err != nil {
+		return err
+	}
+
+	var (
+		u   FilterOverlay
+		err error
+	)
+
+	if u.Prepend, err = unmarshalOverlayFilters(jo.Prepend); err != nil {
+		return err
+	}
+
+	if u.Append, err = unmarshalOverlayFilters(jo.Append); err != nil {
+		return err
+	}
+
+	if u.Replace, err = unmarshalOverlayFilters(jo.Replace); err != nil {
+		return err
+	}
+
+	*o = u
+	return nil
+}
+
+// ParseFilterOverlay parses the filter overlays keyed by the route IDs from
+// JSON. See FilterOverlay for the format.
+func ParseFilterOverlay(data []byte) (map[string]FilterOverlay, error) {
+	var overlay map[string]FilterOverlay
+	if err := json.Unmarshal(data, &overlay); err != nil {
+		return nil, err
+	}
+
+	return overlay, nil
+}
+
+// ApplyFilterOverlay applies the filter overlays to the routes with the
+// matching IDs. Unlike DefaultFilters, that applies the same filters to
+// every route, the overlay defines the filters for each route separately.
+// When an overlay has Replace, it replaces the original filters first, and
+// then its Prepend and Append filters are added.
+//
+// The routes without an overlay are returned unchanged, and the input
+// routes are not modified. It returns an error when the overlay contains
+// IDs of routes that are not found.
+func ApplyFilterOverlay(routes []*Route, overlay map[string]FilterOverlay) ([]*Route, error) {
+	ids := make(map[string]bool)
+	for _, r := range routes {
+		ids[r.Id] = true
+	}
+
+	var unknown []string
+	for id := range overlay {
+		if !ids[id] {
+			unknown = append(unknown, id)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("filter overlay for unknown routes: %s", strings.Join(unknown, ", "))
+	}
+
+	result := make([]*Route, len(routes))
+	for i, r := range routes {
+		o, ok := overlay[r.Id]
+		if !ok {
+			result[i] = r
+			continue
+		}
+
+		filters := r.Filters
+		if o.Replace != nil {
+			filters = o.Replace
+		}
+
+		next := make([]*Filter, 0, len(o.Prepend)+len(filters)+len(o.Append))
+		next = append(next, CopyFilters(o.Prepend)...)
+		next = append(next, CopyFilters(filters)...)
+		next = append(next, CopyFilters(o.Append)...)
+
+		result[i] = new(Route)
+		*result[i] = *r
+		result[i].Filters = next
+	}
+
+	return result, nil
+}
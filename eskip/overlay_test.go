@@ -0,0 +1,135 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestApplyFilterOverlay(t *testing.T) {
+	routes, err := Parse(`
+		r1: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+		r2: Path("/bar") -> setPath("/") -> "https://bar.example.org";
+		r3: * -> status(404) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title   string
+		overlay string
+		expect  string
+		err     string
+	}{{
+		title:   "empty overlay",
+		overlay: `{}`,
+		expect: `
+			r1: Path("/foo") -> setPath("/") -> "https://foo.example.org";
+			r2: Path("/bar") -> setPath("/") -> "https://bar.example.org";
+			r3: * -> status(404) -> <shunt>;
+		`,
+	}, {
+		title: "prepend, append and replace",
+		overlay: `{
+			"r1": {"prepend": "setRequestHeader(\"X-Env\", \"prod\") -> foo()", "append": "bar()"},
+			"r2": {"replace": [{"name": "status", "args": [503]}], "append": [{"name": "baz", "args": []}]},
+			"r3": {"replace": ""}
+		}`,
+		expect: `
+			r1: Path("/foo") -> setRequestHeader("X-Env", "prod") -> foo() -> setPath("/") -> bar() -> "https://foo.example.org";
+			r2: Path("/bar") -> status(503) -> baz() -> "https://bar.example.org";
+			r3: * -> <shunt>;
+		`,
+	}, {
+		title:   "unknown routes",
+		overlay: `{"r4": {"append": "foo()"}, "r1": {"append": "foo()"}, "r0": {}}`,
+		err:     "filter overlay for unknown routes: r0, r4",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			overlay, err := ParseFilterOverlay([]byte(test.overlay))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := String(routes...)
+			result, err := ApplyFilterOverlay(routes, overlay)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("unexpected error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expect, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(String(expect...), String(result...)); d != "" {
+				t.Error("failed to apply the overlay")
+				t.Log(d)
+			}
+
+			if String(routes...) != before {
+				t.Error("the input routes were modified")
+			}
+		})
+	}
+}
+
+func TestParseFilterOverlay(t *testing.T) {
+	for _, test := range []struct {
+		title   string
+		overlay string
+		expect  map[string]FilterOverlay
+		fail    bool
+	}{{
+		title:   "eskip and JSON filters",
+		overlay: `{"r1": {"prepend": "foo(42)", "append": [{"name": "bar", "args": ["baz"]}]}}`,
+		expect: map[string]FilterOverlay{"r1": {
+			Prepend: []*Filter{{Name: "foo", Args: []interface{}{float64(42)}}},
+			Append:  []*Filter{{Name: "bar", Args: []interface{}{"baz"}}},
+		}},
+	}, {
+		title:   "empty replace",
+		overlay: `{"r1": {"replace": []}, "r2": {"replace": null}}`,
+		expect: map[string]FilterOverlay{
+			"r1": {Replace: []*Filter{}},
+			"r2": {},
+		},
+	}, {
+		title:   "invalid eskip",
+		overlay: `{"r1": {"prepend": "foo("}}`,
+		fail:    true,
+	}, {
+		title:   "invalid type",
+		overlay: `{"r1": {"prepend": 42}}`,
+		fail:    true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			o, err := ParseFilterOverlay([]byte(test.overlay))
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, o); d != "" {
+				t.Error("failed to parse the overlay")
+				t.Log(d)
+			}
+		})
+	}
+}
@@ -10,15 +10,13 @@ import __yyfmt__ "fmt"
 
 //line parser.y:19
 
-import "strconv"
-
 // conversion error ignored, tokenizer expression already checked format
 func convertNumber(s string) float64 {
-	n, _ := strconv.ParseFloat(s, 64)
+	n, _ := parseNumber(s)
 	return n
 }
 
-//line parser.y:31
+//line parser.y:29
 type eskipSymType struct {
 	yys         int
 	token       string
@@ -41,6 +39,10 @@ type eskipSymType struct {
 	stringvals  []string
 	lbAlgorithm string
 	lbEndpoints []string
+	// comment trailing the previous token in the same line
+	comment string
+	// annotations in the comment lines preceding the token
+	annotations map[string]string
 }
 
 const and = 57346
@@ -60,6 +62,9 @@ const stringliteral = 57359
 const symbol = 57360
 const openarrow = 57361
 const closearrow = 57362
+const b64literal = 57363
+const durationliteral = 57364
+const sizeliteral = 57365
 
 var eskipToknames = [...]string{
 	"$end",
@@ -82,6 +87,9 @@ var eskipToknames = [...]string{
 	"symbol",
 	"openarrow",
 	"closearrow",
+	"b64literal",
+	"durationliteral",
+	"sizeliteral",
 }
 
 var eskipStatenames = [...]string{}
@@ -90,7 +98,7 @@ const eskipEofCode = 1
 const eskipErrCode = 2
 const eskipInitialStackSize = 16
 
-//line parser.y:287
+//line parser.y:316
 
 //line yacctab:1
 var eskipExca = [...]int{
@@ -101,62 +109,68 @@ var eskipExca = [...]int{
 
 const eskipPrivate = 57344
 
-const eskipLast = 62
+const eskipLast = 75
 
 var eskipAct = [...]int{
-	34, 40, 32, 31, 24, 17, 20, 21, 22, 25,
-	27, 26, 19, 48, 36, 9, 37, 25, 41, 9,
-	16, 25, 25, 3, 10, 7, 14, 42, 29, 43,
-	4, 55, 8, 45, 44, 49, 45, 30, 28, 19,
-	50, 15, 13, 47, 46, 38, 23, 51, 52, 39,
-	53, 42, 54, 12, 35, 11, 33, 18, 5, 6,
-	2, 1,
+	35, 44, 33, 31, 24, 17, 9, 53, 25, 45,
+	16, 40, 19, 41, 25, 10, 9, 9, 25, 29,
+	14, 7, 37, 38, 39, 47, 40, 46, 41, 29,
+	8, 3, 54, 25, 28, 4, 32, 37, 38, 39,
+	60, 55, 49, 19, 13, 30, 15, 52, 51, 50,
+	23, 56, 57, 42, 43, 58, 46, 59, 20, 21,
+	22, 25, 27, 26, 48, 12, 49, 11, 36, 34,
+	18, 5, 6, 2, 1,
 }
 
 var eskipPact = [...]int{
-	14, -1000, 11, -1000, -1000, 49, 34, -1000, 15, -1000,
-	2, -8, 10, 10, 4, -1000, -1000, -1000, 39, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, 0, 18, -1000, 15,
-	-1000, 27, -1000, -1000, -1000, -1000, -1000, -1000, -8, -7,
-	26, 31, -1000, 4, -1000, 4, -1000, -1000, -1000, 5,
-	5, 24, -1000, -1000, 26, -1000,
+	12, -1000, 2, -1000, -1000, 61, 36, -1000, 9, -1000,
+	-8, 44, 11, 11, 1, -1000, -1000, -1000, 47, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -9, 14, -1000, 9,
+	-1000, 57, 42, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, 44, -13, 23, 32, -1000, 16, -1000, 16,
+	-1000, -1000, -1000, -1000, -3, -3, 33, -1000, -1000, 23,
+	-1000,
 }
 
 var eskipPgo = [...]int{
-	0, 61, 60, 23, 30, 59, 58, 5, 57, 25,
-	3, 4, 2, 56, 0, 54, 1, 49, 46,
+	0, 74, 73, 31, 35, 72, 71, 5, 70, 21,
+	3, 4, 2, 69, 0, 68, 1, 54, 50,
 }
 
 var eskipR1 = [...]int{
 	0, 1, 1, 2, 2, 2, 2, 4, 5, 3,
-	3, 6, 6, 9, 9, 8, 8, 11, 10, 10,
-	10, 12, 12, 12, 16, 16, 17, 17, 18, 7,
-	7, 7, 7, 7, 13, 14, 15,
+	3, 6, 6, 9, 9, 9, 8, 8, 11, 10,
+	10, 10, 12, 12, 12, 12, 12, 12, 16, 16,
+	17, 17, 18, 7, 7, 7, 7, 7, 13, 14,
+	15,
 }
 
 var eskipR2 = [...]int{
 	0, 1, 1, 0, 1, 3, 2, 3, 1, 3,
-	5, 1, 3, 1, 4, 1, 3, 4, 0, 1,
-	3, 1, 1, 1, 1, 3, 1, 3, 3, 1,
-	1, 1, 1, 1, 1, 1, 1,
+	5, 1, 3, 1, 4, 4, 1, 3, 4, 0,
+	1, 3, 1, 1, 1, 1, 1, 1, 1, 3,
+	1, 3, 3, 1, 1, 1, 1, 1, 1, 1,
+	1,
 }
 
 var eskipChk = [...]int{
 	-1000, -1, -2, -3, -4, -6, -5, -9, 18, 5,
 	13, 6, 4, 8, 11, -4, 18, -7, -8, -14,
 	14, 15, 16, -18, -11, 17, 19, 18, -9, 18,
-	-3, -10, -12, -13, -14, -15, 10, 12, 6, -17,
-	-16, 18, -14, 11, 7, 9, -7, -11, 20, 9,
-	9, -10, -12, -14, -16, 7,
+	-3, -10, -9, -12, -13, -14, -15, 21, 22, 23,
+	10, 12, 6, -17, -16, 18, -14, 11, 7, 9,
+	7, -7, -11, 20, 9, 9, -10, -12, -14, -16,
+	7,
 }
 
 var eskipDef = [...]int{
 	3, -2, 1, 2, 4, 0, 0, 11, 8, 13,
-	6, 0, 0, 0, 18, 5, 8, 9, 0, 29,
-	30, 31, 32, 33, 15, 35, 0, 0, 12, 0,
-	7, 0, 19, 21, 22, 23, 34, 36, 0, 0,
-	26, 0, 24, 18, 14, 0, 10, 16, 28, 0,
-	0, 0, 20, 25, 27, 17,
+	6, 0, 0, 0, 19, 5, 8, 9, 0, 33,
+	34, 35, 36, 37, 16, 39, 0, 0, 12, 0,
+	7, 0, 0, 20, 22, 23, 24, 25, 26, 27,
+	38, 40, 0, 0, 30, 0, 28, 19, 14, 0,
+	15, 10, 17, 32, 0, 0, 0, 21, 29, 31,
+	18,
 }
 
 var eskipTok1 = [...]int{
@@ -165,7 +179,8 @@ var eskipTok1 = [...]int{
 
 var eskipTok2 = [...]int{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17, 18, 19, 20,
+	12, 13, 14, 15, 16, 17, 18, 19, 20, 21,
+	22, 23,
 }
 
 var eskipTok3 = [...]int{
@@ -511,54 +526,57 @@ eskipdefault:
 
 	case 1:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:75
+//line parser.y:80
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 2:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:80
+//line parser.y:85
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 			eskiplex.(*eskipLex).routes = eskipVAL.routes
 		}
 	case 4:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:87
+//line parser.y:92
 		{
 			eskipVAL.routes = []*parsedRoute{eskipDollar[1].route}
 		}
 	case 5:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:91
+//line parser.y:96
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 			eskipVAL.routes = append(eskipVAL.routes, eskipDollar[3].route)
 		}
 	case 6:
 		eskipDollar = eskipS[eskippt-2 : eskippt+1]
-//line parser.y:96
+//line parser.y:101
 		{
 			eskipVAL.routes = eskipDollar[1].routes
 		}
 	case 7:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:101
+//line parser.y:106
 		{
 			eskipVAL.route = eskipDollar[3].route
 			eskipVAL.route.id = eskipDollar[1].token
+			if eskipDollar[1].annotations != nil {
+				eskipVAL.route.annotations = eskipDollar[1].annotations
+			}
 		}
 	case 8:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:107
+//line parser.y:115
 		{
 			eskipVAL.token = eskipDollar[1].token
 			eskiplex.(*eskipLex).lastRouteID = eskipDollar[1].token
 		}
 	case 9:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:113
+//line parser.y:121
 		{
 			eskipVAL.route = &parsedRoute{
 				matchers:    eskipDollar[1].matchers,
@@ -569,14 +587,16 @@ eskipdefault:
 				lbBackend:   eskipDollar[3].lbBackend,
 				lbAlgorithm: eskipDollar[3].lbAlgorithm,
 				lbEndpoints: eskipDollar[3].lbEndpoints,
+				annotations: eskipDollar[1].annotations,
 			}
 			eskipDollar[1].matchers = nil
 			eskipDollar[3].lbEndpoints = nil
 		}
 	case 10:
 		eskipDollar = eskipS[eskippt-5 : eskippt+1]
-//line parser.y:128
+//line parser.y:137
 		{
+			eskipDollar[3].filters[len(eskipDollar[3].filters)-1].Comment = eskipDollar[4].comment
 			eskipVAL.route = &parsedRoute{
 				matchers:    eskipDollar[1].matchers,
 				filters:     eskipDollar[3].filters,
@@ -587,6 +607,7 @@ eskipdefault:
 				lbBackend:   eskipDollar[5].lbBackend,
 				lbAlgorithm: eskipDollar[5].lbAlgorithm,
 				lbEndpoints: eskipDollar[5].lbEndpoints,
+				annotations: eskipDollar[1].annotations,
 			}
 			eskipDollar[1].matchers = nil
 			eskipDollar[3].filters = nil
@@ -594,119 +615,145 @@ eskipdefault:
 		}
 	case 11:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:146
+//line parser.y:157
 		{
 			eskipVAL.matchers = []*matcher{eskipDollar[1].matcher}
 		}
 	case 12:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:150
+//line parser.y:161
 		{
 			eskipVAL.matchers = eskipDollar[1].matchers
 			eskipVAL.matchers = append(eskipVAL.matchers, eskipDollar[3].matcher)
 		}
 	case 13:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:156
+//line parser.y:167
 		{
 			eskipVAL.matcher = &matcher{"*", nil}
 		}
 	case 14:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:160
+//line parser.y:171
 		{
 			eskipVAL.matcher = &matcher{eskipDollar[1].token, eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
 	case 15:
+		eskipDollar = eskipS[eskippt-4 : eskippt+1]
+//line parser.y:176
+		{
+			eskipVAL.matcher = &matcher{eskipDollar[1].token, []interface{}{&Predicate{eskipDollar[3].matcher.name, eskipDollar[3].matcher.args}}}
+			eskipDollar[3].matcher = nil
+		}
+	case 16:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:166
+//line parser.y:182
 		{
 			eskipVAL.filters = []*Filter{eskipDollar[1].filter}
 		}
-	case 16:
+	case 17:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:170
+//line parser.y:186
 		{
+			eskipDollar[1].filters[len(eskipDollar[1].filters)-1].Comment = eskipDollar[2].comment
 			eskipVAL.filters = eskipDollar[1].filters
 			eskipVAL.filters = append(eskipVAL.filters, eskipDollar[3].filter)
 		}
-	case 17:
+	case 18:
 		eskipDollar = eskipS[eskippt-4 : eskippt+1]
-//line parser.y:176
+//line parser.y:193
 		{
 			eskipVAL.filter = &Filter{
 				Name: eskipDollar[1].token,
 				Args: eskipDollar[3].args}
 			eskipDollar[3].args = nil
 		}
-	case 19:
+	case 20:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:185
+//line parser.y:202
 		{
 			eskipVAL.args = []interface{}{eskipDollar[1].arg}
 		}
-	case 20:
+	case 21:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:189
+//line parser.y:206
 		{
 			eskipVAL.args = eskipDollar[1].args
 			eskipVAL.args = append(eskipVAL.args, eskipDollar[3].arg)
 		}
-	case 21:
+	case 22:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:195
+//line parser.y:212
 		{
 			eskipVAL.arg = eskipDollar[1].numval
 		}
-	case 22:
+	case 23:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:199
+//line parser.y:216
 		{
 			eskipVAL.arg = eskipDollar[1].stringval
 		}
-	case 23:
+	case 24:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:203
+//line parser.y:220
 		{
 			eskipVAL.arg = eskipDollar[1].regexpval
 		}
-	case 24:
+	case 25:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:224
+		{
+			eskipVAL.arg = []byte(eskipDollar[1].token)
+		}
+	case 26:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:208
+//line parser.y:228
+		{
+			eskipVAL.arg = convertDuration(eskipDollar[1].token)
+		}
+	case 27:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:232
+		{
+			eskipVAL.arg = convertSize(eskipDollar[1].token)
+		}
+	case 28:
+		eskipDollar = eskipS[eskippt-1 : eskippt+1]
+//line parser.y:237
 		{
 			eskipVAL.stringvals = []string{eskipDollar[1].stringval}
 		}
-	case 25:
+	case 29:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:212
+//line parser.y:241
 		{
 			eskipVAL.stringvals = eskipDollar[1].stringvals
 			eskipVAL.stringvals = append(eskipVAL.stringvals, eskipDollar[3].stringval)
 		}
-	case 26:
+	case 30:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:218
+//line parser.y:247
 		{
 			eskipVAL.lbEndpoints = eskipDollar[1].stringvals
 		}
-	case 27:
+	case 31:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:222
+//line parser.y:251
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[1].token
 			eskipVAL.lbEndpoints = eskipDollar[3].stringvals
 		}
-	case 28:
+	case 32:
 		eskipDollar = eskipS[eskippt-3 : eskippt+1]
-//line parser.y:228
+//line parser.y:257
 		{
 			eskipVAL.lbAlgorithm = eskipDollar[2].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[2].lbEndpoints
 		}
-	case 29:
+	case 33:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:234
+//line parser.y:263
 		{
 			eskipVAL.backend = eskipDollar[1].stringval
 			eskipVAL.shunt = false
@@ -714,36 +761,36 @@ eskipdefault:
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 30:
+	case 34:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:242
+//line parser.y:271
 		{
 			eskipVAL.shunt = true
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 31:
+	case 35:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:249
+//line parser.y:278
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = true
 			eskipVAL.dynamic = false
 			eskipVAL.lbBackend = false
 		}
-	case 32:
+	case 36:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:256
+//line parser.y:285
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
 			eskipVAL.dynamic = true
 			eskipVAL.lbBackend = false
 		}
-	case 33:
+	case 37:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:263
+//line parser.y:292
 		{
 			eskipVAL.shunt = false
 			eskipVAL.loopback = false
@@ -752,21 +799,21 @@ eskipdefault:
 			eskipVAL.lbAlgorithm = eskipDollar[1].lbAlgorithm
 			eskipVAL.lbEndpoints = eskipDollar[1].lbEndpoints
 		}
-	case 34:
+	case 38:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:273
+//line parser.y:302
 		{
 			eskipVAL.numval = convertNumber(eskipDollar[1].token)
 		}
-	case 35:
+	case 39:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:278
+//line parser.y:307
 		{
 			eskipVAL.stringval = eskipDollar[1].token
 		}
-	case 36:
+	case 40:
 		eskipDollar = eskipS[eskippt-1 : eskippt+1]
-//line parser.y:283
+//line parser.y:312
 		{
 			eskipVAL.regexpval = eskipDollar[1].token
 		}
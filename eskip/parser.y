@@ -18,11 +18,9 @@
 
 package eskip
 
-import "strconv"
-
 // conversion error ignored, tokenizer expression already checked format
 func convertNumber(s string) float64 {
-	n, _ := strconv.ParseFloat(s, 64)
+	n, _ := parseNumber(s)
 	return n
 }
 
@@ -49,6 +47,10 @@ func convertNumber(s string) float64 {
 	stringvals []string
 	lbAlgorithm string
 	lbEndpoints []string
+	// comment trailing the previous token in the same line
+	comment string
+	// annotations in the comment lines preceding the token
+	annotations map[string]string
 }
 
 %token and
@@ -68,6 +70,9 @@ func convertNumber(s string) float64 {
 %token symbol
 %token openarrow
 %token closearrow
+%token b64literal
+%token durationliteral
+%token sizeliteral
 
 %%
 
@@ -101,6 +106,9 @@ routedef:
 	routeid colon route {
 		$$.route = $3.route
 		$$.route.id = $1.token
+		if $1.annotations != nil {
+			$$.route.annotations = $1.annotations
+		}
 	}
 
 routeid:
@@ -120,12 +128,14 @@ route:
 			lbBackend: $3.lbBackend,
 			lbAlgorithm: $3.lbAlgorithm,
 			lbEndpoints: $3.lbEndpoints,
+			annotations: $1.annotations,
 		}
 		$1.matchers = nil
 		$3.lbEndpoints = nil
 	}
 	|
 	frontend arrow filters arrow backend {
+		$3.filters[len($3.filters)-1].Comment = $4.comment
 		$$.route = &parsedRoute{
 			matchers: $1.matchers,
 			filters: $3.filters,
@@ -136,6 +146,7 @@ route:
 			lbBackend: $5.lbBackend,
 			lbAlgorithm: $5.lbAlgorithm,
 			lbEndpoints: $5.lbEndpoints,
+			annotations: $1.annotations,
 		}
 		$1.matchers = nil
 		$3.filters = nil
@@ -161,6 +172,11 @@ matcher:
 		$$.matcher = &matcher{$1.token, $3.args}
 		$3.args = nil
 	}
+	|
+	symbol openparen matcher closeparen {
+		$$.matcher = &matcher{$1.token, []interface{}{&Predicate{$3.matcher.name, $3.matcher.args}}}
+		$3.matcher = nil
+	}
 
 filters:
 	filter {
@@ -168,6 +184,7 @@ filters:
 	}
 	|
 	filters arrow filter {
+		$1.filters[len($1.filters)-1].Comment = $2.comment
 		$$.filters = $1.filters
 		$$.filters = append($$.filters, $3.filter)
 	}
@@ -203,6 +220,18 @@ arg:
 	regexpval {
 		$$.arg = $1.regexpval
 	}
+	|
+	b64literal {
+		$$.arg = []byte($1.token)
+	}
+	|
+	durationliteral {
+		$$.arg = convertDuration($1.token)
+	}
+	|
+	sizeliteral {
+		$$.arg = convertSize($1.token)
+	}
 
 stringvals:
 	stringval {
@@ -15,6 +15,9 @@
 package eskip
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -208,11 +211,99 @@ func TestNumber(t *testing.T) {
 	}
 }
 
+func TestNegativeNumber(t *testing.T) {
+	r, err := parse(`* -> number(-3.14, -.5) -> <shunt>`)
+	if err != nil {
+		t.Fatal("failed to parse number", err)
+	}
+
+	if d := cmp.Diff([]interface{}{-3.14, -.5}, r[0].filters[0].Args); d != "" {
+		t.Error("failed to parse negative numbers")
+		t.Log(d)
+	}
+}
+
+func TestMinusWithoutNumber(t *testing.T) {
+	_, err := parse(`* -> number(-) -> <shunt>`)
+	if err == nil {
+		t.Error("failed to fail")
+	}
+}
+
+func TestHexAndUnderscoreNumbers(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect float64
+		fail   bool
+	}{{
+		title:  "hex",
+		code:   "0xFF",
+		expect: 255,
+	}, {
+		title:  "upper case hex prefix",
+		code:   "0X1f",
+		expect: 31,
+	}, {
+		title:  "negative hex",
+		code:   "-0x10",
+		expect: -16,
+	}, {
+		title:  "hex with underscores",
+		code:   "0x_FF_FF",
+		expect: 65535,
+	}, {
+		title:  "underscores",
+		code:   "1_000_000",
+		expect: 1000000,
+	}, {
+		title:  "underscores with decimals",
+		code:   "1_000.000_5",
+		expect: 1000.0005,
+	}, {
+		title: "missing hex digits",
+		code:  "0x",
+		fail:  true,
+	}, {
+		title: "trailing underscore",
+		code:  "1_",
+		fail:  true,
+	}, {
+		title: "double underscore",
+		code:  "1__0",
+		fail:  true,
+	}, {
+		title: "hex fraction",
+		code:  "0xF.F",
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := parse(fmt.Sprintf("* -> number(%s) -> <shunt>", test.code))
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff([]interface{}{test.expect}, r[0].filters[0].Args); d != "" {
+				t.Error("failed to parse number")
+				t.Log(d)
+			}
+		})
+	}
+}
+
 func TestRegExp(t *testing.T) {
 	testRegExpOnce(t, `PathRegexp(/[/]/)-> <shunt>`, `[/]`)
 	testRegExpOnce(t, `PathRegexp(/[\[]/)-> <shunt>`, `[\[]`)
 	testRegExpOnce(t, `PathRegexp(/[\]]/)-> <shunt>`, `[\]]`)
-	testRegExpOnce(t, `PathRegexp(/[\\]/)-> <shunt>`, `[\]`)
+	testRegExpOnce(t, `PathRegexp(/[\\]/)-> <shunt>`, `[\\]`)
 	testRegExpOnce(t, `PathRegexp(/[\/]/)-> <shunt>`, `[/]`)
 	testRegExpOnce(t, `PathRegexp(/["]/)-> <shunt>`, `["]`)
 	testRegExpOnce(t, `PathRegexp(/[\"]/)-> <shunt>`, `[\"]`)
@@ -220,6 +311,82 @@ func TestRegExp(t *testing.T) {
 	testRegExpOnce(t, `PathRegexp(/[[:upper:]]/)-> <shunt>`, `[[:upper:]]`)
 }
 
+func TestRegExpEscapedDelimiters(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect string
+		fail   bool
+	}{{
+		title:  "escaped delimiter",
+		code:   `/\/\w+Id$/`,
+		expect: `/\w+Id$`,
+	}, {
+		title:  "escaped backslash",
+		code:   `/\\/`,
+		expect: `\\`,
+	}, {
+		title:  "escaped backslash followed by escaped delimiter",
+		code:   `/a\\\/b/`,
+		expect: `a\\/b`,
+	}, {
+		title:  "delimiter in a class",
+		code:   `/^[/]+$/`,
+		expect: `^[/]+$`,
+	}, {
+		title:  "escaped and unescaped delimiters",
+		code:   `/[/]\/[\/]/`,
+		expect: `[/]/[/]`,
+	}, {
+		title:  "literal closing bracket first in a class",
+		code:   `/[]/]/`,
+		expect: `[]/]`,
+	}, {
+		title:  "literal closing bracket first in a negated class",
+		code:   `/[^]/]/`,
+		expect: `[^]/]`,
+	}, {
+		title:  "named class followed by a delimiter in a class",
+		code:   `/[[:alpha:]/]/`,
+		expect: `[[:alpha:]/]`,
+	}, {
+		title: "lone backslash at the end",
+		code:  `/foo\`,
+		fail:  true,
+	}, {
+		title: "escaped delimiter at the end",
+		code:  `/foo\/`,
+		fail:  true,
+	}, {
+		title: "unclosed class",
+		code:  `/[/`,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := parse(fmt.Sprintf("PathRegexp(%s) -> <shunt>", test.code))
+			if test.fail {
+				if err == nil {
+					t.Error("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if r[0].matchers[0].args[0] != test.expect {
+				t.Errorf("invalid regexp, got: %s, expected: %s", r[0].matchers[0].args[0], test.expect)
+			}
+
+			if _, err := regexp.Compile(test.expect); err != nil {
+				t.Errorf("invalid regexp syntax: %v", err)
+			}
+		})
+	}
+}
+
 func testRegExpOnce(t *testing.T, regexpStr string, expectedRegExp string) {
 	routes, err := parse(regexpStr)
 	if err != nil {
@@ -332,3 +499,733 @@ func TestLBBackend(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterComments(t *testing.T) {
+	for _, test := range []struct {
+		title    string
+		code     string
+		comments []string
+	}{{
+		title:    "no comments",
+		code:     `* -> foo() -> bar() -> <shunt>`,
+		comments: []string{"", ""},
+	}, {
+		title: "comment after the last filter",
+		code: `* -> foo() -> status(418) // added for incident-1234
+		       -> <shunt>`,
+		comments: []string{"", "added for incident-1234"},
+	}, {
+		title: "comment after each filter",
+		code: `* -> foo() // foo comment
+		       -> bar() //bar comment
+		       -> <shunt>`,
+		comments: []string{"foo comment", "bar comment"},
+	}, {
+		title: "comment in its own line is not attached",
+		code: `* -> foo()
+		       // about bar
+		       -> bar()
+		       -> <shunt>`,
+		comments: []string{"", ""},
+	}, {
+		title: "comment after the predicates is not attached",
+		code: `* // predicates
+		       -> foo() -> bar() -> <shunt>`,
+		comments: []string{"", ""},
+	}, {
+		title: "comment in route definitions",
+		code: `r1: * -> foo() // r1 comment
+		       -> <shunt>;
+		       r2: * -> bar() // r2 comment
+		       -> <shunt>`,
+		comments: []string{"r1 comment", "r2 comment"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var comments []string
+			for _, ri := range r {
+				for _, f := range ri.Filters {
+					comments = append(comments, f.Comment)
+				}
+			}
+
+			if d := cmp.Diff(test.comments, comments); d != "" {
+				t.Error("failed to parse filter comments")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestCommentsWithinRoutes(t *testing.T) {
+	const expect = `Path("/foo") && Method("GET") && Header("X-Foo", "bar") -> setPath("/") -> status(200) -> <shunt>`
+	for _, test := range []struct {
+		title string
+		code  string
+	}{{
+		title: "between predicates",
+		code: `r1: Path("/foo")
+		       // only reads
+		       && Method("GET")
+		       // with the header
+		       // set
+		       && Header("X-Foo", "bar")
+		       -> setPath("/") -> status(200) -> <shunt>`,
+	}, {
+		title: "at the end of the predicate lines",
+		code: `r1: Path("/foo") && // only reads
+		       Method("GET") // with the header
+		       && Header("X-Foo", "bar") -> setPath("/") -> status(200) -> <shunt>`,
+	}, {
+		title: "between filters",
+		code: `r1: Path("/foo") && Method("GET") && Header("X-Foo", "bar")
+		       // strip the path
+		       -> setPath("/")
+		       // respond
+		       -> status(200)
+		       // no backend
+		       -> <shunt>`,
+	}, {
+		title: "annotation-like comments within the route are ignored",
+		code: `r1: Path("/foo")
+		       // @foo=bar
+		       && Method("GET") && Header("X-Foo", "bar")
+		       // @baz
+		       -> setPath("/") -> status(200) -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r) != 1 {
+				t.Fatalf("invalid number of routes: %d", len(r))
+			}
+
+			if s := r[0].String(); s != expect {
+				t.Errorf("invalid route, got: %s, expected: %s", s, expect)
+			}
+
+			if r[0].Annotations != nil {
+				t.Errorf("unexpected annotations: %v", r[0].Annotations)
+			}
+
+			for _, f := range r[0].Filters {
+				if f.Comment != "" {
+					t.Errorf("unexpected filter comment: %s", f.Comment)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownBackendType(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		code  string
+		err   string
+	}{{
+		title: "typo in shunt",
+		code:  `r1: * -> <shnt>`,
+		err:   "parse failed after token ->, last route id: r1, position 9: unknown backend type 'shnt', expected one of <shunt>, <loopback>, <dynamic> or <algorithm, \"endpoint\", ...>",
+	}, {
+		title: "typo with filters",
+		code:  `r1: * -> status(404) -> < loopbak >`,
+		err:   "parse failed after token ->, last route id: r1, position 24: unknown backend type 'loopbak', expected one of <shunt>, <loopback>, <dynamic> or <algorithm, \"endpoint\", ...>",
+	}, {
+		title: "algorithm only",
+		code:  `r1: * -> <roundRobin>`,
+		err:   "parse failed after token ->, last route id: r1, position 9: unknown backend type 'roundRobin', expected one of <shunt>, <loopback>, <dynamic> or <algorithm, \"endpoint\", ...>",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := Parse(test.code)
+			if err == nil {
+				t.Fatal("failed to fail")
+			}
+
+			if err.Error() != test.err {
+				t.Errorf("unexpected error, got: %q, expected: %q", err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestOtherLexerErrorsKeepSyntaxError(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		code  string
+		err   string
+	}{{
+		title: "unterminated string",
+		code:  `r1: * -> "foo`,
+		err:   "parse failed after token ->, last route id: r1, position 13: syntax error",
+	}, {
+		title: "unexpected character",
+		code:  `r1: * -> f($) -> <shunt>`,
+		err:   "parse failed after token (, last route id: r1, position 11: syntax error",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := Parse(test.code)
+			if err == nil {
+				t.Fatal("failed to fail")
+			}
+
+			if err.Error() != test.err {
+				t.Errorf("unexpected error, got: %q, expected: %q", err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestBackendNotLast(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		code  string
+		err   string
+	}{{
+		title: "network backend",
+		code:  `r1: * -> "https://www.example.org" -> setRequestHeader("X-Foo", "bar")`,
+		err:   "parse failed after token ->, last route id: r1, position 37: backend must be the last element of the route",
+	}, {
+		title: "shunt",
+		code:  `r1: * -> setPath("/") -> <shunt> -> status(418)`,
+		err:   "parse failed after token ->, last route id: r1, position 35: backend must be the last element of the route",
+	}, {
+		title: "load balanced backend in a route expression",
+		code:  `* -> <roundRobin, "https://a.example.org", "https://b.example.org"> -> status(418)`,
+		err:   "parse failed after token ->, last route id: , position 70: backend must be the last element of the route",
+	}, {
+		title: "second route",
+		code:  `r1: * -> <shunt>; r2: * -> <dynamic> -> setDynamicBackendUrl("https://www.example.org")`,
+		err:   "parse failed after token ->, last route id: r2, position 39: backend must be the last element of the route",
+	}, {
+		title: "other syntax errors",
+		code:  `r1: * -> status(418) -> -> <shunt>`,
+		err:   "parse failed after token ->, last route id: r1, position 26: syntax error",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := Parse(test.code)
+			if err == nil {
+				t.Fatal("failed to fail")
+			}
+
+			if err.Error() != test.err {
+				t.Errorf("unexpected error, got: %q, expected: %q", err.Error(), test.err)
+			}
+		})
+	}
+}
+
+func TestKnownBackendTypes(t *testing.T) {
+	for _, code := range []string{
+		`* -> <shunt>`,
+		`* -> <loopback>`,
+		`* -> <dynamic>`,
+		`* -> <roundRobin, "http://foo.example.org">`,
+		`* -> <"http://foo.example.org">`,
+	} {
+		if _, err := Parse(code); err != nil {
+			t.Errorf("failed to parse %s: %v", code, err)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []map[string]string
+	}{{
+		title:  "no annotations",
+		code:   `r1: * -> <shunt>; r2: * -> <shunt>`,
+		expect: []map[string]string{nil, nil},
+	}, {
+		title: "route definitions",
+		code: `
+			// @team=gateway
+			// @ cost-center = 1234
+			r1: * -> <shunt>;
+
+			// forwards the beta requests
+			// @experimental
+			r2: Path("/beta") -> "https://beta.example.org";
+			r3: * -> <shunt>
+		`,
+		expect: []map[string]string{
+			{"team": "gateway", "cost-center": "1234"},
+			{"experimental": ""},
+			nil,
+		},
+	}, {
+		title: "route expression",
+		code: `// @team=gateway
+		       Path("/foo") -> <shunt>`,
+		expect: []map[string]string{{"team": "gateway"}},
+	}, {
+		title: "annotation after the route id",
+		code: `r1:
+		       // @team=gateway
+		       * -> <shunt>`,
+		expect: []map[string]string{{"team": "gateway"}},
+	}, {
+		title: "comment in the same line is not an annotation",
+		code: `r1: * -> <shunt>; // @team=gateway
+		       r2: * -> <shunt>`,
+		expect: []map[string]string{nil, nil},
+	}, {
+		title:  "empty key ignored",
+		code:   "// @=foo\nr1: * -> <shunt>",
+		expect: []map[string]string{nil},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var annotations []map[string]string
+			for _, ri := range r {
+				annotations = append(annotations, ri.Annotations)
+			}
+
+			if d := cmp.Diff(test.expect, annotations); d != "" {
+				t.Error("failed to parse annotations")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestUnicodeEscapes(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect string
+	}{{
+		title:  "basic multilingual plane",
+		code:   `"caf\u00e9"`,
+		expect: "caf\u00e9",
+	}, {
+		title:  "upper case hex digits",
+		code:   `"\u00C9t\u00E9"`,
+		expect: "\u00c9t\u00e9",
+	}, {
+		title:  "long form",
+		code:   `"\U0001F600"`,
+		expect: "\U0001F600",
+	}, {
+		title:  "surrogate pair",
+		code:   `"\ud83d\ude00"`,
+		expect: "\U0001F600",
+	}, {
+		title:  "mixed with other escapes",
+		code:   `"\"\u00e9\"\t\\\n"`,
+		expect: "\"\u00e9\"\t\\\n",
+	}, {
+		title:  "backtick",
+		code:   "`\\u00e9`",
+		expect: "\u00e9",
+	}, {
+		title:  "too short, kept",
+		code:   `"\u00e"`,
+		expect: `\u00e`,
+	}, {
+		title:  "invalid hex, kept",
+		code:   `"\u00zz"`,
+		expect: `\u00zz`,
+	}, {
+		title:  "lone surrogate, kept",
+		code:   `"\ud83d"`,
+		expect: `\ud83d`,
+	}, {
+		title:  "out of range, kept",
+		code:   `"\U00110000"`,
+		expect: `\U00110000`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(fmt.Sprintf(`* -> inlineContent(%s) -> <shunt>`, test.code))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if arg := r[0].Filters[0].Args[0]; arg != test.expect {
+				t.Errorf("invalid arg, got: %q, expected: %q", arg, test.expect)
+			}
+		})
+	}
+}
+
+func TestBase64Literal(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []interface{}
+		fail   bool
+	}{{
+		title:  "filter arg",
+		code:   `* -> inlineContent(b64"SGVsbG8=") -> <shunt>`,
+		expect: []interface{}{[]byte("Hello")},
+	}, {
+		title:  "empty",
+		code:   `* -> inlineContent(b64"") -> <shunt>`,
+		expect: []interface{}{[]byte{}},
+	}, {
+		title:  "mixed with other args",
+		code:   `* -> custom("foo", b64"AP8=", 42) -> <shunt>`,
+		expect: []interface{}{"foo", []byte{0, 255}, float64(42)},
+	}, {
+		title: "invalid base64",
+		code:  `* -> custom(b64"SGVsbG8") -> <shunt>`,
+		fail:  true,
+	}, {
+		title: "unterminated",
+		code:  `* -> custom(b64"SGVsbG8=) -> <shunt>`,
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if test.fail {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, r[0].Filters[0].Args); d != "" {
+				t.Error("invalid args")
+				t.Log(d)
+			}
+
+			rr, err := Parse(r[0].String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !Eq(r[0], rr[0]) {
+				t.Errorf("failed to round-trip the route: %s", r[0].String())
+			}
+		})
+	}
+}
+
+func TestBase64LiteralPredicateArg(t *testing.T) {
+	r, err := Parse(`Custom(b64"SGVsbG8=") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := r[0].String(); s != `Custom(b64"SGVsbG8=") -> <shunt>` {
+		t.Errorf("invalid route string: %s", s)
+	}
+
+	c := r[0].Copy()
+	c.Predicates[0].Args[0].([]byte)[0] = 'J'
+	if r[0].Predicates[0].Args[0].([]byte)[0] != 'H' {
+		t.Error("failed to copy the binary arg")
+	}
+
+	if Eq(r[0], c) {
+		t.Error("failed to compare the binary args")
+	}
+}
+
+// the route mixes of the parse benchmarks, each generating the route
+// definition with the given index
+var parseBenchmarkMixes = []struct {
+	name  string
+	route func(i int) string
+}{{
+	name: "predicates",
+	route: func(i int) string {
+		return fmt.Sprintf(
+			`route%d: Host("^api%d[.]example[.]org$") && Path("/api/:version/%d") && Method("POST") `+
+				`&& Header("X-Tenant", "tenant-%d") && Header("Content-Type", "application/json") `+
+				`&& Cookie("session", "abc") && Weight(%d) -> "https://backend-%d.example.org";`,
+			i, i, i, i, i%10, i%20,
+		)
+	},
+}, {
+	name: "filters",
+	route: func(i int) string {
+		return fmt.Sprintf(
+			`route%d: Path("/api/%d") -> setRequestHeader("X-Forwarded-Service", "api-%d") `+
+				`-> setResponseHeader("Cache-Control", "no-cache") -> ratelimit(20, "1m") `+
+				`-> modPath("^/api/(.*)", "/v2/$1") -> compress("text/html", "application/json") `+
+				`-> inlineContent("{\"ok\": true}", "application/json") -> status(%d) -> <shunt>;`,
+			i, i, i, 200+i%3,
+		)
+	},
+}, {
+	name: "regexps",
+	route: func(i int) string {
+		return fmt.Sprintf(
+			`route%d: PathRegexp(/^\/api\/v[0-9]+\/items%d\/[a-z0-9-]+$/) && Host(/^(www|api)[.]example%d[.]org$/) `+
+				`&& HeaderRegexp("User-Agent", /^Mozilla\/[0-9.]+ .*%d/) -> modPath(/^\/api\/v[0-9]+/, "/") `+
+				`-> <roundRobin, "https://a.example.org", "https://b.example.org">;`,
+			i, i, i, i,
+		)
+	},
+}}
+
+func parseBenchmarkDocument(n int, route func(int) string) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteString(route(i))
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, mix := range parseBenchmarkMixes {
+		for _, n := range []int{100, 1000, 10000} {
+			doc := parseBenchmarkDocument(n, mix.route)
+			if _, err := Parse(doc); err != nil {
+				b.Fatal(err)
+			}
+
+			b.Run(fmt.Sprintf("%s/%d", mix.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(doc)))
+				for i := 0; i < b.N; i++ {
+					if _, err := Parse(doc); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStrictEscapes(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		strict bool
+		expect string
+		err    string
+	}{{
+		title:  "lenient, unknown escape kept",
+		code:   `"foo\qbar"`,
+		expect: `foo\qbar`,
+	}, {
+		title:  "lenient, valid escapes",
+		code:   `"foo\n\"bar\""`,
+		expect: "foo\n\"bar\"",
+	}, {
+		title:  "strict, valid escapes",
+		code:   `"foo\n\"bar\"\\é"`,
+		strict: true,
+		expect: "foo\n\"bar\"\\é",
+	}, {
+		title:  "strict, unknown escape",
+		code:   `"foo\n\qbar"`,
+		strict: true,
+		err:    "position 19: unknown escape sequence: \\q",
+	}, {
+		title:  "strict, backtick",
+		code:   "`foo\\q`",
+		strict: true,
+		err:    "position 19: unknown escape sequence: \\q",
+	}, {
+		title:  "strict, invalid unicode escape",
+		code:   `"\u00zz"`,
+		strict: true,
+		err:    "position 19: unknown escape sequence: \\u",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := ParseWithOptions(
+				fmt.Sprintf(`* -> inlineContent(%s) -> <shunt>`, test.code),
+				ParseOptions{StrictEscapes: test.strict},
+			)
+
+			if test.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+					t.Fatalf("failed to fail with the right error, got: %v, expected: %s", err, test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if arg := r[0].Filters[0].Args[0]; arg != test.expect {
+				t.Errorf("invalid arg, got: %q, expected: %q", arg, test.expect)
+			}
+		})
+	}
+}
+
+func TestSuggestShunt(t *testing.T) {
+	const hint = "missing backend after the last filter, use -> <shunt> when the route doesn't forward the requests"
+	for _, test := range []struct {
+		title   string
+		code    string
+		suggest bool
+		err     string
+	}{{
+		title: "default, generic error",
+		code:  `* -> status(404)`,
+		err:   "position 16: syntax error",
+	}, {
+		title:   "single filter without backend",
+		code:    `* -> status(404)`,
+		suggest: true,
+		err:     "position 16: " + hint,
+	}, {
+		title:   "multiple filters and a comment",
+		code:    `Path("/foo") -> setPath("/") -> status(404) // gone`,
+		suggest: true,
+		err:     "position 51: " + hint,
+	}, {
+		title:   "route in a document",
+		code:    `r1: * -> status(404); r2: * -> <shunt>`,
+		suggest: true,
+		err:     "last route id: r1, position 21: " + hint,
+	}, {
+		title:   "last route in a document",
+		code:    `r1: * -> <shunt>; r2: * -> status(404);`,
+		suggest: true,
+		err:     "last route id: r2, position 39: " + hint,
+	}, {
+		title:   "predicates only, generic error",
+		code:    `Path("/foo")`,
+		suggest: true,
+		err:     "position 12: syntax error",
+	}, {
+		title:   "unfinished filter, generic error",
+		code:    `* -> status(404`,
+		suggest: true,
+		err:     "position 15: syntax error",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := ParseWithOptions(test.code, ParseOptions{SuggestShunt: test.suggest})
+			if err == nil || !strings.HasSuffix(err.Error(), test.err) {
+				t.Fatalf("failed to fail with the right error, got: %v, expected: %s", err, test.err)
+			}
+		})
+	}
+
+	r, err := ParseWithOptions(`* -> status(404) -> <shunt>`, ParseOptions{SuggestShunt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].BackendType != ShuntBackend {
+		t.Error("failed to parse the shunt route")
+	}
+}
+
+func TestArgListWhitespace(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect string
+	}{{
+		title:  "no whitespace",
+		code:   `Foo("a","b") -> bar(1,"2") -> <shunt>`,
+		expect: `Foo("a", "b") -> bar(1, "2") -> <shunt>`,
+	}, {
+		title:  "whitespace before the comma",
+		code:   `Foo("a" , "b") -> bar(1 ,"2") -> <shunt>`,
+		expect: `Foo("a", "b") -> bar(1, "2") -> <shunt>`,
+	}, {
+		title:  "whitespace around the parens",
+		code:   `Foo( "a", "b" ) -> bar ( 1 ) -> baz( ) -> <shunt>`,
+		expect: `Foo("a", "b") -> bar(1) -> baz() -> <shunt>`,
+	}, {
+		title:  "tabs, newlines and comments",
+		code:   "Foo(\n\t\"a\", // first\n\t\"b\"\n) -> bar(\t-1,\t/c/,\tb64\"SGVsbG8=\"\t) -> <shunt>",
+		expect: `Foo("a", "b") -> bar(-1, "c", b64"SGVsbG8=") -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := r[0].String()
+			if s != test.expect {
+				t.Errorf("invalid route string, got: %s, expected: %s", s, test.expect)
+			}
+
+			rr, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rs := rr[0].String(); rs != s {
+				t.Errorf("unstable route string, got: %s, expected: %s", rs, s)
+			}
+		})
+	}
+}
+
+func TestFinalSemicolon(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		code   string
+		expect []string
+	}{{
+		title:  "single route without semicolon",
+		code:   `r1: * -> "https://a.example.org"`,
+		expect: []string{"r1"},
+	}, {
+		title:  "single route with semicolon",
+		code:   `r1: * -> "https://a.example.org";`,
+		expect: []string{"r1"},
+	}, {
+		title:  "without final semicolon",
+		code:   `r1: * -> "https://a.example.org"; r2: * -> "https://b.example.org"`,
+		expect: []string{"r1", "r2"},
+	}, {
+		title:  "with final semicolon",
+		code:   `r1: * -> "https://a.example.org"; r2: * -> "https://b.example.org";`,
+		expect: []string{"r1", "r2"},
+	}, {
+		title: "final semicolon followed by whitespace and a comment",
+		code: `r1: * -> "https://a.example.org";
+			r2: * -> "https://b.example.org";
+			// the end
+		`,
+		expect: []string{"r1", "r2"},
+	}, {
+		title: "no final semicolon, followed by a comment",
+		code: `r1: * -> "https://a.example.org";
+			r2: * -> "https://b.example.org" // the end
+		`,
+		expect: []string{"r1", "r2"},
+	}, {
+		title:  "repeated semicolons",
+		code:   `r1: * -> "https://a.example.org";; r2: * -> "https://b.example.org";;`,
+		expect: []string{"r1", "r2"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, routeIDs(r)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+
+	if _, err := Parse(`r1: * -> "https://a.example.org" r2: * -> "https://b.example.org"`); err == nil {
+		t.Error("failed to fail on the missing semicolon between the routes")
+	}
+}
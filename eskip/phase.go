@@ -0,0 +1,70 @@
+package eskip
+
+// FilterPhase tells whether a filter acts on the request, on the response,
+// or on both.
+type FilterPhase int
+
+const (
+	// PhaseBoth is used for the filters that act both on the request
+	// and the response, and it is the default for the unknown filters.
+	PhaseBoth FilterPhase = iota
+
+	// PhaseRequest is used for the filters that act only on the request.
+	PhaseRequest
+
+	// PhaseResponse is used for the filters that act only on the response.
+	PhaseResponse
+)
+
+// PhaseSpec maps filter names to the phase that they act in. The filters
+// missing from the spec are considered to act in both phases.
+type PhaseSpec map[string]FilterPhase
+
+// DefaultPhaseSpec contains the phases of the common built-in filters.
+var DefaultPhaseSpec = PhaseSpec{
+	"setRequestHeader":     PhaseRequest,
+	"appendRequestHeader":  PhaseRequest,
+	"dropRequestHeader":    PhaseRequest,
+	"requestCookie":        PhaseRequest,
+	"modPath":              PhaseRequest,
+	"setPath":              PhaseRequest,
+	"setQuery":             PhaseRequest,
+	"dropQuery":            PhaseRequest,
+	"preserveHost":         PhaseRequest,
+	"setResponseHeader":    PhaseResponse,
+	"appendResponseHeader": PhaseResponse,
+	"dropResponseHeader":   PhaseResponse,
+	"responseCookie":       PhaseResponse,
+}
+
+// String returns the name of the phase.
+func (p FilterPhase) String() string {
+	switch p {
+	case PhaseRequest:
+		return "request"
+	case PhaseResponse:
+		return "response"
+	default:
+		return "both"
+	}
+}
+
+// ClassifyFilters splits the filters of a route into the filters acting on
+// the request and the filters acting on the response, based on the spec.
+// The filters acting in both phases are contained by both lists. The order
+// of the filters is preserved in both lists.
+func ClassifyFilters(r *Route, spec PhaseSpec) (request, response []*Filter) {
+	for _, f := range r.Filters {
+		switch spec[f.Name] {
+		case PhaseRequest:
+			request = append(request, f)
+		case PhaseResponse:
+			response = append(response, f)
+		default:
+			request = append(request, f)
+			response = append(response, f)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,71 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func filterNames(f []*Filter) []string {
+	var n []string
+	for _, fi := range f {
+		n = append(n, fi.Name)
+	}
+
+	return n
+}
+
+func TestClassifyFilters(t *testing.T) {
+	r, err := Parse(`* ->
+		setRequestHeader("X-Foo", "bar") ->
+		compress() ->
+		setResponseHeader("X-Bar", "baz") ->
+		setPath("/") ->
+		customFilter() ->
+		"https://www.example.org"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("default spec", func(t *testing.T) {
+		request, response := ClassifyFilters(r[0], DefaultPhaseSpec)
+		if d := cmp.Diff(
+			[]string{"setRequestHeader", "compress", "setPath", "customFilter"},
+			filterNames(request),
+		); d != "" {
+			t.Error("invalid request filters")
+			t.Log(d)
+		}
+
+		if d := cmp.Diff(
+			[]string{"compress", "setResponseHeader", "customFilter"},
+			filterNames(response),
+		); d != "" {
+			t.Error("invalid response filters")
+			t.Log(d)
+		}
+	})
+
+	t.Run("custom spec", func(t *testing.T) {
+		request, response := ClassifyFilters(r[0], PhaseSpec{
+			"compress":     PhaseResponse,
+			"customFilter": PhaseRequest,
+		})
+
+		if d := cmp.Diff(
+			[]string{"setRequestHeader", "setResponseHeader", "setPath", "customFilter"},
+			filterNames(request),
+		); d != "" {
+			t.Error("invalid request filters")
+			t.Log(d)
+		}
+
+		if d := cmp.Diff(
+			[]string{"setRequestHeader", "compress", "setResponseHeader", "setPath"},
+			filterNames(response),
+		); d != "" {
+			t.Error("invalid response filters")
+			t.Log(d)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package eskip
+
+import "sort"
+
+// QueryParamPredicates returns the QueryParam predicates, that are stored in
+// the QueryParams and QueryParamRegexps fields of the route, in a stable
+// order: sorted by the query param name, and for the same name, the
+// QueryParam predicates checking only the existence of the param first,
+// followed by the ones with a regular expression, sorted by the regular
+// expression. The predicates in the Predicates field of the route are not
+// included.
+func QueryParamPredicates(r *Route) []*Predicate {
+	all := make(map[string][]string)
+	for _, k := range r.QueryParams {
+		all[k] = nil
+	}
+
+	for k := range r.QueryParamRegexps {
+		all[k] = nil
+	}
+
+	existence := make(map[string]int)
+	for _, k := range r.QueryParams {
+		existence[k]++
+	}
+
+	var p []*Predicate
+	for _, k := range sortedKeys(all) {
+		for i := 0; i < existence[k]; i++ {
+			p = append(p, &Predicate{Name: "QueryParam", Args: []interface{}{k}})
+		}
+
+		values := copyStrings(r.QueryParamRegexps[k])
+		sort.Strings(values)
+		for _, v := range values {
+			p = append(p, &Predicate{Name: "QueryParam", Args: []interface{}{k, v}})
+		}
+	}
+
+	return p
+}
+
+func applyQueryParam(r *Route, args []interface{}, o ParseOptions) error {
+	if len(args) != 1 && len(args) != 2 {
+		return invalidQueryParamArgsError
+	}
+
+	sargs, err := getStringArgs(len(args), args)
+	if err != nil {
+		return invalidQueryParamArgsError
+	}
+
+	if !o.QueryParamFields {
+		r.Predicates = append(r.Predicates, &Predicate{Name: "QueryParam", Args: args})
+		return nil
+	}
+
+	if len(sargs) == 1 {
+		r.QueryParams = append(r.QueryParams, sargs[0])
+		return nil
+	}
+
+	if r.QueryParamRegexps == nil {
+		r.QueryParamRegexps = make(map[string][]string)
+	}
+
+	r.QueryParamRegexps[sargs[0]] = append(r.QueryParamRegexps[sargs[0]], sargs[1])
+	return nil
+}
@@ -0,0 +1,83 @@
+package eskip
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueryParamFields(t *testing.T) {
+	const code = `QueryParam("debug") && Path("/foo") && QueryParam("page", "^1") && QueryParam("page", /^[0-9]+$/) -> <shunt>`
+
+	r, err := Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].QueryParams != nil || r[0].QueryParamRegexps != nil || len(r[0].Predicates) != 3 {
+		t.Error("failed to keep the query params in the predicates by default")
+	}
+
+	rf, err := ParseWithOptions(code, ParseOptions{QueryParamFields: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff([]string{"debug"}, rf[0].QueryParams); d != "" {
+		t.Error(d)
+	}
+
+	if d := cmp.Diff(map[string][]string{"page": {"^1", "^[0-9]+$"}}, rf[0].QueryParamRegexps); d != "" {
+		t.Error(d)
+	}
+
+	if len(rf[0].Predicates) != 0 {
+		t.Errorf("unexpected predicates: %v", rf[0].Predicates)
+	}
+
+	const expect = `Path("/foo") && QueryParam("debug") && QueryParam("page", "^1") && QueryParam("page", "^[0-9]+$") -> <shunt>`
+	if s := rf[0].String(); s != expect {
+		t.Errorf("invalid route string, got: %s, expected: %s", s, expect)
+	}
+
+	if !Eq(r[0], rf[0]) {
+		t.Error("the two representations are not equal")
+	}
+
+	b, err := json.Marshal(rf[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rj Route
+	if err := json.Unmarshal(b, &rj); err != nil {
+		t.Fatal(err)
+	}
+
+	if !Eq(r[0], &rj) {
+		t.Errorf("failed to round-trip the query params in JSON: %s", b)
+	}
+
+	c := rf[0].Copy()
+	c.QueryParams[0] = "trace"
+	c.QueryParamRegexps["page"][0] = "^2"
+	if rf[0].QueryParams[0] != "debug" || rf[0].QueryParamRegexps["page"][0] != "^1" {
+		t.Error("failed to copy the query params")
+	}
+}
+
+func TestQueryParamArgs(t *testing.T) {
+	for _, code := range []string{
+		`QueryParam() -> <shunt>`,
+		`QueryParam("page", "^1", "^2") -> <shunt>`,
+		`QueryParam(42) -> <shunt>`,
+		`QueryParam("page", 1) -> <shunt>`,
+	} {
+		for _, o := range []ParseOptions{{}, {QueryParamFields: true}} {
+			if _, err := ParseWithOptions(code, o); err != invalidQueryParamArgsError {
+				t.Errorf("failed to fail with the right error: %s, %v", code, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package eskip
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var invalidRateLimitError = errors.New("expected the number of requests per a time unit or a duration, e.g. 100/s or 600/10m")
+
+// parses a rate limit spec of the form <requests>/<unit or duration>, where
+// the unit is s, m or h
+func parseRateLimit(spec string) (int, time.Duration, error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 2 {
+		return 0, 0, invalidRateLimitError
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 {
+		return 0, 0, invalidRateLimitError
+	}
+
+	window := strings.TrimSpace(parts[1])
+	switch window {
+	case "s", "m", "h":
+		window = "1" + window
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, 0, invalidRateLimitError
+	}
+
+	return n, d, nil
+}
+
+// ApplyRateLimitFilters appends a rate limit filter with the provided name
+// to the routes with a RateLimit, parsed from the @ratelimit annotation. The
+// args of the filter are the number of requests, and the time window as a
+// duration string, e.g. the annotation @ratelimit=100/s becomes
+// clientRatelimit(100, "1s"). The filters with a name starting with
+// cluster, e.g. clusterClientRatelimit, get the route ID as the group name
+// in their first arg, e.g. clusterClientRatelimit("route1", 100, "1s").
+//
+// The routes that already have a filter with the same name are skipped.
+// The routes are modified in place. When the rate limit of any route is
+// invalid, it returns an error with the ID of the route, and it doesn't
+// modify the routes.
+func ApplyRateLimitFilters(routes []*Route, filterName string) error {
+	filters := make(map[*Route]*Filter)
+	for _, r := range routes {
+		if r.RateLimit == "" {
+			continue
+		}
+
+		n, d, err := parseRateLimit(r.RateLimit)
+		if err != nil {
+			return fmt.Errorf(invalidAnnotationErrorFmt+", %w", RateLimitAnnotation, r.Id, r.RateLimit, err)
+		}
+
+		args := []interface{}{float64(n), d.String()}
+		if strings.HasPrefix(filterName, "cluster") {
+			args = append([]interface{}{r.Id}, args...)
+		}
+
+		filters[r] = &Filter{Name: filterName, Args: args}
+	}
+
+	for _, r := range routes {
+		f, ok := filters[r]
+		if !ok || len(missingFilters(r, []*Filter{f})) == 0 {
+			continue
+		}
+
+		rf := make([]*Filter, len(r.Filters), len(r.Filters)+1)
+		copy(rf, r.Filters)
+		r.Filters = append(rf, f)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package eskip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRateLimitAnnotation(t *testing.T) {
+	const doc = `
+		// @ratelimit=100/s
+		// @team=search
+		r1: Path("/search") -> setPath("/") -> "https://search.example.org";
+
+		// @ratelimit=600/10m
+		r2: Path("/export") -> "https://export.example.org";
+
+		// @ratelimit=5 / h
+		r3: Path("/report") -> clientRatelimit(1, "1s") -> "https://report.example.org";
+
+		r4: * -> "https://www.example.org";
+	`
+
+	r, err := Parse(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].RateLimit != "100/s" || r[1].RateLimit != "600/10m" || r[3].RateLimit != "" {
+		t.Errorf("failed to parse the rate limits: %s, %s, %s", r[0].RateLimit, r[1].RateLimit, r[3].RateLimit)
+	}
+
+	if len(r[0].Annotations) != 1 || r[1].Annotations != nil {
+		t.Errorf("invalid annotations: %v, %v", r[0].Annotations, r[1].Annotations)
+	}
+
+	rr, err := Parse(String(r...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr[0].RateLimit != "100/s" || rr[2].RateLimit != "5 / h" || Copy(r[1]).RateLimit != "600/10m" {
+		t.Error("failed to round-trip the rate limits")
+	}
+
+	for _, test := range []struct {
+		title      string
+		filterName string
+		expect     []string
+	}{{
+		title:      "client rate limit",
+		filterName: "clientRatelimit",
+		expect: []string{
+			`Path("/search") -> setPath("/") -> clientRatelimit(100, "1s") -> "https://search.example.org"`,
+			`Path("/export") -> clientRatelimit(600, "10m0s") -> "https://export.example.org"`,
+			`Path("/report") -> clientRatelimit(1, "1s") -> "https://report.example.org"`,
+			`* -> "https://www.example.org"`,
+		},
+	}, {
+		title:      "cluster rate limit",
+		filterName: "clusterClientRatelimit",
+		expect: []string{
+			`Path("/search") -> setPath("/") -> clusterClientRatelimit("r1", 100, "1s") -> "https://search.example.org"`,
+			`Path("/export") -> clusterClientRatelimit("r2", 600, "10m0s") -> "https://export.example.org"`,
+			`Path("/report") -> clientRatelimit(1, "1s") -> clusterClientRatelimit("r3", 5, "1h0m0s") -> "https://report.example.org"`,
+			`* -> "https://www.example.org"`,
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ApplyRateLimitFilters(r, test.filterName); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, ri := range r {
+				if s := ri.String(); s != test.expect[i] {
+					t.Errorf("invalid route, got: %s, expected: %s", s, test.expect[i])
+				}
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, spec := range []string{"100", "100/", "0/s", "-1/s", "x/s", "100/d", "100/0s", "1/2/s"} {
+			r := []*Route{
+				{Id: "valid", RateLimit: "100/s"},
+				{Id: "invalid", RateLimit: spec},
+			}
+
+			err := ApplyRateLimitFilters(r, "clientRatelimit")
+			if !errors.Is(err, invalidRateLimitError) {
+				t.Errorf("failed to fail for %s: %v", spec, err)
+				continue
+			}
+
+			if expect := "invalid ratelimit annotation in route invalid: " + spec; !strings.HasPrefix(err.Error(), expect) {
+				t.Errorf("invalid error: %v", err)
+			}
+
+			if len(r[0].Filters) != 0 {
+				t.Error("the routes were modified")
+			}
+		}
+	})
+}
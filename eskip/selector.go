@@ -0,0 +1,49 @@
+package eskip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnnotationSelector selects routes by their annotations. A route matches
+// the selector when it has every annotation of the selector with the same
+// value. An empty selector matches every route.
+type AnnotationSelector map[string]string
+
+// ParseAnnotationSelector parses a selector from a comma separated list of
+// key=value pairs, e.g. team=payments,env=prod.
+func ParseAnnotationSelector(s string) (AnnotationSelector, error) {
+	sel := make(AnnotationSelector)
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		p := strings.SplitN(kv, "=", 2)
+		key := strings.TrimSpace(p[0])
+		if len(p) != 2 || key == "" {
+			return nil, fmt.Errorf("invalid annotation selector: %s", s)
+		}
+
+		sel[key] = strings.TrimSpace(p[1])
+	}
+
+	return sel, nil
+}
+
+// Matches tells whether the route has the annotations of the selector,
+// including the ones stored in dedicated fields, e.g. @env.
+func (s AnnotationSelector) Matches(r *Route) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	a := routeAnnotations(r)
+	for k, v := range s {
+		if rv, ok := a[k]; !ok || rv != v {
+			return false
+		}
+	}
+
+	return true
+}
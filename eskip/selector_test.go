@@ -0,0 +1,177 @@
+package eskip
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const selectorRoutes = `
+	// @team=payments
+	// @env=prod
+	payments: Source("10.0.0.0/8") -> "https://pay.example.org";
+
+	// @team=search
+	search: Source("10.0.0.0/8") -> "https://search.example.org";
+
+	other: Source("10.0.0.0/8") -> "https://other.example.org";
+`
+
+func routeIDs(r []*Route) []string {
+	var ids []string
+	for _, ri := range r {
+		ids = append(ids, ri.Id)
+	}
+
+	return ids
+}
+
+func TestParseAnnotationSelector(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		input  string
+		expect AnnotationSelector
+		fail   bool
+	}{{
+		title:  "empty",
+		expect: AnnotationSelector{},
+	}, {
+		title:  "single",
+		input:  "team=payments",
+		expect: AnnotationSelector{"team": "payments"},
+	}, {
+		title:  "multiple",
+		input:  "team = payments, env=prod",
+		expect: AnnotationSelector{"team": "payments", "env": "prod"},
+	}, {
+		title:  "empty value",
+		input:  "team=",
+		expect: AnnotationSelector{"team": ""},
+	}, {
+		title: "missing value",
+		input: "team",
+		fail:  true,
+	}, {
+		title: "missing key",
+		input: "=payments",
+		fail:  true,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s, err := ParseAnnotationSelector(test.input)
+			if test.fail {
+				if err == nil {
+					t.Fatal("failed to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, s); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestAnnotationSelectorMatches(t *testing.T) {
+	r, err := Parse(selectorRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		title    string
+		selector AnnotationSelector
+		expect   []string
+	}{{
+		title:  "nil selector",
+		expect: []string{"payments", "search", "other"},
+	}, {
+		title:    "single annotation",
+		selector: AnnotationSelector{"team": "payments"},
+		expect:   []string{"payments"},
+	}, {
+		title:    "all annotations need to match",
+		selector: AnnotationSelector{"team": "payments", "env": "staging"},
+	}, {
+		title:    "empty value does not match missing annotation",
+		selector: AnnotationSelector{"env": ""},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			var matching []*Route
+			for _, ri := range r {
+				if test.selector.Matches(ri) {
+					matching = append(matching, ri)
+				}
+			}
+
+			if d := cmp.Diff(test.expect, routeIDs(matching)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestSelectorPreProcessors(t *testing.T) {
+	r, err := Parse(selectorRoutes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := AnnotationSelector{"team": "payments"}
+
+	t.Run("editor", func(t *testing.T) {
+		e := NewEditor(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)")
+		e.Match = match
+		result := e.Do(CopyRoutes(r))
+		if result[0].Predicates[0].Name != "ClientIP" {
+			t.Error("failed to edit the matching route")
+		}
+
+		for _, ri := range result[1:] {
+			if ri.Predicates[0].Name != "Source" {
+				t.Errorf("unexpected edit of route %s", ri.Id)
+			}
+		}
+	})
+
+	t.Run("clone", func(t *testing.T) {
+		c := NewClone(regexp.MustCompile("Source[(](.*)[)]"), "ClientIP($1)")
+		c.Match = match
+		result := c.Do(r)
+		if d := cmp.Diff(
+			[]string{"payments", "search", "other", "clone_payments"},
+			routeIDs(result),
+		); d != "" {
+			t.Error(d)
+		}
+	})
+
+	t.Run("targeted filters", func(t *testing.T) {
+		tf := &TargetedFilters{
+			Match:   match,
+			Prepend: []*Filter{{Name: "first"}},
+			Append:  []*Filter{{Name: "last"}},
+		}
+
+		result := tf.Do(r)
+		if d := cmp.Diff([]string{"first", "last"}, filterNames(result[0].Filters)); d != "" {
+			t.Error(d)
+		}
+
+		for _, ri := range result[1:] {
+			if len(ri.Filters) != 0 {
+				t.Errorf("unexpected filters in route %s", ri.Id)
+			}
+		}
+
+		if len(r[0].Filters) != 0 {
+			t.Error("input route modified")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package eskip
+
+// RouteStats contains aggregated numbers about a list of routes.
+type RouteStats struct {
+	// Routes is the number of routes.
+	Routes int
+
+	// Predicates is the total number of predicates, including the ones
+	// represented by the convenience fields, like Path or Headers.
+	Predicates int
+
+	// Filters is the total number of filters.
+	Filters int
+
+	// Backends is the number of distinct network backend addresses,
+	// including the load balancer endpoints.
+	Backends int
+
+	// Shunt, Loopback, Dynamic and LB are the number of routes with the
+	// corresponding backend type.
+	Shunt    int
+	Loopback int
+	Dynamic  int
+	LB       int
+}
+
+// Stats returns aggregated numbers about a list of routes. The predicates
+// are counted in their canonical form, see Canonical().
+func Stats(routes []*Route) RouteStats {
+	var s RouteStats
+	backends := make(map[string]struct{})
+	for _, r := range routes {
+		if r == nil {
+			continue
+		}
+
+		c := Canonical(r)
+		s.Routes++
+		s.Predicates += len(c.Predicates)
+		s.Filters += len(c.Filters)
+
+		switch c.BackendType {
+		case ShuntBackend:
+			s.Shunt++
+		case LoopBackend:
+			s.Loopback++
+		case DynamicBackend:
+			s.Dynamic++
+		case LBBackend:
+			s.LB++
+			for _, ep := range c.LBEndpoints {
+				backends[ep] = struct{}{}
+			}
+		default:
+			backends[c.Backend] = struct{}{}
+		}
+	}
+
+	s.Backends = len(backends)
+	return s
+}
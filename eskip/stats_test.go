@@ -0,0 +1,63 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStats(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect RouteStats
+	}{{
+		title: "no routes",
+	}, {
+		title: "convenience predicates counted",
+		routes: `
+			r1: Path("/foo") && Method("GET") && Header("X-Foo", "bar") && Traffic(.3)
+			    -> setPath("/") -> "https://foo.example.org";
+		`,
+		expect: RouteStats{Routes: 1, Predicates: 4, Filters: 1, Backends: 1},
+	}, {
+		title: "backend types",
+		routes: `
+			r1: Path("/foo") -> "https://foo.example.org";
+			r2: Path("/bar") -> "https://foo.example.org";
+			r3: * -> status(404) -> <shunt>;
+			r4: * -> setPath("/") -> <loopback>;
+			r5: * -> <dynamic>;
+			r6: * -> <"https://foo.example.org", "https://lb.example.org">;
+		`,
+		expect: RouteStats{
+			Routes:     6,
+			Predicates: 2,
+			Filters:    2,
+			Backends:   2,
+			Shunt:      1,
+			Loopback:   1,
+			Dynamic:    1,
+			LB:         1,
+		},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, Stats(r)); d != "" {
+				t.Error("invalid stats")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestStatsLegacyShunt(t *testing.T) {
+	s := Stats([]*Route{{Shunt: true}})
+	if s.Shunt != 1 || s.Backends != 0 {
+		t.Errorf("failed to count legacy shunt route: %+v", s)
+	}
+}
@@ -2,15 +2,44 @@ package eskip
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuoteStyle tells the printer how to quote the string args of the
+// predicates and the filters. The regular expressions are printed between
+// '/' delimiters regardless of the style.
+type QuoteStyle int
+
+const (
+	// DoubleQuotes prints the strings between double quotes, e.g. "foo".
+	DoubleQuotes QuoteStyle = iota
+
+	// SingleQuotes prints the strings between single quotes, e.g. 'foo'.
+	SingleQuotes
+
+	// BacktickQuotes prints the strings between backticks, e.g. `foo`,
+	// when they don't contain a backtick, otherwise between double quotes.
+	BacktickQuotes
 )
 
 type PrettyPrintInfo struct {
 	Pretty    bool
 	IndentStr string
+
+	// AnyPredicate tells the printer to print the catch-all routes with
+	// the explicit Any() predicate instead of *.
+	AnyPredicate bool
+
+	// QuoteStyle tells the printer how to quote the string args. The
+	// default is DoubleQuotes.
+	QuoteStyle QuoteStyle
 }
 
 func escape(s string, chars string) string {
@@ -29,20 +58,88 @@ func escape(s string, chars string) string {
 	return s
 }
 
+var controlEscapes = map[rune]string{
+	'\a': `\a`,
+	'\b': `\b`,
+	'\f': `\f`,
+	'\n': `\n`,
+	'\r': `\r`,
+	'\t': `\t`,
+	'\v': `\v`,
+}
+
+// escapes a string literal like escape(), and additionally escapes the
+// backslash, and the rest of the non-printable characters as unicode escape
+// sequences
+func escapeString(s string, chars string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == escapeChar:
+			b.WriteString(`\\`)
+		case strings.ContainsRune(chars, r):
+			b.WriteByte(escapeChar)
+			b.WriteRune(r)
+		case controlEscapes[r] != "":
+			b.WriteString(controlEscapes[r])
+		case r == utf8.RuneError && n == 1, unicode.IsPrint(r):
+			b.WriteString(s[i : i+n])
+		case r > 0xffff:
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+
+		i += n
+	}
+
+	return b.String()
+}
+
+// escapes the / delimiter in a regexp, unless it is escaped already, e.g. \/
+func escapeRegexp(s string) string {
+	s = escape(s, "")
+
+	var b strings.Builder
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '/' && !escaped {
+			b.WriteByte(escapeChar)
+		}
+
+		escaped = c == escapeChar && !escaped
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
 func appendFmt(s []string, format string, args ...interface{}) []string {
 	return append(s, fmt.Sprintf(format, args...))
 }
 
-func appendFmtEscape(s []string, format string, escapeChars string, args ...interface{}) []string {
-	eargs := make([]interface{}, len(args))
-	for i, arg := range args {
-		eargs[i] = escape(fmt.Sprintf("%v", arg), escapeChars)
+// quotes and escapes a string literal in the requested style
+func quoteString(s string, style QuoteStyle) string {
+	q := `"`
+	switch style {
+	case SingleQuotes:
+		q = "'"
+	case BacktickQuotes:
+		if !strings.Contains(s, "`") {
+			q = "`"
+		}
 	}
 
-	return appendFmt(s, format, eargs...)
+	return q + escapeString(s, q) + q
 }
 
 func argsString(args []interface{}) string {
+	return argsStringQuoted(args, DoubleQuotes)
+}
+
+func argsStringQuoted(args []interface{}, style QuoteStyle) string {
 	var sargs []string
 	for _, a := range args {
 		switch v := a.(type) {
@@ -59,17 +156,23 @@ func argsString(args []interface{}) string {
 
 			sargs = appendFmt(sargs, f, a)
 		case string:
-			sargs = appendFmtEscape(sargs, `"%s"`, `"`, a)
+			sargs = append(sargs, quoteString(v, style))
+		case *Predicate:
+			sargs = append(sargs, v.PrintQuoted(style))
+		case []byte:
+			sargs = appendFmt(sargs, `b64"%s"`, base64.StdEncoding.EncodeToString(v))
+		case Duration, ByteSize:
+			sargs = append(sargs, fmt.Sprint(v))
 		default:
 			if m, ok := a.(interface{ MarshalText() ([]byte, error) }); ok {
 				t, err := m.MarshalText()
 				if err != nil {
 					sargs = append(sargs, `"[error]"`)
 				} else {
-					sargs = appendFmtEscape(sargs, `"%s"`, `"`, string(t))
+					sargs = append(sargs, quoteString(string(t), style))
 				}
 			} else {
-				sargs = appendFmtEscape(sargs, `"%s"`, `"`, a)
+				sargs = append(sargs, quoteString(fmt.Sprint(a), style))
 			}
 		}
 	}
@@ -77,57 +180,159 @@ func argsString(args []interface{}) string {
 	return strings.Join(sargs, ", ")
 }
 
-func (r *Route) predicateString() string {
-	var predicates []string
+// a printed predicate with its name, used for restoring the source order
+type predicateItem struct {
+	name string
+	str  string
+}
+
+func appendPredicate(p []predicateItem, name string, format string, args ...interface{}) []predicateItem {
+	return append(p, predicateItem{name: name, str: fmt.Sprintf(format, args...)})
+}
+
+// reorders the predicates by the names in the order, taking the first
+// unused predicate with the given name. The predicates missing from the
+// order keep their position after the ordered ones.
+func orderPredicates(p []predicateItem, order []string) []predicateItem {
+	if len(order) == 0 {
+		return p
+	}
+
+	used := make([]bool, len(p))
+	ordered := make([]predicateItem, 0, len(p))
+	for _, name := range order {
+		for i := range p {
+			if !used[i] && p[i].name == name {
+				used[i] = true
+				ordered = append(ordered, p[i])
+				break
+			}
+		}
+	}
+
+	for i := range p {
+		if !used[i] {
+			ordered = append(ordered, p[i])
+		}
+	}
+
+	return ordered
+}
+
+func (r *Route) predicateString(prettyPrintInfo PrettyPrintInfo) string {
+	var predicates []predicateItem
+	quote := func(s interface{}) string { return quoteString(fmt.Sprint(s), prettyPrintInfo.QuoteStyle) }
 
 	if r.Path != "" {
-		predicates = appendFmtEscape(predicates, `Path("%s")`, `"`, r.Path)
+		predicates = appendPredicate(predicates, "Path", "Path(%s)", quote(r.Path))
 	}
 
 	for _, h := range r.HostRegexps {
-		predicates = appendFmtEscape(predicates, "Host(/%s/)", "/", h)
+		predicates = appendPredicate(predicates, "Host", "Host(/%s/)", escapeRegexp(h))
 	}
 
 	for _, p := range r.PathRegexps {
-		predicates = appendFmtEscape(predicates, "PathRegexp(/%s/)", "/", p)
+		predicates = appendPredicate(predicates, "PathRegexp", "PathRegexp(/%s/)", escapeRegexp(p))
 	}
 
 	if r.Method != "" {
-		predicates = appendFmtEscape(predicates, `Method("%s")`, `"`, r.Method)
+		predicates = appendPredicate(predicates, "Method", "Method(%s)", quote(r.Method))
 	}
 
-	for k, v := range r.Headers {
-		predicates = appendFmtEscape(predicates, `Header("%s", "%s")`, `"`, k, v)
+	for _, p := range HeaderPredicates(r) {
+		if p.Name == "Header" {
+			predicates = appendPredicate(predicates, p.Name, "Header(%s, %s)", quote(p.Args[0]), quote(p.Args[1]))
+		} else {
+			predicates = appendPredicate(predicates, p.Name, "HeaderRegexp(%s, /%s/)", quote(p.Args[0]), escapeRegexp(p.Args[1].(string)))
+		}
 	}
 
-	for k, rxs := range r.HeaderRegexps {
-		for _, rx := range rxs {
-			predicates = appendFmt(predicates, `HeaderRegexp("%s", /%s/)`, escape(k, `"`), escape(rx, "/"))
-		}
+	for _, p := range QueryParamPredicates(r) {
+		predicates = appendPredicate(predicates, p.Name, "%s(%s)", p.Name, argsStringQuoted(p.Args, prettyPrintInfo.QuoteStyle))
+	}
+
+	for _, p := range CookiePredicates(r) {
+		predicates = appendPredicate(predicates, p.Name, "%s(%s)", p.Name, argsStringQuoted(p.Args, prettyPrintInfo.QuoteStyle))
 	}
 
 	for _, p := range r.Predicates {
 		if p.Name != "Any" {
-			predicates = appendFmt(predicates, "%s(%s)", p.Name, argsString(p.Args))
+			predicates = appendPredicate(predicates, p.Name, "%s(%s)", p.Name, argsStringQuoted(p.Args, prettyPrintInfo.QuoteStyle))
 		}
 	}
 
+	if r.Fallback {
+		predicates = appendPredicate(predicates, "Fallback", "Fallback()")
+	}
+
 	if len(predicates) == 0 {
-		predicates = append(predicates, "*")
+		if prettyPrintInfo.AnyPredicate {
+			return "Any()"
+		}
+
+		return "*"
 	}
 
-	return strings.Join(predicates, " && ")
+	predicates = orderPredicates(predicates, r.PredicateOrder)
+	s := make([]string, len(predicates))
+	for i, p := range predicates {
+		s[i] = p.str
+	}
+
+	return strings.Join(s, " && ")
+}
+
+func separatorString(prettyPrintInfo PrettyPrintInfo) string {
+	if prettyPrintInfo.Pretty {
+		return "\n" + prettyPrintInfo.IndentStr + "-> "
+	}
+
+	return " -> "
+}
+
+// a comment runs until the end of the line, so the next separator must start
+// in a new line.
+func separatorAfter(f *Filter, prettyPrintInfo PrettyPrintInfo) string {
+	if f.Comment == "" || prettyPrintInfo.Pretty {
+		return separatorString(prettyPrintInfo)
+	}
+
+	return "\n-> "
+}
+
+func filterString(f *Filter, style QuoteStyle) string {
+	s := f.PrintQuoted(style)
+	if f.Comment != "" {
+		s += " // " + f.Comment
+	}
+
+	return s
 }
 
 func (r *Route) filterString(prettyPrintInfo PrettyPrintInfo) string {
-	var sfilters []string
-	for _, f := range r.Filters {
-		sfilters = appendFmt(sfilters, "%s(%s)", f.Name, argsString(f.Args))
+	var b strings.Builder
+	for i, f := range r.Filters {
+		if i > 0 {
+			b.WriteString(separatorAfter(r.Filters[i-1], prettyPrintInfo))
+		}
+
+		b.WriteString(filterString(f, prettyPrintInfo.QuoteStyle))
 	}
-	if prettyPrintInfo.Pretty {
-		return strings.Join(sfilters, "\n"+prettyPrintInfo.IndentStr+"-> ")
+
+	return b.String()
+}
+
+// FilterChainString returns the filters of the route, formatted the same way
+// as by Filter.String(), and separated by ->, e.g. for logging. The filter
+// comments are omitted. When the route has no filters, it returns an empty
+// string.
+func (r *Route) FilterChainString() string {
+	fs := make([]string, len(r.Filters))
+	for i, f := range r.Filters {
+		fs[i] = f.String()
 	}
-	return strings.Join(sfilters, " -> ")
+
+	return strings.Join(fs, " -> ")
 }
 
 func (r *Route) backendString() string {
@@ -174,19 +379,14 @@ func (r *Route) String() string {
 }
 
 func (r *Route) Print(prettyPrintInfo PrettyPrintInfo) string {
-	s := []string{r.predicateString()}
+	separator := separatorString(prettyPrintInfo)
+	s := r.predicateString(prettyPrintInfo) + separator
 
-	fs := r.filterString(prettyPrintInfo)
-	if fs != "" {
-		s = append(s, fs)
+	if fs := r.filterString(prettyPrintInfo); fs != "" {
+		s += fs + separatorAfter(r.Filters[len(r.Filters)-1], prettyPrintInfo)
 	}
 
-	s = append(s, r.backendStringQuoted())
-	separator := " -> "
-	if prettyPrintInfo.Pretty {
-		separator = "\n" + prettyPrintInfo.IndentStr + "-> "
-	}
-	return strings.Join(s, separator)
+	return s + r.backendStringQuoted()
 }
 
 // String is the same as Print but defaulting to pretty=false.
@@ -208,11 +408,36 @@ func isDefinition(route *Route) bool {
 	return route.Id != ""
 }
 
+// the annotations are printed as comment lines preceding the route, in a
+// stable order.
+func fprintAnnotations(w io.Writer, route *Route) {
+	annotations := routeAnnotations(route)
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := annotations[k]
+		switch {
+		case k == DisabledAnnotation && v != "":
+			fmt.Fprintf(w, "// %s%s: %s\n", annotationPrefix, k, v)
+		case v != "":
+			fmt.Fprintf(w, "// %s%s=%s\n", annotationPrefix, k, v)
+		default:
+			fmt.Fprintf(w, "// %s%s\n", annotationPrefix, k)
+		}
+	}
+}
+
 func fprintExpression(w io.Writer, route *Route, prettyPrintInfo PrettyPrintInfo) {
+	fprintAnnotations(w, route)
 	fmt.Fprint(w, route.Print(prettyPrintInfo))
 }
 
 func fprintDefinition(w io.Writer, route *Route, prettyPrintInfo PrettyPrintInfo) {
+	fprintAnnotations(w, route)
 	fmt.Fprintf(w, "%s: %s", route.Id, route.Print(prettyPrintInfo))
 }
 
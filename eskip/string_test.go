@@ -3,7 +3,10 @@ package eskip
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func findDiffPos(left, right string) (pos int, leftOut, rightOut string) {
@@ -64,8 +67,8 @@ func TestRouteString(t *testing.T) {
 				`ap"key`: {"slash/value0", "slash/value1"}},
 			Predicates: []*Predicate{{"Test", []interface{}{3.14, "hello"}}},
 			Filters: []*Filter{
-				{"filter0", []interface{}{float64(3.1415), "argvalue"}},
-				{"filter1", []interface{}{float64(-42), `ap"argvalue`}}},
+				{Name: "filter0", Args: []interface{}{float64(3.1415), "argvalue"}},
+				{Name: "filter1", Args: []interface{}{float64(-42), `ap"argvalue`}}},
 			Shunt:   false,
 			Backend: "https://www.example.org"},
 		`Path("/some/\"/path") && Host(/h-expression/) && ` +
@@ -80,34 +83,34 @@ func TestRouteString(t *testing.T) {
 	}, {
 		&Route{
 			Method:  "GET",
-			Filters: []*Filter{{"static", []interface{}{"/some", "/file"}}},
+			Filters: []*Filter{{Name: "static", Args: []interface{}{"/some", "/file"}}},
 			Shunt:   true},
 		`Method("GET") -> static("/some", "/file") -> <shunt>`,
 	}, {
 		&Route{
 			Method:      "GET",
-			Filters:     []*Filter{{"static", []interface{}{"/some", "/file"}}},
+			Filters:     []*Filter{{Name: "static", Args: []interface{}{"/some", "/file"}}},
 			BackendType: ShuntBackend},
 		`Method("GET") -> static("/some", "/file") -> <shunt>`,
 	}, {
 		&Route{
 			Method:      "GET",
-			Filters:     []*Filter{{"static", []interface{}{"/some", "/file"}}},
+			Filters:     []*Filter{{Name: "static", Args: []interface{}{"/some", "/file"}}},
 			BackendType: LoopBackend},
 		`Method("GET") -> static("/some", "/file") -> <loopback>`,
 	}, {
 		&Route{
-			Filters:     []*Filter{{"filter0", []interface{}{"arg"}}},
+			Filters:     []*Filter{{Name: "filter0", Args: []interface{}{"arg"}}},
 			BackendType: DynamicBackend},
 		`* -> filter0("arg") -> <dynamic>`,
 	}, {
 		&Route{
-			Filters:     []*Filter{{"filter0", []interface{}{`Line 1\r\nLine 2`}}},
+			Filters:     []*Filter{{Name: "filter0", Args: []interface{}{`Line 1\r\nLine 2`}}},
 			BackendType: DynamicBackend},
-		`* -> filter0("Line 1\r\nLine 2") -> <dynamic>`,
+		`* -> filter0("Line 1\\r\\nLine 2") -> <dynamic>`,
 	}, {
 		&Route{
-			Filters:     []*Filter{{"filter0", []interface{}{"Line 1\r\nLine 2"}}},
+			Filters:     []*Filter{{Name: "filter0", Args: []interface{}{"Line 1\r\nLine 2"}}},
 			BackendType: DynamicBackend},
 		`* -> filter0("Line 1\r\nLine 2") -> <dynamic>`,
 	}} {
@@ -224,6 +227,9 @@ func TestNumberString(t *testing.T) {
 		123,
 		123456789,
 		123456789012345678901234567890,
+		-42,
+		0xFF,
+		1_000_000,
 	} {
 		t.Run(fmt.Sprint(ti), func(t *testing.T) {
 			in := &Route{Filters: []*Filter{{Name: "filter", Args: []interface{}{ti}}}}
@@ -481,3 +487,396 @@ testRoute3: Path("/baz")
 		})
 	})
 }
+
+func TestPrintFilterComments(t *testing.T) {
+	r := &Route{
+		Filters: []*Filter{
+			{Name: "foo", Comment: "foo comment"},
+			{Name: "status", Args: []interface{}{float64(418)}, Comment: "added for incident-1234"},
+		},
+		BackendType: ShuntBackend,
+	}
+
+	for _, test := range []struct {
+		title  string
+		pretty PrettyPrintInfo
+		expect string
+	}{{
+		title:  "non-pretty",
+		expect: "* -> foo() // foo comment\n-> status(418) // added for incident-1234\n-> <shunt>",
+	}, {
+		title:  "pretty",
+		pretty: PrettyPrintInfo{Pretty: true, IndentStr: "  "},
+		expect: "*\n  -> foo() // foo comment\n  -> status(418) // added for incident-1234\n  -> <shunt>",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			s := r.Print(test.pretty)
+			if s != test.expect {
+				t.Fatalf("invalid route string, got: %q, expected: %q", s, test.expect)
+			}
+
+			rr, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(r.Filters, rr[0].Filters); d != "" {
+				t.Error("failed to round-trip filter comments")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestPrintAnnotations(t *testing.T) {
+	r := []*Route{{
+		Id:          "r1",
+		Annotations: map[string]string{"team": "gateway", "experimental": ""},
+		BackendType: ShuntBackend,
+	}, {
+		Id:          "r2",
+		BackendType: ShuntBackend,
+	}}
+
+	s := String(r...)
+	expect := "// @experimental\n// @team=gateway\nr1: * -> <shunt>;\nr2: * -> <shunt>;"
+	if s != expect {
+		t.Fatalf("invalid routes string, got: %q, expected: %q", s, expect)
+	}
+
+	rr, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(r[0].Annotations, rr[0].Annotations); d != "" || rr[1].Annotations != nil {
+		t.Error("failed to round-trip annotations")
+		t.Log(d)
+	}
+}
+
+func TestPrintRegexpDelimiters(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		regexp string
+		expect string
+	}{{
+		title:  "delimiter",
+		regexp: `^/api/`,
+		expect: `^/api/`,
+	}, {
+		title:  "escaped backslash",
+		regexp: `\\`,
+		expect: `\\`,
+	}, {
+		title:  "escaped backslash followed by delimiter",
+		regexp: `a\\/b`,
+		expect: `a\\/b`,
+	}, {
+		title:  "escaped delimiter",
+		regexp: `a\/b`,
+		expect: `a/b`,
+	}, {
+		title:  "delimiter in a class",
+		regexp: `[/]`,
+		expect: `[/]`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r := &Route{
+				PathRegexps:   []string{test.regexp},
+				HeaderRegexps: map[string][]string{"X-Foo": {test.regexp}},
+				BackendType:   ShuntBackend,
+			}
+
+			rr, err := Parse(r.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rr[0].PathRegexps) != 1 || rr[0].PathRegexps[0] != test.expect {
+				t.Errorf("failed to round-trip the path regexp, got: %v, expected: %s", rr[0].PathRegexps, test.expect)
+			}
+
+			if len(rr[0].HeaderRegexps["X-Foo"]) != 1 || rr[0].HeaderRegexps["X-Foo"][0] != test.expect {
+				t.Errorf("failed to round-trip the header regexp, got: %v, expected: %s", rr[0].HeaderRegexps, test.expect)
+			}
+		})
+	}
+}
+
+func TestZeroArgPredicatesRoundTrip(t *testing.T) {
+	for _, code := range []string{
+		`Custom2() -> "https://www.example.org"`,
+		`Custom1(3.14, "test value") && Custom2() -> "https://www.example.org"`,
+		`Path("/foo") && Custom2() && Custom3() -> <shunt>`,
+	} {
+		t.Run(code, func(t *testing.T) {
+			r, err := Parse(code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := r[0].String()
+			if !strings.Contains(s, "Custom2()") {
+				t.Errorf("failed to print the empty parentheses: %s", s)
+			}
+
+			rr, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(r, rr); d != "" {
+				t.Error("failed to round-trip zero-arg predicates")
+				t.Log(d)
+			}
+		})
+	}
+}
+
+func TestPrintPredicateOrder(t *testing.T) {
+	for _, test := range []struct {
+		title string
+		code  string
+	}{{
+		title: "custom predicate before the path",
+		code:  `Traffic(0.3) && Path("/foo") -> <shunt>`,
+	}, {
+		title: "mixed predicates",
+		code: `Header("X-A", "a") && Host(/^b[.]example[.]org$/) && Method("GET") && ` +
+			`Header("X-B", "b") && Fallback() && Host(/^a[.]example[.]org$/) && Cookie("foo", "bar") -> <shunt>`,
+	}, {
+		title: "catch all",
+		code:  `* -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := ParseWithOptions(test.code, ParseOptions{PredicateOrder: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := r[0].String(); s != test.code {
+				t.Errorf("failed to preserve the predicate order, got: %s, expected: %s", s, test.code)
+			}
+
+			if s := r[0].Copy().String(); s != test.code {
+				t.Errorf("failed to preserve the predicate order in the copy, got: %s, expected: %s", s, test.code)
+			}
+		})
+	}
+}
+
+func TestPredicateOrderDefault(t *testing.T) {
+	r, err := Parse(`Traffic(0.3) && Path("/foo") -> <shunt>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r[0].PredicateOrder != nil {
+		t.Error("unexpected predicate order")
+	}
+
+	if s := r[0].String(); s != `Path("/foo") && Traffic(0.3) -> <shunt>` {
+		t.Errorf("unexpected route string: %s", s)
+	}
+}
+
+func TestUnicodeEscapesRoundTrip(t *testing.T) {
+	const arg = "caf\u00e9 \u00fcber\x00\x1b\u200b\U0001F600\t\r\n\""
+
+	r := &Route{
+		Filters:     []*Filter{{Name: "inlineContent", Args: []interface{}{arg}}},
+		BackendType: ShuntBackend,
+	}
+
+	s := r.String()
+	if expect := `* -> inlineContent("café über\u0000\u001b\u200b😀\t\r\n\"") -> <shunt>`; s != expect {
+		t.Errorf("invalid route string, got: %s, expected: %s", s, expect)
+	}
+
+	rr, err := Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rr[0].Filters[0].Args[0]; got != arg {
+		t.Errorf("failed to round-trip the arg, got: %q, expected: %q", got, arg)
+	}
+}
+
+func TestBackslashRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		arg    string
+		expect string
+	}{{
+		title:  "lone backslash",
+		arg:    `\`,
+		expect: `* -> f("\\") -> <shunt>`,
+	}, {
+		title:  "literal unicode escape sequence",
+		arg:    `C:\u00e9x`,
+		expect: `* -> f("C:\\u00e9x") -> <shunt>`,
+	}, {
+		title:  "windows path",
+		arg:    `C:\temp`,
+		expect: `* -> f("C:\\temp") -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r := &Route{
+				Filters:     []*Filter{{Name: "f", Args: []interface{}{test.arg}}},
+				BackendType: ShuntBackend,
+			}
+
+			s := r.String()
+			if s != test.expect {
+				t.Errorf("invalid route string, got: %s, expected: %s", s, test.expect)
+			}
+
+			rr, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rr[0].Filters[0].Args[0]; got != test.arg {
+				t.Errorf("failed to round-trip the arg, got: %q, expected: %q", got, test.arg)
+			}
+		})
+	}
+}
+
+func TestFilterChainString(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		route  string
+		expect string
+	}{{
+		title: "no filters",
+		route: `Path("/foo") -> "https://www.example.org"`,
+	}, {
+		title:  "single filter",
+		route:  `* -> setPath("/") -> <shunt>`,
+		expect: `setPath("/")`,
+	}, {
+		title: "multiple filters with comments",
+		route: `Method("GET")
+			-> setRequestHeader("X-Foo", "\"bar\"") // quoted
+			-> status(418)
+			-> inlineContent("I'm a teapot")
+			-> <shunt>`,
+		expect: `setRequestHeader("X-Foo", "\"bar\"") -> status(418) -> inlineContent("I'm a teapot")`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.route)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := r[0].FilterChainString(); s != test.expect {
+				t.Errorf("invalid filter chain string, got: %s, expected: %s", s, test.expect)
+			}
+		})
+	}
+}
+
+func TestQuoteStyleRoundTrip(t *testing.T) {
+	const code = `Path("/foo") && Method("GET") && HeaderRegexp("X-Bar", /^b[a-z]+$/) &&
+		Header("X-Foo", "it's \"quoted\"") && Custom("a` + "`" + `b", 3.14) && Not(Custom2("baz")) ->
+		setRequestHeader("X-Baz", "qux\n") -> status(418) -> <shunt>`
+
+	for _, test := range []struct {
+		title  string
+		style  QuoteStyle
+		expect string
+	}{{
+		title: "double quotes",
+		style: DoubleQuotes,
+		expect: `Path("/foo") && Method("GET") && HeaderRegexp("X-Bar", /^b[a-z]+$/) && ` +
+			`Header("X-Foo", "it's \"quoted\"") && Custom("a` + "`" + `b", 3.14) && Not(Custom2("baz")) -> ` +
+			`setRequestHeader("X-Baz", "qux\n") -> status(418) -> <shunt>`,
+	}, {
+		title: "single quotes",
+		style: SingleQuotes,
+		expect: `Path('/foo') && Method('GET') && HeaderRegexp('X-Bar', /^b[a-z]+$/) && ` +
+			`Header('X-Foo', 'it\'s "quoted"') && Custom('a` + "`" + `b', 3.14) && Not(Custom2('baz')) -> ` +
+			`setRequestHeader('X-Baz', 'qux\n') -> status(418) -> <shunt>`,
+	}, {
+		title: "backticks when possible",
+		style: BacktickQuotes,
+		expect: "Path(`/foo`) && Method(`GET`) && HeaderRegexp(`X-Bar`, /^b[a-z]+$/) && " +
+			"Header(`X-Foo`, `it's \"quoted\"`) && Custom(\"a`b\", 3.14) && Not(Custom2(`baz`)) -> " +
+			"setRequestHeader(`X-Baz`, `qux\\n`) -> status(418) -> <shunt>",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := r[0].Print(PrettyPrintInfo{QuoteStyle: test.style})
+			if s != test.expect {
+				t.Errorf("invalid route string, got: %s, expected: %s", s, test.expect)
+			}
+
+			rr, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(r, rr); d != "" {
+				t.Errorf("failed to round-trip the route: %s", d)
+			}
+
+			if f := rr[0].Filters[0].PrintQuoted(test.style); !strings.Contains(test.expect, f) {
+				t.Errorf("invalid filter string: %s", f)
+			}
+		})
+	}
+}
+
+func TestPrintArrowPlacement(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		route  *Route
+		expect string
+		pretty string
+	}{{
+		title:  "no filters",
+		route:  &Route{Backend: "https://www.example.org"},
+		expect: `* -> "https://www.example.org"`,
+		pretty: "*\n  -> \"https://www.example.org\"",
+	}, {
+		title:  "empty filter chain",
+		route:  &Route{Filters: []*Filter{}, Backend: "https://www.example.org"},
+		expect: `* -> "https://www.example.org"`,
+		pretty: "*\n  -> \"https://www.example.org\"",
+	}, {
+		title: "multiple filters",
+		route: &Route{
+			Method:      "GET",
+			Filters:     []*Filter{{Name: "setPath", Args: []interface{}{"/"}}, {Name: "status", Args: []interface{}{418.0}}},
+			BackendType: ShuntBackend,
+		},
+		expect: `Method("GET") -> setPath("/") -> status(418) -> <shunt>`,
+		pretty: "Method(\"GET\")\n  -> setPath(\"/\")\n  -> status(418)\n  -> <shunt>",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			if s := test.route.String(); s != test.expect {
+				t.Errorf("invalid route string, got: %q, expected: %q", s, test.expect)
+			}
+
+			if s := test.route.Print(PrettyPrintInfo{Pretty: true, IndentStr: "  "}); s != test.pretty {
+				t.Errorf("invalid pretty route string, got: %q, expected: %q", s, test.pretty)
+			}
+
+			r, err := Parse(test.expect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r[0].Filters) != len(test.route.Filters) {
+				t.Errorf("invalid number of filters after parsing: %d", len(r[0].Filters))
+			}
+		})
+	}
+}
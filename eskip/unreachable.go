@@ -0,0 +1,277 @@
+package eskip
+
+import (
+	"sort"
+	"strings"
+)
+
+// the predicates that don't affect which requests the route matches
+var nonMatchingPredicates = map[string]bool{
+	"Weight": true,
+}
+
+// the predicates that match only a part of the requests otherwise matched by
+// the route, e.g. randomly, therefore a route with them doesn't shadow the
+// subsequent routes
+var partialPredicates = map[string]bool{
+	"Traffic":        true,
+	"TrafficSegment": true,
+}
+
+func matchingPredicates(r *Route) []*Predicate {
+	var p []*Predicate
+	for _, pi := range Canonical(r).Predicates {
+		if !nonMatchingPredicates[pi.Name] {
+			p = append(p, pi)
+		}
+	}
+
+	return p
+}
+
+// IsCatchAll tells whether the route matches every request, i.e. it has
+// no predicates other than Any and Weight.
+func (r *Route) IsCatchAll() bool {
+	for _, p := range matchingPredicates(r) {
+		if p.Name != "Any" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLiteralPath(p string) bool {
+	return !strings.ContainsAny(p, ":*")
+}
+
+// returns the path of the Path predicate of the route, either from the
+// Path field or from the generic predicates
+func routePath(r *Route) (string, bool) {
+	if r.Path != "" {
+		return r.Path, true
+	}
+
+	for _, p := range r.Predicates {
+		if p.Name != "Path" {
+			continue
+		}
+
+		if a, err := getStringArgs(1, p.Args); err == nil {
+			return a[0], true
+		}
+	}
+
+	return "", false
+}
+
+// LiteralPaths returns the routes with a literal Path predicate, i.e.
+// without wildcards, grouped by the path, e.g. to build an exact match
+// index of the paths. The routes matching the paths by wildcards,
+// PathSubtree or PathRegexp, without a literal Path, are not included. The
+// routes in a group keep their order, and other predicates of the routes,
+// e.g. PathRegexp, still need to be evaluated when matching them.
+func LiteralPaths(routes []*Route) map[string][]*Route {
+	paths := make(map[string][]*Route)
+	for _, r := range routes {
+		if p, ok := routePath(r); ok && isLiteralPath(p) {
+			paths[p] = append(paths[p], r)
+		}
+	}
+
+	return paths
+}
+
+// returns the methods matched by the route, or nil and false when the route
+// doesn't restrict the methods. When the route has multiple method
+// constraints, each of them needs to match.
+func routeMethods(r *Route) (map[string]bool, bool) {
+	var constraints [][]string
+	if r.Method != "" {
+		constraints = append(constraints, []string{strings.ToUpper(r.Method)})
+	}
+
+	for _, p := range r.Predicates {
+		if !isMethodPredicate(p) {
+			continue
+		}
+
+		var methods []string
+		for _, a := range p.Args {
+			if m, ok := a.(string); ok {
+				methods = append(methods, strings.ToUpper(m))
+			}
+		}
+
+		constraints = append(constraints, methods)
+	}
+
+	if len(constraints) == 0 {
+		return nil, false
+	}
+
+	methods := make(map[string]bool)
+	for _, m := range constraints[0] {
+		methods[m] = true
+	}
+
+	for _, c := range constraints[1:] {
+		next := make(map[string]bool)
+		for _, m := range c {
+			if methods[m] {
+				next[m] = true
+			}
+		}
+
+		methods = next
+	}
+
+	return methods, true
+}
+
+// MethodsForPath returns the sorted set of the HTTP methods served by the
+// routes with a Path predicate exactly matching path, considering the Method
+// field and the Method and Methods predicates of the routes, e.g. to verify
+// the supported methods of an API endpoint. The routes without a method
+// constraint serve all the standard methods. Other predicates of the routes,
+// and the wildcards of the paths, are not evaluated.
+func MethodsForPath(routes []*Route, path string) []string {
+	methods := make(map[string]bool)
+	for _, r := range routes {
+		if p, ok := routePath(r); !ok || p != path {
+			continue
+		}
+
+		rm, ok := routeMethods(r)
+		if !ok {
+			rm = standardMethods
+		}
+
+		for m := range rm {
+			methods[m] = true
+		}
+	}
+
+	if len(methods) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(methods))
+	for m := range methods {
+		result = append(result, m)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// tells whether every request matching p matches implied, too, when unsure,
+// returns false
+func impliesPredicate(p, implied *Predicate) bool {
+	if p.Name == implied.Name && eqArgs(p.Args, implied.Args) {
+		return true
+	}
+
+	switch implied.Name {
+	case "PathSubtree":
+		if p.Name != "Path" && p.Name != "PathSubtree" {
+			return false
+		}
+
+		subtree, err := getStringArgs(1, implied.Args)
+		if err != nil {
+			return false
+		}
+
+		path, err := getStringArgs(1, p.Args)
+		if err != nil || !isLiteralPath(path[0]) {
+			return false
+		}
+
+		return matchPathPattern(subtree[0], path[0], true)
+	case "Methods":
+		if p.Name != "Method" {
+			return false
+		}
+
+		m, err := getStringArgs(1, p.Args)
+		if err != nil {
+			return false
+		}
+
+		for _, a := range implied.Args {
+			if ma, ok := a.(string); ok && strings.EqualFold(ma, m[0]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func predicatesImplied(implied, by []*Predicate) bool {
+	for _, p := range implied {
+		if partialPredicates[p.Name] {
+			return false
+		}
+
+		var found bool
+		for _, bp := range by {
+			if impliesPredicate(bp, p) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PredicatesSubset tells whether every matching constraint of route a is
+// implied by the predicates of route b, i.e. every request matched by b is
+// matched by a, too. E.g. a catch-all route is a predicates subset of any
+// route, and * -> Path("/foo") is a subset of Path("/foo") && Method("GET").
+//
+// It is conservative, and returns false when unsure. Predicates with the same
+// name and args imply each other. Additionally, a PathSubtree predicate is
+// implied by a Path or PathSubtree predicate whose path is under the subtree
+// and has no wildcards, and a Methods predicate is implied by a Method
+// predicate with one of its methods. The Weight predicates are ignored, and
+// when a has a Traffic or TrafficSegment predicate, that match only a part of
+// the requests, PredicatesSubset returns false.
+func PredicatesSubset(a, b *Route) bool {
+	return predicatesImplied(matchingPredicates(a), matchingPredicates(b))
+}
+
+// FindUnreachable returns the routes that can never match, assuming that the
+// routes are evaluated in the order of the list, and the first matching route
+// is selected. A route is considered unreachable, when an earlier route in
+// the list has a subset of its predicates, e.g. a catch-all route shadows
+// every route after it.
+//
+// The analysis is conservative, see PredicatesSubset(). Note that
+// the routing of Skipper selects the routes by their predicates, and not by
+// their order, so FindUnreachable is meant for the cases where the order
+// matters.
+func FindUnreachable(routes []*Route) []*Route {
+	predicates := make([][]*Predicate, len(routes))
+	for i, r := range routes {
+		predicates[i] = matchingPredicates(r)
+	}
+
+	var unreachable []*Route
+	for i, r := range routes {
+		for j := 0; j < i; j++ {
+			if predicatesImplied(predicates[j], predicates[i]) {
+				unreachable = append(unreachable, r)
+				break
+			}
+		}
+	}
+
+	return unreachable
+}
@@ -0,0 +1,281 @@
+package eskip
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindUnreachable(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect []string
+	}{{
+		title: "no routes",
+	}, {
+		title: "no shadowing",
+		routes: `
+			r1: Path("/foo") -> <shunt>;
+			r2: Path("/bar") -> <shunt>;
+			r3: Path("/baz") && Method("POST") -> <shunt>;
+		`,
+	}, {
+		title: "catch-all shadows the rest",
+		routes: `
+			r1: Path("/foo") -> <shunt>;
+			r2: * -> <shunt>;
+			r3: Path("/bar") -> <shunt>;
+			r4: Method("GET") -> <shunt>;
+		`,
+		expect: []string{"r3", "r4"},
+	}, {
+		title: "superset of predicates",
+		routes: `
+			r1: Path("/foo") && Header("X-Foo", "bar") -> <shunt>;
+			r2: Path("/foo") && Method("GET") && Header("X-Foo", "bar") -> <shunt>;
+			r3: Path("/foo") && Header("X-Foo", "baz") -> <shunt>;
+		`,
+		expect: []string{"r2"},
+	}, {
+		title: "same predicates",
+		routes: `
+			r1: Host(/^example[.]org$/) && Custom(42) -> <shunt>;
+			r2: Custom(42) && Host(/^example[.]org$/) -> "https://www.example.org";
+		`,
+		expect: []string{"r2"},
+	}, {
+		title: "later catch-all is reachable",
+		routes: `
+			r1: Path("/foo") -> <shunt>;
+			r2: * -> <shunt>;
+		`,
+	}, {
+		title: "repeated predicates",
+		routes: `
+			r1: Custom("a") && Custom("a") -> <shunt>;
+			r2: Custom("a") -> <shunt>;
+			r3: Custom("a") && Custom("b") -> <shunt>;
+		`,
+		expect: []string{"r2", "r3"},
+	}, {
+		title: "path subtree",
+		routes: `
+			r1: PathSubtree("/api") -> <shunt>;
+			r2: Path("/api/v1") -> <shunt>;
+			r3: Path("/api/:version") -> <shunt>;
+			r4: PathSubtree("/apis") -> <shunt>;
+		`,
+		expect: []string{"r2"},
+	}, {
+		title: "traffic does not shadow",
+		routes: `
+			r1: Path("/foo") && Traffic(.5) -> <shunt>;
+			r2: Path("/foo") -> <shunt>;
+		`,
+	}, {
+		title: "weight is ignored",
+		routes: `
+			r1: Path("/foo") && Weight(10) -> <shunt>;
+			r2: Path("/foo") && Method("GET") -> <shunt>;
+		`,
+		expect: []string{"r2"},
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d := cmp.Diff(test.expect, routeIDs(FindUnreachable(r))); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestPredicatesSubset(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		a, b   string
+		expect bool
+	}{{
+		title:  "catch-all",
+		a:      `* -> <shunt>`,
+		b:      `Path("/foo") && Method("GET") -> <shunt>`,
+		expect: true,
+	}, {
+		title: "catch-all is not a superset",
+		a:     `Path("/foo") -> <shunt>`,
+		b:     `* -> <shunt>`,
+	}, {
+		title:  "same predicates",
+		a:      `Path("/foo") && Header("X-Foo", "bar") -> <shunt>`,
+		b:      `Header("X-Foo", "bar") && Path("/foo") -> <shunt>`,
+		expect: true,
+	}, {
+		title:  "subset",
+		a:      `Path("/foo") -> <shunt>`,
+		b:      `Path("/foo") && Method("GET") -> <shunt>`,
+		expect: true,
+	}, {
+		title: "different args",
+		a:     `Path("/foo") -> <shunt>`,
+		b:     `Path("/bar") -> <shunt>`,
+	}, {
+		title:  "path under subtree",
+		a:      `PathSubtree("/foo") -> <shunt>`,
+		b:      `Path("/foo/bar/baz") -> <shunt>`,
+		expect: true,
+	}, {
+		title:  "subtree under subtree",
+		a:      `PathSubtree("/foo") -> <shunt>`,
+		b:      `PathSubtree("/foo/bar") -> <shunt>`,
+		expect: true,
+	}, {
+		title: "subtree is not under path",
+		a:     `Path("/foo/bar") -> <shunt>`,
+		b:     `PathSubtree("/foo") -> <shunt>`,
+	}, {
+		title: "path with wildcards",
+		a:     `PathSubtree("/foo") -> <shunt>`,
+		b:     `Path("/:name/bar") -> <shunt>`,
+	}, {
+		title:  "method in methods",
+		a:      `Methods("GET", "HEAD") -> <shunt>`,
+		b:      `Method("head") -> <shunt>`,
+		expect: true,
+	}, {
+		title: "method not in methods",
+		a:     `Methods("GET", "HEAD") -> <shunt>`,
+		b:     `Method("POST") -> <shunt>`,
+	}, {
+		title:  "weight ignored",
+		a:      `Path("/foo") && Weight(10) -> <shunt>`,
+		b:      `Path("/foo") -> <shunt>`,
+		expect: true,
+	}, {
+		title: "traffic",
+		a:     `Traffic(.3) -> <shunt>`,
+		b:     `Traffic(.3) -> <shunt>`,
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			a, err := Parse(test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := Parse(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := PredicatesSubset(a[0], b[0]); s != test.expect {
+				t.Errorf("unexpected result, got: %t, expected: %t", s, test.expect)
+			}
+		})
+	}
+}
+
+func TestIsCatchAll(t *testing.T) {
+	r, err := ParseWithOptions(`
+		star: * -> <shunt>;
+		any: Any() -> <shunt>;
+		weighted: Weight(10) -> <shunt>;
+		path: Path("/") -> <shunt>;
+		custom: Any() && Custom() -> <shunt>;
+	`, ParseOptions{AnyPredicate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var catchAll []*Route
+	for _, ri := range r {
+		if ri.IsCatchAll() {
+			catchAll = append(catchAll, ri)
+		}
+	}
+
+	if d := cmp.Diff([]string{"star", "any", "weighted"}, routeIDs(catchAll)); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestLiteralPaths(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/foo") -> <shunt>;
+		r2: Path("/foo") && Method("POST") -> <shunt>;
+		r3: Path("/foo/:id") -> <shunt>;
+		r4: Path("/static/**") -> <shunt>;
+		r5: PathSubtree("/bar") -> <shunt>;
+		r6: PathRegexp("^/baz$") -> <shunt>;
+		r7: Path("/baz") && PathRegexp("^/baz$") -> <shunt>;
+		r8: * -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = append(r, &Route{Id: "r9", Predicates: []*Predicate{{Name: "Path", Args: []interface{}{"/qux"}}}})
+
+	paths := make(map[string][]string)
+	for p, pr := range LiteralPaths(r) {
+		paths[p] = routeIDs(pr)
+	}
+
+	if d := cmp.Diff(map[string][]string{
+		"/foo": {"r1", "r2"},
+		"/baz": {"r7"},
+		"/qux": {"r9"},
+	}, paths); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestMethodsForPath(t *testing.T) {
+	r, err := Parse(`
+		r1: Path("/users") && Method("GET") -> <shunt>;
+		r2: Path("/users") && Methods("post", "PUT") -> <shunt>;
+		r3: Path("/users") && Methods("PUT", "DELETE") && Method("DELETE") -> <shunt>;
+		r4: Path("/users/:id") && Method("PATCH") -> <shunt>;
+		r5: PathSubtree("/users") && Method("OPTIONS") -> <shunt>;
+		r6: Path("/health") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = append(r, &Route{Id: "r7", Predicates: []*Predicate{
+		{Name: "Path", Args: []interface{}{"/users"}},
+		{Name: "Method", Args: []interface{}{"HEAD"}},
+	}}, &Route{Id: "r8", Path: "/orders", Method: "get", Predicates: []*Predicate{
+		{Name: "Methods", Args: []interface{}{"GET", "POST"}},
+	}})
+
+	for _, test := range []struct {
+		path   string
+		expect []string
+	}{{
+		path:   "/users",
+		expect: []string{"DELETE", "GET", "HEAD", "POST", "PUT"},
+	}, {
+		path:   "/users/:id",
+		expect: []string{"PATCH"},
+	}, {
+		path:   "/orders",
+		expect: []string{"GET"},
+	}, {
+		path: "/health",
+		expect: []string{
+			"CONNECT", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE",
+		},
+	}, {
+		path: "/users/42",
+	}} {
+		t.Run(test.path, func(t *testing.T) {
+			if d := cmp.Diff(test.expect, MethodsForPath(r, test.path)); d != "" {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,696 @@
+package eskip
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultDynamicBackendFilters contains the names of the built-in Skipper
+// filters that set the backend of the routes with a dynamic backend.
+var DefaultDynamicBackendFilters = []string{
+	"setDynamicBackendHostFromHeader",
+	"setDynamicBackendSchemeFromHeader",
+	"setDynamicBackendUrlFromHeader",
+	"setDynamicBackendHost",
+	"setDynamicBackendScheme",
+	"setDynamicBackendUrl",
+}
+
+// DurationArg identifies a filter argument that is expected to contain a
+// duration string, e.g. "100ms".
+type DurationArg struct {
+	// Filter is the name of the filter.
+	Filter string
+
+	// Arg is the index of the argument in the filter args.
+	Arg int
+}
+
+// ValidateDurations checks that the string arguments identified by the
+// specs can be parsed with time.ParseDuration. The arguments are not
+// changed, and the arguments of other types and the missing arguments are
+// ignored. It returns an error for each invalid argument, with the route ID.
+func ValidateDurations(routes []*Route, specs ...DurationArg) []error {
+	var errs []error
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			for _, s := range specs {
+				if f.Name != s.Filter || s.Arg < 0 || s.Arg >= len(f.Args) {
+					continue
+				}
+
+				d, ok := f.Args[s.Arg].(string)
+				if !ok {
+					continue
+				}
+
+				if _, err := time.ParseDuration(d); err != nil {
+					errs = append(errs, fmt.Errorf(
+						"invalid duration in route %s, filter %s, arg %d: %w",
+						r.Id, f.Name, s.Arg, err,
+					))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateDynamicBackends checks that every route with a dynamic backend has
+// at least one filter that can set the backend. The names of these filters
+// can be passed in as backendFilters, and when omitted, the
+// DefaultDynamicBackendFilters are used. It returns an error for each invalid
+// route, with the route ID.
+func ValidateDynamicBackends(routes []*Route, backendFilters ...string) []error {
+	if len(backendFilters) == 0 {
+		backendFilters = DefaultDynamicBackendFilters
+	}
+
+	names := make(map[string]bool)
+	for _, n := range backendFilters {
+		names[n] = true
+	}
+
+	var errs []error
+	for _, r := range routes {
+		if !r.IsDynamic() {
+			continue
+		}
+
+		var found bool
+		for _, f := range r.Filters {
+			if names[f.Name] {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			errs = append(errs, fmt.Errorf(
+				"route %s has a dynamic backend but none of the filters setting it: %s",
+				r.Id, strings.Join(backendFilters, ", "),
+			))
+		}
+	}
+
+	return errs
+}
+
+// RegexpComplexityOptions configures ValidateRegexpComplexity.
+type RegexpComplexityOptions struct {
+	// MaxLength is the max length of a regular expression in bytes. When
+	// zero or negative, the length is not limited.
+	MaxLength int
+
+	// AllowNestedQuantifiers disables the check of the nested unbounded
+	// quantifiers, e.g. (a+)*.
+	AllowNestedQuantifiers bool
+}
+
+// DefaultRegexpComplexityOptions limits the regular expressions to 1024
+// bytes and rejects the nested unbounded quantifiers.
+var DefaultRegexpComplexityOptions = RegexpComplexityOptions{MaxLength: 1024}
+
+func isUnboundedRepeat(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		return true
+	case syntax.OpRepeat:
+		return re.Max < 0
+	default:
+		return false
+	}
+}
+
+// tells whether an unbounded repeat contains another unbounded repeat
+func hasNestedUnboundedRepeat(re *syntax.Regexp, insideRepeat bool) bool {
+	unbounded := isUnboundedRepeat(re)
+	if unbounded && insideRepeat {
+		return true
+	}
+
+	for _, sub := range re.Sub {
+		if hasNestedUnboundedRepeat(sub, insideRepeat || unbounded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkRegexpComplexity(expr string, o RegexpComplexityOptions) error {
+	if o.MaxLength > 0 && len(expr) > o.MaxLength {
+		return fmt.Errorf("longer than %d bytes", o.MaxLength)
+	}
+
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return err
+	}
+
+	if !o.AllowNestedQuantifiers && hasNestedUnboundedRepeat(re, false) {
+		return fmt.Errorf("nested unbounded quantifiers in %s", expr)
+	}
+
+	return nil
+}
+
+// ValidateRegexpComplexity checks the regular expressions of the PathRegexp,
+// Host and HeaderRegexp predicates of the routes, to protect the router from
+// the pathological patterns, e.g. when the routes are accepted from less
+// trusted sources. It rejects the invalid regular expressions and the ones
+// longer than the configured max length, and, as a simple heuristic, the
+// regular expressions that contain an unbounded quantifier (*, + or {n,})
+// nested in another unbounded quantifier, like (a+)+ or (a|b*)*. It returns
+// an error for each rejected regular expression, with the route ID.
+func ValidateRegexpComplexity(routes []*Route, o RegexpComplexityOptions) []error {
+	var errs []error
+	for _, r := range routes {
+		for _, p := range Canonical(r).Predicates {
+			var arg int
+			switch p.Name {
+			case "PathRegexp", "Host":
+			case "HeaderRegexp":
+				arg = 1
+			default:
+				continue
+			}
+
+			if arg >= len(p.Args) {
+				continue
+			}
+
+			expr, ok := p.Args[arg].(string)
+			if !ok {
+				continue
+			}
+
+			if err := checkRegexpComplexity(expr, o); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"regexp rejected in route %s, predicate %s: %w",
+					r.Id, p.Name, err,
+				))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateShuntRoutes checks that the routes with a shunt, loopback or
+// dynamic backend don't have a network backend address in the Backend
+// field, which is contradictory. It returns an error for each invalid
+// route, with the route ID.
+func ValidateShuntRoutes(routes []*Route) []error {
+	var errs []error
+	for _, r := range routes {
+		t := r.BackendType
+		if r.Shunt {
+			t = ShuntBackend
+		}
+
+		switch t {
+		case ShuntBackend, LoopBackend, DynamicBackend:
+		default:
+			continue
+		}
+
+		if r.Backend != "" {
+			errs = append(errs, fmt.Errorf(
+				"route %s has a %s backend and a network backend address: %s",
+				r.Id, t, r.Backend,
+			))
+		}
+	}
+
+	return errs
+}
+
+// ValidateShuntField checks that the routes don't rely on the deprecated
+// Shunt field: a route setting Shunt needs to have the ShuntBackend type,
+// too. The routes created by the parser and by the JSON decoder always set
+// both, so this check is meant for the routes constructed by code, to help
+// migrating it to the BackendType field. It returns an error for each
+// invalid route, with the route ID.
+func ValidateShuntField(routes []*Route) []error {
+	var errs []error
+	for _, r := range routes {
+		if r.Shunt && r.BackendType != ShuntBackend {
+			errs = append(errs, fmt.Errorf(
+				"route %s uses the deprecated Shunt field with a %s backend type, instead of the shunt backend type",
+				r.Id, r.BackendType,
+			))
+		}
+	}
+
+	return errs
+}
+
+// ValidateBackendPresence checks that the routes specify a backend: the
+// routes with the default network backend type need a backend address, and
+// the load balanced routes need at least one endpoint. It doesn't validate
+// the backend addresses themselves. It returns an error for each invalid
+// route, with the route ID.
+func ValidateBackendPresence(routes []*Route) []error {
+	var errs []error
+	for _, r := range routes {
+		if r.Shunt {
+			continue
+		}
+
+		switch {
+		case r.BackendType == NetworkBackend && r.Backend == "":
+			errs = append(errs, fmt.Errorf("route %s has no backend", r.Id))
+		case r.BackendType == LBBackend && len(r.LBEndpoints) == 0:
+			errs = append(errs, fmt.Errorf("route %s has a load balanced backend without endpoints", r.Id))
+		}
+	}
+
+	return errs
+}
+
+// Warning describes a potential problem in a route, that doesn't make the
+// route invalid.
+type Warning struct {
+	RouteID   string
+	Predicate string
+	Message   string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("route %s, predicate %s: %s", w.RouteID, w.Predicate, w.Message)
+}
+
+// returns the first and the last node of a parsed regexp, when it is a
+// concatenation
+func regexpEnds(re *syntax.Regexp) (first, last *syntax.Regexp) {
+	if re.Op == syntax.OpConcat && len(re.Sub) > 0 {
+		return re.Sub[0], re.Sub[len(re.Sub)-1]
+	}
+
+	return re, re
+}
+
+func missingAnchors(expr string) []string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	var missing []string
+	first, last := regexpEnds(re)
+	if first.Op != syntax.OpBeginText && first.Op != syntax.OpBeginLine {
+		missing = append(missing, "^")
+	}
+
+	if last.Op != syntax.OpEndText && last.Op != syntax.OpEndLine {
+		missing = append(missing, "$")
+	}
+
+	return missing
+}
+
+// WarnUnanchoredRegexps checks the regular expressions of the PathRegexp and
+// Host predicates of the routes, and warns about the ones that are not
+// anchored at the start with ^ or at the end with $, as the unanchored
+// patterns match any path or host containing them, e.g. Host(/example[.]org/)
+// matches www.example.org.evil.com, too. The invalid regular expressions are
+// ignored, see ValidateRegexpComplexity.
+func WarnUnanchoredRegexps(routes []*Route) []Warning {
+	var w []Warning
+	for _, r := range routes {
+		for _, p := range Canonical(r).Predicates {
+			switch p.Name {
+			case "PathRegexp", "Host", "HostRegexp":
+			default:
+				continue
+			}
+
+			if len(p.Args) != 1 {
+				continue
+			}
+
+			expr, ok := p.Args[0].(string)
+			if !ok {
+				continue
+			}
+
+			missing := missingAnchors(expr)
+			if len(missing) == 0 {
+				continue
+			}
+
+			anchors := "anchor"
+			if len(missing) > 1 {
+				anchors += "s"
+			}
+
+			w = append(w, Warning{
+				RouteID:   r.Id,
+				Predicate: p.Name,
+				Message: fmt.Sprintf(
+					"the regexp %s is missing the %s %s, and it may match more than expected",
+					expr, strings.Join(missing, " and "), anchors,
+				),
+			})
+		}
+	}
+
+	return w
+}
+
+// Suggestion describes a predicate of a route that can be replaced by a
+// cheaper, equivalent predicate.
+type Suggestion struct {
+	RouteID     string
+	Predicate   *Predicate
+	Replacement *Predicate
+}
+
+func (s Suggestion) String() string {
+	return fmt.Sprintf("route %s: %v can be replaced by %v", s.RouteID, s.Predicate, s.Replacement)
+}
+
+// returns the literal matched by a regexp anchored at both ends, e.g. /foo
+// for ^/foo$, when the regexp matches only that literal
+func anchoredLiteral(expr string) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || len(re.Sub) != 3 {
+		return "", false
+	}
+
+	first, lit, last := re.Sub[0], re.Sub[1], re.Sub[2]
+	if first.Op != syntax.OpBeginText || last.Op != syntax.OpEndText ||
+		lit.Op != syntax.OpLiteral || lit.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+
+	return string(lit.Rune), true
+}
+
+// SuggestLiteralPredicates finds the PathRegexp and HeaderRegexp predicates
+// of the routes whose regexp matches only a single literal, e.g.
+// PathRegexp("^/foo$"), and suggests replacing them with the exact match
+// Path and Header predicates, that are cheaper to evaluate. The paths with
+// the wildcard characters : and *, and the case insensitive regexps, are not
+// suggested, as their exact match predicates would not be equivalent.
+func SuggestLiteralPredicates(routes []*Route) []Suggestion {
+	var s []Suggestion
+	for _, r := range routes {
+		for _, p := range Canonical(r).Predicates {
+			var replacement *Predicate
+			switch p.Name {
+			case "PathRegexp":
+				a, err := getStringArgs(1, p.Args)
+				if err != nil {
+					continue
+				}
+
+				if path, ok := anchoredLiteral(a[0]); ok &&
+					strings.HasPrefix(path, "/") && isLiteralPath(path) {
+					replacement = &Predicate{Name: "Path", Args: []interface{}{path}}
+				}
+			case "HeaderRegexp":
+				a, err := getStringArgs(2, p.Args)
+				if err != nil {
+					continue
+				}
+
+				if value, ok := anchoredLiteral(a[1]); ok {
+					replacement = &Predicate{Name: "Header", Args: []interface{}{a[0], value}}
+				}
+			}
+
+			if replacement != nil {
+				s = append(s, Suggestion{RouteID: r.Id, Predicate: p, Replacement: replacement})
+			}
+		}
+	}
+
+	return s
+}
+
+func validateDurationOption(v string) error {
+	_, err := time.ParseDuration(v)
+	return err
+}
+
+func validateNonNegativeIntOption(v string) error {
+	if i, err := strconv.Atoi(v); err != nil || i < 0 {
+		return fmt.Errorf("expected a non-negative integer: %s", v)
+	}
+
+	return nil
+}
+
+// the known backend options and their validation
+var backendOptions = map[string]func(string) error{
+	"timeout":               validateDurationOption,
+	"connect-timeout":       validateDurationOption,
+	"health-check-interval": validateDurationOption,
+	"weight":                validateNonNegativeIntOption,
+}
+
+// ValidateBackendOptions checks that the backend options of the routes are
+// known, and that their values have the right format. The known options are
+// timeout, connect-timeout and health-check-interval, expecting a duration,
+// e.g. 5s, and weight, expecting a non-negative integer. It returns an error
+// for each invalid option, with the route ID.
+func ValidateBackendOptions(routes []*Route) []error {
+	var errs []error
+	for _, r := range routes {
+		keys := make([]string, 0, len(r.BackendOptions))
+		for k := range r.BackendOptions {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			validate, ok := backendOptions[k]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown backend option in route %s: %s", r.Id, k))
+				continue
+			}
+
+			if err := validate(r.BackendOptions[k]); err != nil {
+				errs = append(errs, fmt.Errorf("invalid backend option in route %s, %s: %w", r.Id, k, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// OrderRule requires that when a route has both filters, the filter Before
+// precedes the filter After.
+type OrderRule struct {
+	Before string
+	After  string
+}
+
+func firstFilter(filters []*Filter, name string) int {
+	for i, f := range filters {
+		if f.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ValidateFilterOrder checks that the filters of the routes follow the order
+// rules. A rule is violated when a route has both filters, and the first
+// occurrence of the After filter comes before the first occurrence of the
+// Before filter. Routes with only one of the filters are ignored. It returns
+// an error for each violation, with the route ID and the positions of the
+// filters.
+func ValidateFilterOrder(routes []*Route, rules []OrderRule) []error {
+	var errs []error
+	for _, r := range routes {
+		for _, rule := range rules {
+			before, after := firstFilter(r.Filters, rule.Before), firstFilter(r.Filters, rule.After)
+			if before < 0 || after < 0 || before < after {
+				continue
+			}
+
+			errs = append(errs, fmt.Errorf(
+				"invalid filter order in route %s: %s at position %d must precede %s at position %d",
+				r.Id, rule.Before, before, rule.After, after,
+			))
+		}
+	}
+
+	return errs
+}
+
+// ValidateSingleCatchAll checks that the routing table has exactly one
+// catch-all route, see (*Route).IsCatchAll(). It returns an error when there
+// is no catch-all route, or when there are more than one, listing their IDs.
+func ValidateSingleCatchAll(routes []*Route) error {
+	var ids []string
+	for _, r := range routes {
+		if r.IsCatchAll() {
+			ids = append(ids, r.Id)
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return errors.New("no catch-all route")
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("multiple catch-all routes: %s", strings.Join(ids, ", "))
+	}
+}
+
+// ValidateIDFormat checks that the route IDs match the pattern, e.g.
+// ^[a-z][a-z0-9_]*$. The empty IDs, e.g. of the routes parsed from a single
+// route expression, are accepted only when allowEmpty is set. It returns an
+// error for each invalid route ID.
+func ValidateIDFormat(routes []*Route, pattern *regexp.Regexp, allowEmpty bool) []error {
+	var errs []error
+	for _, r := range routes {
+		switch {
+		case r.Id == "" && allowEmpty:
+		case r.Id == "":
+			errs = append(errs, errors.New("empty route id"))
+		case !pattern.MatchString(r.Id):
+			errs = append(errs, fmt.Errorf("invalid route id: %s, expected to match %s", r.Id, pattern))
+		}
+	}
+
+	return errs
+}
+
+// ValidateRouteReferences checks that the filters referencing other routes
+// by their ID, e.g. teeLoopback("route1"), reference existing routes.
+// The refFilters map the names of these filters to the index of the arg
+// holding the route ID. The filters with a missing or non-string arg at the
+// index are ignored. It returns an error for each unknown reference, with
+// the ID of the referencing route.
+func ValidateRouteReferences(routes []*Route, refFilters map[string]int) []error {
+	ids := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		ids[r.Id] = true
+	}
+
+	var errs []error
+	for _, r := range routes {
+		for _, f := range r.Filters {
+			i, ok := refFilters[f.Name]
+			if !ok || i < 0 || i >= len(f.Args) {
+				continue
+			}
+
+			ref, ok := f.Args[i].(string)
+			if !ok || ids[ref] {
+				continue
+			}
+
+			errs = append(errs, fmt.Errorf(
+				"route %s references an unknown route in filter %s: %s",
+				r.Id, f.Name, ref,
+			))
+		}
+	}
+
+	return errs
+}
+
+// returns the offset of the first byte of s that is not valid UTF-8, or -1
+func invalidUTF8Offset(s string) int {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+
+		i += size
+	}
+
+	return -1
+}
+
+type utf8Validator struct {
+	routeID string
+	errs    []error
+}
+
+func (v *utf8Validator) check(location, s string) {
+	if i := invalidUTF8Offset(s); i >= 0 {
+		v.errs = append(v.errs, fmt.Errorf(
+			"route %s has invalid UTF-8 in %s at byte %d",
+			v.routeID, location, i,
+		))
+	}
+}
+
+func (v *utf8Validator) checkArgs(location string, args []interface{}) {
+	for i, a := range args {
+		argLocation := fmt.Sprintf("%s arg %d", location, i)
+		switch at := a.(type) {
+		case string:
+			v.check(argLocation, at)
+		case *Predicate:
+			v.check(argLocation+" name", at.Name)
+			v.checkArgs(fmt.Sprintf("%s predicate %s", argLocation, at.Name), at.Args)
+		}
+	}
+}
+
+// ValidateUTF8 checks that the strings of the routes are valid UTF-8, as the
+// JSON encoder replaces the invalid bytes silently, and the serialized
+// routes would not be the same. It checks the ID, the names and the string
+// args of the predicates and the filters, including the header names and
+// values, the backend, the load balancer endpoints and the annotations. The
+// errors contain the ID of the route, the location of the string in the
+// route, and the offset of the first invalid byte in the string.
+func ValidateUTF8(routes []*Route) []error {
+	var errs []error
+	for _, r := range routes {
+		v := &utf8Validator{routeID: r.Id}
+		v.check("id", r.Id)
+
+		c := Canonical(r)
+		for _, p := range c.Predicates {
+			v.check("predicate name", p.Name)
+			v.checkArgs("predicate "+p.Name, p.Args)
+		}
+
+		for _, f := range c.Filters {
+			v.check("filter name", f.Name)
+			v.checkArgs("filter "+f.Name, f.Args)
+		}
+
+		v.check("backend", c.Backend)
+		v.check("load balancer algorithm", c.LBAlgorithm)
+		for i, ep := range c.LBEndpoints {
+			v.check(fmt.Sprintf("load balancer endpoint %d", i), ep)
+		}
+
+		a := routeAnnotations(r)
+		keys := make([]string, 0, len(a))
+		for k := range a {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		for _, k := range keys {
+			v.check("annotation key", k)
+			v.check("annotation "+k, a[k])
+		}
+
+		errs = append(errs, v.errs...)
+	}
+
+	return errs
+}
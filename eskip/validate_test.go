@@ -0,0 +1,486 @@
+package eskip
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func checkErrors(t *testing.T, errs []error, expect ...string) {
+	t.Helper()
+
+	if len(errs) != len(expect) {
+		t.Fatalf("invalid number of errors, got: %d, expected: %d; %v", len(errs), len(expect), errs)
+	}
+
+	for i := range errs {
+		if errs[i].Error() != expect[i] {
+			t.Errorf("invalid error, got: %q, expected: %q", errs[i].Error(), expect[i])
+		}
+	}
+}
+
+func TestValidateDurations(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> uniformRequestLatency("100ms", "10ms") -> <shunt>;
+		r2: * -> uniformRequestLatency("100xs", 10) -> <shunt>;
+		r3: * -> uniformRequestLatency("1s") -> normalRequestLatency("5", "1s") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no specs", func(t *testing.T) {
+		checkErrors(t, ValidateDurations(r))
+	})
+
+	t.Run("invalid durations", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateDurations(
+				r,
+				DurationArg{Filter: "uniformRequestLatency", Arg: 0},
+				DurationArg{Filter: "uniformRequestLatency", Arg: 1},
+				DurationArg{Filter: "normalRequestLatency", Arg: 0},
+			),
+			`invalid duration in route r2, filter uniformRequestLatency, arg 0: time: unknown unit "xs" in duration "100xs"`,
+			`invalid duration in route r3, filter normalRequestLatency, arg 0: time: missing unit in duration "5"`,
+		)
+	})
+}
+
+func TestValidateDynamicBackends(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> setDynamicBackendUrl("https://www.example.org") -> <dynamic>;
+		r2: * -> setPath("/") -> <dynamic>;
+		r3: * -> setPath("/") -> "https://www.example.org";
+		r4: * -> setBackendFromCustomLogic() -> <dynamic>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r[0].IsDynamic() || r[2].IsDynamic() {
+		t.Error("failed to detect dynamic backends")
+	}
+
+	if (&Route{BackendType: DynamicBackend, Shunt: true}).IsDynamic() {
+		t.Error("failed to prefer the legacy shunt")
+	}
+
+	t.Run("default filters", func(t *testing.T) {
+		errs := ValidateDynamicBackends(r)
+		if len(errs) != 2 ||
+			!strings.HasPrefix(errs[0].Error(), "route r2 has a dynamic backend") ||
+			!strings.HasPrefix(errs[1].Error(), "route r4 has a dynamic backend") {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	t.Run("custom filters", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateDynamicBackends(r, "setDynamicBackendUrl", "setBackendFromCustomLogic"),
+			"route r2 has a dynamic backend but none of the filters setting it: setDynamicBackendUrl, setBackendFromCustomLogic",
+		)
+	})
+}
+
+func TestValidateRegexpComplexity(t *testing.T) {
+	r, err := Parse(`
+		r1: PathRegexp(/^\/api\/[a-z]+$/) && Host(/^(www|api)[.]example[.]org$/) -> <shunt>;
+		r2: PathRegexp(/^(a+)+$/) -> <shunt>;
+		r3: HeaderRegexp("X-Foo", /^(a|b*)*$/) && Host(/^(a{2,})+$/) -> <shunt>;
+		r4: Host(/^[a-z]{1,10}([.][a-z]{1,10})*$/) -> <shunt>;
+		r5: PathRegexp(/^[/]abcdefghijklmnopqrstuvwxyz$/) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("default options", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateRegexpComplexity(r, DefaultRegexpComplexityOptions),
+			"regexp rejected in route r2, predicate PathRegexp: nested unbounded quantifiers in ^(a+)+$",
+			"regexp rejected in route r3, predicate HeaderRegexp: nested unbounded quantifiers in ^(a|b*)*$",
+			"regexp rejected in route r3, predicate Host: nested unbounded quantifiers in ^(a{2,})+$",
+		)
+	})
+
+	t.Run("max length", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateRegexpComplexity(r, RegexpComplexityOptions{MaxLength: 29, AllowNestedQuantifiers: true}),
+			"regexp rejected in route r4, predicate Host: longer than 29 bytes",
+			"regexp rejected in route r5, predicate PathRegexp: longer than 29 bytes",
+		)
+	})
+
+	t.Run("invalid regexp", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateRegexpComplexity([]*Route{{Id: "r1", PathRegexps: []string{"^(a"}}}, RegexpComplexityOptions{}),
+			"regexp rejected in route r1, predicate PathRegexp: error parsing regexp: missing closing ): `^(a`",
+		)
+	})
+}
+
+func TestValidateShuntRoutes(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> <shunt>;
+		r2: * -> "https://www.example.org";
+		r3: * -> <loopback>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		checkErrors(t, ValidateShuntRoutes(r))
+	})
+
+	t.Run("stray backend addresses", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateShuntRoutes([]*Route{
+				{Id: "r1", BackendType: ShuntBackend, Backend: "https://www.example.org"},
+				{Id: "r2", Shunt: true, Backend: "https://www.example.org"},
+				{Id: "r3", BackendType: NetworkBackend, Backend: "https://www.example.org"},
+				{Id: "r4", BackendType: LoopBackend, Backend: "https://loop.example.org"},
+				{Id: "r5", BackendType: DynamicBackend, Backend: "https://dynamic.example.org"},
+				{Id: "r6", BackendType: DynamicBackend},
+			}),
+			"route r1 has a shunt backend and a network backend address: https://www.example.org",
+			"route r2 has a shunt backend and a network backend address: https://www.example.org",
+			"route r4 has a loopback backend and a network backend address: https://loop.example.org",
+			"route r5 has a dynamic backend and a network backend address: https://dynamic.example.org",
+		)
+	})
+}
+
+func TestValidateShuntField(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> <shunt>;
+		r2: * -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rj, err := UnmarshalRoutesJSON([]byte(`[{"id": "r3", "backend": "<shunt>"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("parsed", func(t *testing.T) {
+		checkErrors(t, ValidateShuntField(append(r, rj...)))
+	})
+
+	t.Run("constructed", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateShuntField([]*Route{
+				{Id: "r1", Shunt: true},
+				{Id: "r2", Shunt: true, BackendType: ShuntBackend},
+				{Id: "r3", BackendType: ShuntBackend},
+				{Id: "r4", Shunt: true, BackendType: LoopBackend},
+			}),
+			"route r1 uses the deprecated Shunt field with a network backend type, instead of the shunt backend type",
+			"route r4 uses the deprecated Shunt field with a loopback backend type, instead of the shunt backend type",
+		)
+	})
+}
+
+func TestValidateBackendPresence(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> <shunt>;
+		r2: * -> "https://www.example.org";
+		r3: * -> <loopback>;
+		r4: * -> <dynamic>;
+		r5: * -> <"https://a.example.org", "https://b.example.org">;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		checkErrors(t, ValidateBackendPresence(r))
+	})
+
+	t.Run("missing backends", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateBackendPresence([]*Route{
+				{Id: "r1"},
+				{Id: "r2", Shunt: true},
+				{Id: "r3", BackendType: NetworkBackend, Backend: "https://www.example.org"},
+				{Id: "r4", BackendType: LBBackend},
+				{Id: "r5", BackendType: ShuntBackend},
+			}),
+			"route r1 has no backend",
+			"route r4 has a load balanced backend without endpoints",
+		)
+	})
+}
+
+func TestWarnUnanchoredRegexps(t *testing.T) {
+	r, err := Parse(`
+		anchored: Host(/^www[.]example[.]org$/) && PathRegexp(/^\/api\/v[0-9]+$/) -> <shunt>;
+		multiline: PathRegexp(/(?m)^\/foo$/) -> <shunt>;
+		noStart: Host(/[.]example[.]org$/) -> <shunt>;
+		noEnd: PathRegexp("^/foo") -> <shunt>;
+		none: PathRegexp("/foo") && HeaderRegexp("X-Foo", "bar") -> <shunt>;
+		invalid: PathRegexp("(/foo") -> <shunt>;
+		legacy: HostRegexp("example[.]org") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w []string
+	for _, wi := range WarnUnanchoredRegexps(r) {
+		w = append(w, wi.String())
+	}
+
+	if d := cmp.Diff([]string{
+		"route noStart, predicate Host: the regexp [.]example[.]org$ is missing the ^ anchor, and it may match more than expected",
+		"route noEnd, predicate PathRegexp: the regexp ^/foo is missing the $ anchor, and it may match more than expected",
+		"route none, predicate PathRegexp: the regexp /foo is missing the ^ and $ anchors, and it may match more than expected",
+		"route legacy, predicate HostRegexp: the regexp example[.]org is missing the ^ and $ anchors, and it may match more than expected",
+	}, w); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestSuggestLiteralPredicates(t *testing.T) {
+	r, err := Parse(`
+		path: PathRegexp("^/foo$") -> <shunt>;
+		escaped: PathRegexp(/^\/api\/v1[.]json$/) -> <shunt>;
+		header: HeaderRegexp("X-Mode", /^test$/) -> <shunt>;
+		pattern: PathRegexp("^/foo/[0-9]+$") && HeaderRegexp("X-Mode", "test") -> <shunt>;
+		caseInsensitive: PathRegexp("(?i)^/foo$") -> <shunt>;
+		multiline: PathRegexp("(?m)^/foo$") -> <shunt>;
+		wildcard: PathRegexp("^/foo/:id$") -> <shunt>;
+		host: Host(/^www[.]example[.]org$/) -> <shunt>;
+		empty: HeaderRegexp("X-Mode", "^$") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s []string
+	for _, si := range SuggestLiteralPredicates(r) {
+		s = append(s, si.String())
+	}
+
+	if d := cmp.Diff([]string{
+		`route path: PathRegexp("^/foo$") can be replaced by Path("/foo")`,
+		`route escaped: PathRegexp("^/api/v1[.]json$") can be replaced by Path("/api/v1.json")`,
+		`route header: HeaderRegexp("X-Mode", "^test$") can be replaced by Header("X-Mode", "test")`,
+	}, s); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestValidateBackendOptions(t *testing.T) {
+	r, err := Parse(`
+		// @backend-timeout=5s
+		// @backend-connect-timeout=100ms
+		// @backend-health-check-interval=1m
+		// @backend-weight=3
+		valid: * -> "https://www.example.org";
+
+		// @backend-timeout=5
+		// @backend-weight=-1
+		// @backend-retries=3
+		invalid: * -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkErrors(
+		t,
+		ValidateBackendOptions(r),
+		"unknown backend option in route invalid: retries",
+		`invalid backend option in route invalid, timeout: time: missing unit in duration "5"`,
+		"invalid backend option in route invalid, weight: expected a non-negative integer: -1",
+	)
+}
+
+func TestValidateFilterOrder(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> oauthTokeninfoAnyScope("read") -> forwardToken("X-Token") -> <shunt>;
+		r2: * -> forwardToken("X-Token") -> oauthTokeninfoAnyScope("read") -> <shunt>;
+		r3: * -> forwardToken("X-Token") -> <shunt>;
+		r4: * -> setPath("/") -> forwardToken("X-Token") -> status(200) -> oauthTokeninfoAnyScope("read") -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []OrderRule{
+		{Before: "oauthTokeninfoAnyScope", After: "forwardToken"},
+		{Before: "setPath", After: "status"},
+	}
+
+	checkErrors(
+		t,
+		ValidateFilterOrder(r, rules),
+		"invalid filter order in route r2: oauthTokeninfoAnyScope at position 1 must precede forwardToken at position 0",
+		"invalid filter order in route r4: oauthTokeninfoAnyScope at position 3 must precede forwardToken at position 1",
+	)
+}
+
+func TestValidateSingleCatchAll(t *testing.T) {
+	for _, test := range []struct {
+		title  string
+		routes string
+		expect string
+	}{{
+		title: "single catch-all",
+		routes: `
+			r1: Path("/foo") -> <shunt>;
+			r2: * -> <shunt>;
+		`,
+	}, {
+		title: "weighted any",
+		routes: `
+			r1: Path("/foo") -> <shunt>;
+			r2: Any() && Weight(3) -> <shunt>;
+		`,
+	}, {
+		title:  "no catch-all",
+		routes: `r1: Path("/foo") -> <shunt>; r2: Method("GET") -> <shunt>;`,
+		expect: "no catch-all route",
+	}, {
+		title: "multiple catch-alls",
+		routes: `
+			r1: * -> <shunt>;
+			r2: Path("/foo") -> <shunt>;
+			r3: Any() -> <shunt>;
+		`,
+		expect: "multiple catch-all routes: r1, r3",
+	}} {
+		t.Run(test.title, func(t *testing.T) {
+			r, err := Parse(test.routes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ValidateSingleCatchAll(r)
+			if test.expect == "" && err != nil {
+				t.Fatal(err)
+			}
+
+			if test.expect != "" && (err == nil || err.Error() != test.expect) {
+				t.Errorf("failed to fail with the right error, got: %v, expected: %s", err, test.expect)
+			}
+		})
+	}
+}
+
+func TestValidateIDFormat(t *testing.T) {
+	r := []*Route{{Id: "foo_bar1"}, {Id: "Foo"}, {}, {Id: "1foo"}, {Id: "foo-bar"}}
+	rx := regexp.MustCompile("^[a-z][a-z0-9_]*$")
+
+	t.Run("empty not allowed", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateIDFormat(r, rx, false),
+			"invalid route id: Foo, expected to match ^[a-z][a-z0-9_]*$",
+			"empty route id",
+			"invalid route id: 1foo, expected to match ^[a-z][a-z0-9_]*$",
+			"invalid route id: foo-bar, expected to match ^[a-z][a-z0-9_]*$",
+		)
+	})
+
+	t.Run("empty allowed", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateIDFormat(r, rx, true),
+			"invalid route id: Foo, expected to match ^[a-z][a-z0-9_]*$",
+			"invalid route id: 1foo, expected to match ^[a-z][a-z0-9_]*$",
+			"invalid route id: foo-bar, expected to match ^[a-z][a-z0-9_]*$",
+		)
+	})
+}
+
+func TestValidateRouteReferences(t *testing.T) {
+	r, err := Parse(`
+		r1: * -> teeLoopback("r2") -> <shunt>;
+		r2: Path("/foo") -> teeLoopback("r4") -> <shunt>;
+		r3: Path("/bar") -> teeLoopback() -> customRef("x", "r1") -> customRef("x", "r5") -> <shunt>;
+		r4: Path("/baz") -> teeLoopback(42) -> <shunt>;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		checkErrors(t, ValidateRouteReferences(r[:2], map[string]int{"customRef": 1}))
+	})
+
+	t.Run("unknown references", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateRouteReferences(r, map[string]int{"teeLoopback": 0, "customRef": 1}),
+			"route r3 references an unknown route in filter customRef: r5",
+		)
+
+		checkErrors(
+			t,
+			ValidateRouteReferences(r[:2], map[string]int{"teeLoopback": 0}),
+			"route r2 references an unknown route in filter teeLoopback: r4",
+		)
+	})
+}
+
+func TestValidateUTF8(t *testing.T) {
+	r, err := Parse(`
+		// @team=gateway
+		r1: Path("/foo") && Header("X-Foo", "bär") -> setPath("/bar") -> "https://www.example.org";
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []*Route{{
+		Id:      "r2",
+		Headers: map[string]string{"X-Foo\xff": "ok", "X-Bar": "b\xc3"},
+		Filters: []*Filter{
+			{Name: "setPath", Args: []interface{}{"/ok", 42, "/b\xffr"}},
+		},
+		Predicates: []*Predicate{
+			{Name: "Not", Args: []interface{}{&Predicate{Name: "Custom", Args: []interface{}{"\xfe"}}}},
+		},
+		BackendType: NetworkBackend,
+		Backend:     "https://www.example.org/\xff",
+	}, {
+		Id:          "r3",
+		Annotations: map[string]string{"team": "gate\xffway"},
+		BackendType: LBBackend,
+		LBEndpoints: []string{"https://a.example.org", "https://\xffb.example.org"},
+	}}
+
+	t.Run("valid", func(t *testing.T) {
+		checkErrors(t, ValidateUTF8(r))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		checkErrors(
+			t,
+			ValidateUTF8(invalid),
+			"route r2 has invalid UTF-8 in predicate Header arg 1 at byte 1",
+			"route r2 has invalid UTF-8 in predicate Header arg 0 at byte 5",
+			"route r2 has invalid UTF-8 in predicate Not arg 0 predicate Custom arg 0 at byte 0",
+			"route r2 has invalid UTF-8 in filter setPath arg 2 at byte 2",
+			"route r2 has invalid UTF-8 in backend at byte 24",
+			"route r3 has invalid UTF-8 in load balancer endpoint 1 at byte 8",
+			"route r3 has invalid UTF-8 in annotation team at byte 4",
+		)
+	})
+}
@@ -334,6 +334,13 @@ func mergeLegacyNonTreePredicates(r *eskip.Route) (*eskip.Route, error) {
 		}
 	}
 
+	rest = append(rest, eskip.QueryParamPredicates(c)...)
+	c.QueryParams = nil
+	c.QueryParamRegexps = nil
+
+	rest = append(rest, eskip.CookiePredicates(c)...)
+	c.Cookies = nil
+
 	c.Predicates = rest
 	return c, nil
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/zalando/skipper/eskip"
 	"github.com/zalando/skipper/filters"
 	"github.com/zalando/skipper/logging"
 	"github.com/zalando/skipper/logging/loggingtest"
@@ -109,6 +110,56 @@ func TestErrorWhenUsingPrediateAsFilter(t *testing.T) {
 	}
 }
 
+func TestQueryParamFields(t *testing.T) {
+	r, err := eskip.ParseWithOptions(
+		`QueryParam("page", "^[0-9]+$") && QueryParam("debug") -> <shunt>`,
+		eskip.ParseOptions{QueryParamFields: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mergeLegacyNonTreePredicates(r[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.QueryParams != nil || m.QueryParamRegexps != nil {
+		t.Error("failed to move the query params to the predicates")
+	}
+
+	if len(m.Predicates) != 2 ||
+		m.Predicates[0].String() != `QueryParam("debug")` ||
+		m.Predicates[1].String() != `QueryParam("page", "^[0-9]+$")` {
+		t.Errorf("invalid predicates: %v", m.Predicates)
+	}
+}
+
+func TestCookieFields(t *testing.T) {
+	r, err := eskip.ParseWithOptions(
+		`Cookie("tcial", "^enabled$") && Cookie("session", "^[0-9a-f]+$") -> <shunt>`,
+		eskip.ParseOptions{CookieFields: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mergeLegacyNonTreePredicates(r[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Cookies != nil {
+		t.Error("failed to move the cookies to the predicates")
+	}
+
+	if len(m.Predicates) != 2 ||
+		m.Predicates[0].String() != `Cookie("session", "^[0-9a-f]+$")` ||
+		m.Predicates[1].String() != `Cookie("tcial", "^enabled$")` {
+		t.Errorf("invalid predicates: %v", m.Predicates)
+	}
+}
+
 func TestLogging(t *testing.T) {
 	if testing.Short() {
 		t.Skip()